@@ -1,58 +1,478 @@
 package main
 
 import (
+	"context"
+	"embed"
 	"errors"
-	"log"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
-	"strings"
-
-	"net/http"
+	"syscall"
+	"time"
 
 	"blue-beetle/config"
 	"blue-beetle/database"
+	"blue-beetle/logging"
+	"blue-beetle/mail"
+	"blue-beetle/server"
 )
 
-func getConfig() (*config.SysConfig, error) {
-	directory, err := filepath.Abs(filepath.Dir(os.Args[0]))
+//go:embed pages/*.html
+var pages embed.FS
+
+type options struct {
+	configPath string
+	port       int
+	logLevel   string
+	command    string
+	args       []string
+}
+
+const usage = `Usage: blue-beetle [flags] [command]
+
+Commands:
+  serve             start the web server (default)
+  validate-config   check the configuration and report every problem
+  migrate up        apply pending database migrations
+  migrate down [n]  revert the last n migrations (default 1)
+  migrate status    list migrations and whether they are applied
+  reconcile [--fix] compare point balances with the ledger, --fix repairs them
+  close-season <name> [--carry-over N]
+                    archive the open season and start <name>, carrying N% of
+                    unspent points over (default points.carry-over-percent)
+  award-badges      check the badge rules for every participant, e.g. after
+                    adding a rule
+
+Flags:
+`
+
+func parseFlags(args []string) (options, error) {
+	var opts options
+	flags := flag.NewFlagSet("blue-beetle", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.configPath, "config", "", "path to config.yaml (default: ./config/config.yaml, then config/config.yaml next to the executable)")
+	flags.IntVar(&opts.port, "port", 0, "port to listen on, overrides server.port")
+	flags.StringVar(&opts.logLevel, "log-level", "", "debug, info, warn or error, overrides logging.level")
+	err := flags.Parse(args)
+	if err != nil {
+		return opts, err
+	}
+	if flags.NArg() > 0 {
+		opts.command = flags.Arg(0)
+		opts.args = flags.Args()[1:]
+	}
+	return opts, nil
+}
+
+// defaultConfigPaths lists where config.yaml is looked for when --config is
+// not given: the working directory first, then next to the real executable.
+func defaultConfigPaths() []string {
+	paths := []string{filepath.Join("config", "config.yaml")}
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+		if err == nil {
+			paths = append(paths, filepath.Join(filepath.Dir(executable), "config", "config.yaml"))
+		}
+	}
+	return paths
+}
+
+// getConfig loads the configuration and returns it with the path of the
+// file it came from, which is empty when the defaults are used.
+func getConfig(opts options) (string, *config.SysConfig, error) {
+	if opts.configPath != "" {
+		sconfig, err := config.LoadConfig(opts.configPath)
+		return opts.configPath, sconfig, err
+	}
+	for _, path := range defaultConfigPaths() {
+		sconfig, err := config.LoadConfig(path)
+		if errors.Is(err, config.ErrConfigNotFound) {
+			logging.Debugf("No config file at %s", path)
+			continue
+		}
+		if err == nil {
+			logging.Infof("Loaded config file %s", path)
+		}
+		return path, sconfig, err
+	}
+	logging.Warnf("No config file found, using the default sqlite configuration")
+	sconfig := config.DefaultConfig()
+	err := config.ApplyEnvironment(sconfig)
+	if err != nil {
+		return "", nil, err
+	}
+	return "", sconfig, nil
+}
+
+func applyFlags(opts options, sconfig *config.SysConfig) {
+	if opts.port != 0 {
+		sconfig.Server.Port = opts.port
+	}
+	if opts.logLevel != "" {
+		sconfig.Logging.Level = opts.logLevel
+	}
+}
+
+func setLogLevel(name string) error {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(level)
+	return nil
+}
+
+// Migrate applies pending schema migrations and seeds the built-in roles
+// and admin account.
+func Migrate(ctx context.Context, repo *database.UserRepository, users *database.UserService) error {
+	count, err := repo.MigrateUp(ctx)
+	if err != nil {
+		return err
+	}
+	err = users.InitiateModels(ctx)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	logging.Infof("Database Migration Completed! %d migration(s) applied", count)
+	return nil
+}
+
+// runMigrate implements "migrate up", "migrate down [steps]" and
+// "migrate status", returning the process exit code.
+func runMigrate(sconfig *config.SysConfig, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: blue-beetle migrate up|down [steps]|status")
+		return 2
 	}
-	config_path := filepath.Join(directory, "config", "config.yaml")
-	_, err = os.Stat(config_path)
+	db, err := database.ConnectDatabase(sconfig.Database)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, errors.New("Could not find config file at: " + config_path)
-		} else if errors.Is(err, os.ErrPermission) {
-			return nil, errors.New("Do not have permissions to read config file at: " + config_path)
-		} else {
-			return nil, errors.New("Unknown error for config file at: " + config_path + " -> " + err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	repo := database.NewUserRepository(db, sconfig.Database.QueryTimeout)
+	defer repo.Close()
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		count, err := repo.MigrateUp(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		fmt.Printf("applied %d migration(s)\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return 2
+			}
+		}
+		count, err := repo.MigrateDown(ctx, steps)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
 		}
+		fmt.Printf("reverted %d migration(s)\n", count)
+	case "status":
+		statuses, err := repo.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "unknown migrate command: "+args[0])
+		return 2
+	}
+	return 0
+}
+
+// runReconcile reports point balances that drifted from the ledger and
+// repairs them with --fix. It exits with 1 when drift is left unfixed so it
+// can run as a scheduled check.
+func runReconcile(sconfig *config.SysConfig, args []string) int {
+	fix := false
+	for _, arg := range args {
+		if arg != "--fix" {
+			fmt.Fprintln(os.Stderr, "usage: blue-beetle reconcile [--fix]")
+			return 2
+		}
+		fix = true
+	}
+	db, err := database.ConnectDatabase(sconfig.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	repo := database.NewUserRepository(db, sconfig.Database.QueryTimeout)
+	defer repo.Close()
+	points := database.NewPointService(repo.Stores(), repo)
+	drifts, err := points.Reconcile(context.Background(), fix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	for _, drift := range drifts {
+		fmt.Printf("%s  stored %d earned/%d spent/%d balance, ledger %d/%d/%d\n",
+			drift.Stored.ParticipantID,
+			drift.Stored.Earned, drift.Stored.Spent, drift.Stored.Balance,
+			drift.Expected.Earned, drift.Expected.Spent, drift.Expected.Balance)
+	}
+	if len(drifts) == 0 {
+		fmt.Println("all balances match the ledger")
+		return 0
+	}
+	if fix {
+		fmt.Printf("fixed %d balance(s)\n", len(drifts))
+		return 0
 	}
-	return config.ProcessConfigYAMLFile(config_path)
+	fmt.Printf("%d balance(s) drifted, run with --fix to repair them\n", len(drifts))
+	return 1
 }
 
-func Migrate() {
-	database.UserRepo.AutoMigrate()
-	database.UserRepo.InitiateModels()
-	log.Println("Database Migration Completed!")
+// runCloseSeason closes the open season and starts the named one.
+func runCloseSeason(sconfig *config.SysConfig, args []string) int {
+	flags := flag.NewFlagSet("close-season", flag.ContinueOnError)
+	carryOver := flags.Int("carry-over", sconfig.Points.CarryOverPercent, "percent of unspent points carried into the new season")
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+		args = args[1:]
+	}
+	err := flags.Parse(args)
+	if err != nil || name == "" || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: blue-beetle close-season <name> [--carry-over N]")
+		return 2
+	}
+	db, err := database.ConnectDatabase(sconfig.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	repo := database.NewUserRepository(db, sconfig.Database.QueryTimeout)
+	defer repo.Close()
+	seasons := database.NewSeasonService(repo.Stores(), repo)
+	ctx := context.Background()
+	closing, err := seasons.CurrentSeason(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	next, err := seasons.CloseSeason(ctx, database.SystemUser, name, *carryOver)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Printf("closed %s and started %s, carrying over %d%% of unspent points\n", closing.Name, next.Name, *carryOver)
+	return 0
+}
+
+// runAwardBadges awards the badges participants reached before their rules
+// were configured.
+func runAwardBadges(sconfig *config.SysConfig, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: blue-beetle award-badges")
+		return 2
+	}
+	config.SetLive(sconfig)
+	db, err := database.ConnectDatabase(sconfig.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	repo := database.NewUserRepository(db, sconfig.Database.QueryTimeout)
+	defer repo.Close()
+	badges := database.NewBadgeService(repo.Stores(), repo)
+	count, err := badges.EvaluateAll(context.Background(), database.SystemUser)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Printf("awarded %d badge(s)\n", count)
+	return 0
+}
+
+// validateConfig prints every configuration problem and returns the
+// process exit code.
+func validateConfig(sconfig *config.SysConfig) int {
+	err := sconfig.Validate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Println("configuration is valid")
+	return 0
+}
+
+// watchConfig reloads the non-structural settings whenever the config file
+// changes or the process receives SIGHUP.
+func watchConfig(ctx context.Context, opts options, configPath string, sconfig *config.SysConfig) {
+	watcher := config.NewWatcher(configPath, sconfig, func(next *config.SysConfig) {
+		applyFlags(opts, next)
+	})
+	watcher.OnReload(func(current *config.SysConfig) {
+		err := setLogLevel(current.Logging.Level)
+		if err != nil {
+			logging.Errorf("Could not apply log level: %v", err)
+		}
+	})
+	go watcher.Run(ctx)
+}
+
+// serve runs the web server until it fails or SIGINT/SIGTERM is received,
+// then drains in-flight requests and closes the database pool.
+func serve(opts options, configPath string, sconfig *config.SysConfig) error {
+	err := sconfig.Validate()
+	if err != nil {
+		return err
+	}
+	config.SetLive(sconfig)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if configPath != "" {
+		watchConfig(ctx, opts, configPath, sconfig)
+	}
+	db, err := database.ConnectDatabase(sconfig.Database)
+	if err != nil {
+		return err
+	}
+	repo := database.NewUserRepository(db, sconfig.Database.QueryTimeout)
+	defer func() {
+		err := repo.Close()
+		if err != nil {
+			logging.Errorf("Closing the database failed: %v", err)
+		}
+	}()
+	users := database.NewUserService(repo.Stores(), repo)
+	err = Migrate(ctx, repo, users)
+	if err != nil {
+		return err
+	}
+
+	replicas, err := database.ConnectReplicas(db, sconfig.Database)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := replicas.Close()
+		if err != nil {
+			logging.Errorf("Closing the database replicas failed: %v", err)
+		}
+	}()
+
+	health := database.NewHealthMonitor(repo, sconfig.Database.HealthCheckInterval)
+	if sconfig.Database.HealthCheckInterval > 0 {
+		go health.Run(ctx)
+	}
+	// Replicas only take reads once a check has passed, so they are checked
+	// at least once even without a background interval.
+	go replicas.Run(ctx)
+
+	services := server.Services{
+		Users:        users,
+		Leaderboards: database.NewLeaderboardService(repo.Stores(), repo),
+		Catalog:      database.NewCatalogService(repo.Stores(), repo),
+		Checkout:     database.NewCheckoutService(repo.Stores(), repo),
+		Categories:   database.NewCategoryService(repo.Stores(), repo),
+		Teams:        database.NewTeamService(repo.Stores(), repo),
+		Events:       database.NewEventService(repo.Stores(), repo),
+		Awards:       database.NewAwardService(repo.Stores(), repo),
+		Guardians:    database.NewGuardianService(repo.Stores(), repo),
+		Kiosk:        database.NewKioskService(repo.Stores(), repo),
+		Mail:         mail.SMTPSender{},
+		PublicURL:    sconfig.Server.PublicURL,
+	}
+	sessions := server.NewSessionStore(server.SessionLifetime)
+	go sessions.Run(ctx, server.SessionSweepInterval)
+	handlers, err := server.NewHandlers(services, sessions, health, pages)
+	if err != nil {
+		return err
+	}
+	srv, err := server.New(sconfig.Server, handlers.Routes())
+	if err != nil {
+		return err
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	logging.Infof("Shutting down, waiting up to %s for in-flight requests", sconfig.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), sconfig.Server.ShutdownTimeout)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return errors.New("graceful shutdown failed: " + err.Error())
+	}
+	logging.Infof("Server stopped")
+	return nil
 }
 
 func main() {
-	sconfig, err := getConfig()
+	opts, err := parseFlags(os.Args[1:])
 	if err != nil {
-		if strings.HasPrefix(err.Error(), "Could not find config file at") {
-			sconfig = &config.SysConfig{Server: config.ServerConfig{Port: 8080}, Database: config.DBConfig{Type: "sqlite", FilePath: "database.sqlite"}}
-		} else {
+		os.Exit(2)
+	}
+	if opts.logLevel != "" {
+		// Apply the flag early so config discovery is logged at that level.
+		err = setLogLevel(opts.logLevel)
+		if err != nil {
 			panic(err.Error())
 		}
 	}
-	err = database.UserRepo.ConnectUserRepository(sconfig.Database)
+	configPath, sconfig, err := getConfig(opts)
 	if err != nil {
-		panic(err)
+		if opts.command == "validate-config" {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		panic(err.Error())
 	}
-	Migrate()
+	applyFlags(opts, sconfig)
 
-	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(sconfig.Server.Port), nil))
+	switch opts.command {
+	case "", "serve":
+		err = setLogLevel(sconfig.Logging.Level)
+		if err != nil {
+			panic(err.Error())
+		}
+		err = serve(opts, configPath, sconfig)
+		if err != nil {
+			logging.Errorf("%v", err)
+			os.Exit(1)
+		}
+	case "validate-config":
+		os.Exit(validateConfig(sconfig))
+	case "migrate":
+		os.Exit(runMigrate(sconfig, opts.args))
+	case "reconcile":
+		os.Exit(runReconcile(sconfig, opts.args))
+	case "close-season":
+		os.Exit(runCloseSeason(sconfig, opts.args))
+	case "award-badges":
+		os.Exit(runAwardBadges(sconfig, opts.args))
+	default:
+		fmt.Fprintln(os.Stderr, "unknown command: "+opts.command)
+		os.Exit(2)
+	}
 }
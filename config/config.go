@@ -2,12 +2,21 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
+var (
+	// ErrConfigNotFound is returned when the config file does not exist.
+	ErrConfigNotFound = errors.New("config file not found")
+	// ErrConfigPermission is returned when the config file can not be read.
+	ErrConfigPermission = errors.New("no permission to read config file")
+)
+
 type DBConfig struct {
 	Type     string   `yaml:"type"`
 	FilePath string   `yaml:"file-path"`
@@ -18,22 +27,272 @@ type DBConfig struct {
 	Port     int      `yaml:"port"`
 	DBName   string   `yaml:"db-name"`
 	Options  []string `yaml:"options"`
+	// DSN is passed to the driver as is, replacing the connection string
+	// built from the fields above. Options are ignored when it is set.
+	DSN string `yaml:"dsn"`
+	// QueryTimeout bounds each repository call on top of the request's own
+	// deadline.
+	QueryTimeout time.Duration `yaml:"query-timeout"`
+	// MaxOpenConns caps the connection pool, 0 means unlimited.
+	MaxOpenConns int `yaml:"max-open-conns"`
+	// MaxIdleConns is how many unused connections are kept open.
+	MaxIdleConns int `yaml:"max-idle-conns"`
+	// ConnMaxLifetime and ConnMaxIdleTime recycle connections so the pool
+	// follows fail-overs and server side limits, 0 keeps them forever.
+	ConnMaxLifetime time.Duration `yaml:"conn-max-lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn-max-idle-time"`
+	// PrepareStatements caches prepared statements per connection.
+	PrepareStatements bool `yaml:"prepare-statements"`
+	// SlowQueryThreshold logs queries taking longer as warnings, 0 disables it.
+	SlowQueryThreshold time.Duration `yaml:"slow-query-threshold"`
+	// HealthCheckInterval is how often the database is pinged to report
+	// readiness, 0 disables the background check.
+	HealthCheckInterval time.Duration `yaml:"health-check-interval"`
+	// Replicas serve read-only reporting queries and searches. They are
+	// checked at startup and every HealthCheckInterval, and skipped until a
+	// check passes.
+	Replicas []ReplicaConfig `yaml:"replicas"`
+}
+
+// ReplicaConfig is a read-only copy of the primary database. Empty fields
+// take the value of the primary, everything else is shared with it. The
+// password is best kept out of the file with
+// BLUEBEETLE_DATABASE_REPLICAS_<index>_PASSWORD_FILE.
+type ReplicaConfig struct {
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	DSN      string `yaml:"dsn"`
+}
+
+// Replica returns the connection settings of the i-th replica.
+func (c DBConfig) Replica(i int) DBConfig {
+	replica := c.Replicas[i]
+	c.Replicas = nil
+	c.DSN = replica.DSN
+	if replica.Server != "" {
+		c.Server = replica.Server
+	}
+	if replica.Port != 0 {
+		c.Port = replica.Port
+	}
+	if replica.Username != "" {
+		c.Username = replica.Username
+	}
+	if replica.Password != "" {
+		c.Password = replica.Password
+	}
+	return c
+}
+
+// TLSConfig controls HTTPS serving. When SelfSigned is set and the
+// certificate or key file is missing, a self-signed pair is generated on
+// start; this is meant for development only.
+type TLSConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"cert-file"`
+	KeyFile      string `yaml:"key-file"`
+	MinVersion   string `yaml:"min-version"`
+	CipherPolicy string `yaml:"cipher-policy"`
+	// RedirectPort is the plain HTTP port redirected to HTTPS, 0 disables it.
+	RedirectPort int `yaml:"redirect-port"`
+	// HSTSMaxAge is the Strict-Transport-Security max-age in seconds, 0 disables it.
+	HSTSMaxAge int  `yaml:"hsts-max-age"`
+	SelfSigned bool `yaml:"self-signed"`
 }
 
 type ServerConfig struct {
-	Port int `yaml:"port"`
+	Port              int           `yaml:"port"`
+	TLS               TLSConfig     `yaml:"tls"`
+	ReadTimeout       time.Duration `yaml:"read-timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read-header-timeout"`
+	WriteTimeout      time.Duration `yaml:"write-timeout"`
+	IdleTimeout       time.Duration `yaml:"idle-timeout"`
+	MaxHeaderBytes    int           `yaml:"max-header-bytes"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// after SIGINT or SIGTERM before connections are closed.
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout"`
+	// PublicURL is the address users reach the server at, such as
+	// https://points.example.org. Links handed out by mail or for pairing
+	// kiosks are built from it rather than from the Host header of a
+	// request.
+	PublicURL string `yaml:"public-url"`
+}
+
+type LoggingConfig struct {
+	Level string `yaml:"level"`
+}
+
+// MailConfig holds the SMTP settings used for outgoing email.
+type MailConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// PasswordPolicyConfig controls which passwords users may choose.
+type PasswordPolicyConfig struct {
+	MinLength     int  `yaml:"min-length"`
+	RequireUpper  bool `yaml:"require-upper"`
+	RequireLower  bool `yaml:"require-lower"`
+	RequireNumber bool `yaml:"require-number"`
+	RequireSymbol bool `yaml:"require-symbol"`
+}
+
+type RateLimitConfig struct {
+	// MaxLoginAttempts is the number of failed logins before an account is
+	// refused until its password is reset.
+	MaxLoginAttempts uint `yaml:"max-login-attempts"`
+}
+
+// PointsConfig holds the rules for the points ledger.
+type PointsConfig struct {
+	// CarryOverPercent is the share of each unspent balance moved into the
+	// next season when a season is closed, 0 starts everyone from zero.
+	CarryOverPercent int `yaml:"carry-over-percent"`
+}
+
+// LeaderboardConfig controls what leaderboards show.
+type LeaderboardConfig struct {
+	// Size is how many places a leaderboard shows when the request does not
+	// ask for a number; ties with the last place are always included.
+	Size int `yaml:"size"`
+	// InitialsOnly shows participants by their initials instead of their
+	// names. It is on by default because /leaderboard needs no login; set
+	// it to false only where every viewer may see the full names.
+	InitialsOnly bool `yaml:"initials-only"`
+}
+
+// KioskConfig controls check-in at the door kiosks.
+type KioskConfig struct {
+	// AttendancePoints are awarded to each participant checking in.
+	AttendancePoints int64 `yaml:"attendance-points"`
+	// Category is the name of the category attendance points are booked
+	// in, empty books them uncategorized.
+	Category string `yaml:"category"`
+	// EarlyCheckIn is how long before an event starts check-in opens.
+	EarlyCheckIn time.Duration `yaml:"early-check-in"`
+}
+
+// Badge rule kinds.
+const (
+	// BadgePoints rules count the points a participant earned in the season.
+	BadgePoints = "points"
+	// BadgeEntries rules count the ledger entries a participant earned
+	// points with in the season.
+	BadgeEntries = "entries"
+	// BadgeMonthlyAttendance rules are met by attending every event of a
+	// calendar month.
+	BadgeMonthlyAttendance = "monthly-attendance"
+)
+
+// BadgeRule awards a badge once a participant reaches a milestone. Points
+// and entries badges are awarded once per season, attendance badges once
+// per month.
+type BadgeRule struct {
+	// Key identifies the rule in awarded badges. Changing it lets everyone
+	// earn the badge again.
+	Key  string `yaml:"key"`
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+	// Category limits points and entries rules to the category with this
+	// name, empty counts every category.
+	Category  string `yaml:"category"`
+	Threshold int64  `yaml:"threshold"`
+	// Bonus points are granted with the badge, 0 grants none.
+	Bonus int64 `yaml:"bonus"`
 }
 
 type SysConfig struct {
-	Database DBConfig     `yaml:"database"`
-	Server   ServerConfig `yaml:"server"`
+	Database       DBConfig             `yaml:"database"`
+	Server         ServerConfig         `yaml:"server"`
+	Logging        LoggingConfig        `yaml:"logging"`
+	Mail           MailConfig           `yaml:"mail"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password-policy"`
+	RateLimit      RateLimitConfig      `yaml:"rate-limit"`
+	Points         PointsConfig         `yaml:"points"`
+	Leaderboard    LeaderboardConfig    `yaml:"leaderboard"`
+	Badges         []BadgeRule          `yaml:"badges"`
+	Kiosk          KioskConfig          `yaml:"kiosk"`
+}
+
+// DefaultConfig returns the settings used for anything not set in
+// config.yaml or the environment.
+func DefaultConfig() *SysConfig {
+	return &SysConfig{
+		Server: ServerConfig{
+			Port:              8080,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+			TLS: TLSConfig{
+				CertFile:     "tls/cert.pem",
+				KeyFile:      "tls/key.pem",
+				MinVersion:   "1.2",
+				CipherPolicy: "modern",
+				HSTSMaxAge:   31536000,
+			},
+		},
+		Database: DBConfig{
+			Type:                "sqlite",
+			FilePath:            "database.sqlite",
+			QueryTimeout:        10 * time.Second,
+			MaxOpenConns:        25,
+			MaxIdleConns:        5,
+			ConnMaxLifetime:     30 * time.Minute,
+			ConnMaxIdleTime:     5 * time.Minute,
+			PrepareStatements:   true,
+			SlowQueryThreshold:  200 * time.Millisecond,
+			HealthCheckInterval: 15 * time.Second,
+		},
+		Logging: LoggingConfig{Level: "info"},
+		Mail:    MailConfig{Port: 587},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:     8,
+			RequireUpper:  true,
+			RequireLower:  true,
+			RequireNumber: true,
+			RequireSymbol: true,
+		},
+		RateLimit:   RateLimitConfig{MaxLoginAttempts: 5},
+		Leaderboard: LeaderboardConfig{Size: 10, InitialsOnly: true},
+		Kiosk:       KioskConfig{AttendancePoints: 1, EarlyCheckIn: 30 * time.Minute},
+	}
+}
+
+// LoadConfig reads the YAML file at filePath over the defaults and then
+// applies environment overrides (see EnvPrefix).
+func LoadConfig(filePath string) (*SysConfig, error) {
+	config, err := ProcessConfigYAMLFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	err = ApplyEnvironment(config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 func ProcessConfigYAMLFile(filePath string) (*SysConfig, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrConfigNotFound, filePath)
+		}
+		if errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("%w: %s", ErrConfigPermission, filePath)
+		}
 		return nil, errors.New("yamlFile.Get err " + err.Error())
 	}
+	defer file.Close()
 	yamlFile, err := io.ReadAll(file)
 	if err != nil {
 		return nil, errors.New("yamlFile.Get err " + err.Error())
@@ -46,10 +305,10 @@ func ProcessConfigYAMLFile(filePath string) (*SysConfig, error) {
 }
 
 func ProcessConfigYAML(yamlData string) (*SysConfig, error) {
-	var config SysConfig
-	err := yaml.Unmarshal([]byte(yamlData), &config)
+	config := DefaultConfig()
+	err := yaml.Unmarshal([]byte(yamlData), config)
 	if err != nil {
 		return nil, errors.New("Unmarshal: " + err.Error())
 	}
-	return &config, nil
+	return config, nil
 }
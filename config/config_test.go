@@ -0,0 +1,260 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testYAML = `
+database:
+  type: postgresql
+  username: beetle
+  password: from-yaml
+  server: localhost
+  port: 5432
+  db-name: beetle
+server:
+  port: 9090
+`
+
+func TestProcessConfigYAML_ShouldKeepDefaults(t *testing.T) {
+	config, err := ProcessConfigYAML("database:\n  type: mysql\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql", config.Database.Type)
+	assert.Equal(t, 8080, config.Server.Port)
+}
+
+func TestApplyEnvironment_ShouldOverrideYAML(t *testing.T) {
+	t.Setenv("BLUEBEETLE_DATABASE_PASSWORD", "from-env")
+	t.Setenv("BLUEBEETLE_DATABASE_DB_NAME", "other")
+	t.Setenv("BLUEBEETLE_DATABASE_OPTIONS", "sslmode=disable, TimeZone=UTC")
+	t.Setenv("BLUEBEETLE_SERVER_PORT", "7070")
+
+	config, err := ProcessConfigYAML(testYAML)
+	assert.Nil(t, err)
+	err = ApplyEnvironment(config)
+	assert.Nil(t, err)
+	assert.Equal(t, "from-env", config.Database.Password)
+	assert.Equal(t, "other", config.Database.DBName)
+	assert.Equal(t, []string{"sslmode=disable", "TimeZone=UTC"}, config.Database.Options)
+	assert.Equal(t, 7070, config.Server.Port)
+	assert.Equal(t, "beetle", config.Database.Username)
+}
+
+func TestApplyEnvironment_ShouldReadSecretFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	err := os.WriteFile(secret, []byte("s3cr3t\n"), 0600)
+	assert.Nil(t, err)
+	t.Setenv("BLUEBEETLE_DATABASE_PASSWORD_FILE", secret)
+
+	config, err := ProcessConfigYAML(testYAML)
+	assert.Nil(t, err)
+	err = ApplyEnvironment(config)
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cr3t", config.Database.Password)
+}
+
+func TestApplyEnvironment_ShouldRejectAmbiguousVariables(t *testing.T) {
+	t.Setenv("BLUEBEETLE_DATABASE_PASSWORD", "from-env")
+	t.Setenv("BLUEBEETLE_DATABASE_PASSWORD_FILE", "/run/secrets/password")
+
+	err := ApplyEnvironment(DefaultConfig())
+	assert.NotNil(t, err)
+}
+
+func TestApplyEnvironment_ShouldRejectInvalidNumbers(t *testing.T) {
+	t.Setenv("BLUEBEETLE_SERVER_PORT", "eighty")
+
+	err := ApplyEnvironment(DefaultConfig())
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfig_ShouldReturnNotFound(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+}
+
+func TestValidate_ShouldAcceptDefaults(t *testing.T) {
+	assert.Nil(t, DefaultConfig().Validate())
+}
+
+func TestValidate_ShouldReportEveryProblem(t *testing.T) {
+	config := DefaultConfig()
+	config.Database = DBConfig{Type: "postgresql", Port: 70000, QueryTimeout: time.Second}
+	config.Server.Port = 0
+	config.Mail = MailConfig{Enabled: true, Port: 25, Host: "smtp.example.com", From: "not-an-address"}
+
+	err := config.Validate()
+	assert.NotNil(t, err)
+	problems, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	var paths []string
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	assert.Equal(t, []string{
+		"database.username",
+		"database.password",
+		"database.server",
+		"database.port",
+		"database.db-name",
+		"server.port",
+		"mail.from",
+	}, paths)
+}
+
+func TestValidate_ShouldAcceptRawDSN(t *testing.T) {
+	config := DefaultConfig()
+	config.Database = DBConfig{Type: "postgresql", DSN: "postgres://gorm@localhost/gorm", QueryTimeout: time.Second}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestDBConfig_ReplicaShouldInheritFromPrimary(t *testing.T) {
+	primary := DBConfig{Type: "postgresql", Username: "gorm", Password: "secret", Server: "db-1", Port: 5432,
+		DBName: "blue_beetle", Replicas: []ReplicaConfig{{Server: "db-2"}, {DSN: "postgres://reader@db-3/blue_beetle"}}}
+
+	replica := primary.Replica(0)
+	assert.Equal(t, "db-2", replica.Server)
+	assert.Equal(t, 5432, replica.Port)
+	assert.Equal(t, "gorm", replica.Username)
+	assert.Equal(t, "blue_beetle", replica.DBName)
+	assert.Nil(t, replica.Replicas)
+	assert.Equal(t, "postgres://reader@db-3/blue_beetle", primary.Replica(1).DSN)
+}
+
+func TestApplyEnvironment_ShouldOverrideReplicasByIndex(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "replica-password")
+	err := os.WriteFile(secret, []byte("r3plica\n"), 0600)
+	assert.Nil(t, err)
+	t.Setenv("BLUEBEETLE_DATABASE_REPLICAS_0_PASSWORD", "from-env")
+	t.Setenv("BLUEBEETLE_DATABASE_REPLICAS_1_PASSWORD_FILE", secret)
+	t.Setenv("BLUEBEETLE_DATABASE_REPLICAS_2_PASSWORD", "no such replica")
+
+	config, err := ProcessConfigYAML(`
+database:
+  type: postgresql
+  replicas:
+    - server: db-2
+      password: from-yaml
+    - server: db-3
+`)
+	assert.Nil(t, err)
+	err = ApplyEnvironment(config)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(config.Database.Replicas))
+	assert.Equal(t, "from-env", config.Database.Replicas[0].Password)
+	assert.Equal(t, "r3plica", config.Database.Replicas[1].Password)
+	assert.Equal(t, "db-3", config.Database.Replicas[1].Server)
+
+	t.Setenv("BLUEBEETLE_DATABASE_REPLICAS_0_PASSWORD_FILE", secret)
+	err = ApplyEnvironment(config)
+	assert.NotNil(t, err)
+}
+
+func TestValidate_ShouldCheckReplicas(t *testing.T) {
+	config := DefaultConfig()
+	config.Database = DBConfig{Type: "postgresql", DSN: "postgres://gorm@db-1/gorm", QueryTimeout: time.Second,
+		Replicas: []ReplicaConfig{{Server: "db-2"}, {DSN: "postgres://gorm@db-3/gorm"}}}
+
+	err := config.Validate()
+	assert.NotNil(t, err)
+	problems := err.(ValidationErrors)
+	assert.Equal(t, 1, len(problems))
+	assert.Equal(t, "database.replicas[0].dsn", problems[0].Path)
+}
+
+func TestValidate_ShouldNotModifyConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Database.Type = "SQLite"
+
+	assert.Nil(t, config.Validate())
+	assert.Equal(t, "SQLite", config.Database.Type)
+}
+
+func TestValidate_ShouldRequireTLSFiles(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.TLS.Enabled = true
+	config.Server.TLS.CertFile = filepath.Join(t.TempDir(), "missing.pem")
+	config.Server.TLS.KeyFile = ""
+
+	err := config.Validate()
+	assert.NotNil(t, err)
+	problems := err.(ValidationErrors)
+	assert.Equal(t, 2, len(problems))
+	assert.Equal(t, "server.tls.cert-file", problems[0].Path)
+	assert.Equal(t, "server.tls.key-file", problems[1].Path)
+
+	config.Server.TLS.KeyFile = filepath.Join(t.TempDir(), "key.pem")
+	config.Server.TLS.SelfSigned = true
+	assert.Nil(t, config.Validate())
+}
+
+func TestValidate_ShouldCheckBadgeRules(t *testing.T) {
+	config, err := ProcessConfigYAML(`
+badges:
+  - key: hundred
+    name: 100 points
+    kind: points
+    threshold: 100
+    bonus: 10
+  - key: hundred
+    name: Verses
+    kind: entries
+    category: Memory verses
+  - key: attendance
+    kind: monthly-attendance
+    category: Games
+  - key: other
+    name: Other
+    kind: streak
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "Memory verses", config.Badges[1].Category)
+
+	problems := config.Validate().(ValidationErrors)
+	var paths []string
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	assert.Equal(t, []string{
+		"badges[1].key",
+		"badges[1].threshold",
+		"badges[2].name",
+		"badges[2].category",
+		"badges[3].kind",
+	}, paths)
+}
+
+func TestValidate_ShouldCheckKioskSettings(t *testing.T) {
+	config, err := ProcessConfigYAML(`
+kiosk:
+  attendance-points: 0
+  early-check-in: -5m
+`)
+	assert.Nil(t, err)
+
+	problems := config.Validate().(ValidationErrors)
+	var paths []string
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	assert.Equal(t, []string{"kiosk.attendance-points", "kiosk.early-check-in"}, paths)
+}
+
+func TestValidate_ShouldCheckPublicURL(t *testing.T) {
+	config := DefaultConfig()
+	config.Server.PublicURL = "https://points.example.org/club"
+	assert.Nil(t, config.Validate())
+
+	for _, raw := range []string{"points.example.org", "ftp://points.example.org", "https://points.example.org/?a=b"} {
+		config.Server.PublicURL = raw
+		problems, ok := config.Validate().(ValidationErrors)
+		assert.True(t, ok, raw)
+		assert.Equal(t, "server.public-url", problems[0].Path, raw)
+	}
+}
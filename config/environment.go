@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to every environment variable that overrides a
+// SysConfig field. The variable name is built from the YAML path of the
+// field, upper cased with "-" replaced by "_", so database.file-path is
+// overridden by BLUEBEETLE_DATABASE_FILE_PATH.
+//
+// Every variable also has a *_FILE variant holding the path of a file whose
+// contents are used as the value (e.g. BLUEBEETLE_DATABASE_PASSWORD_FILE for
+// a mounted secret). Setting both forms of the same variable is an error.
+//
+// The entries of a list of sections are addressed by their index, so the
+// password of the first of database.replicas is overridden by
+// BLUEBEETLE_DATABASE_REPLICAS_0_PASSWORD. Only entries listed in
+// config.yaml can be overridden.
+//
+// Precedence, lowest to highest: DefaultConfig < config.yaml < environment.
+const EnvPrefix = "BLUEBEETLE"
+
+// ApplyEnvironment overrides the fields of config with any matching
+// environment variables.
+func ApplyEnvironment(config *SysConfig) error {
+	return applyEnvironment(reflect.ValueOf(config).Elem(), EnvPrefix)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func envName(prefix string, field reflect.StructField) (string, bool) {
+	tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if tag == "" || tag == "-" {
+		return "", false
+	}
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(tag, "-", "_")), true
+}
+
+func applyEnvironment(value reflect.Value, prefix string) error {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := envName(prefix, t.Field(i))
+		if !ok {
+			continue
+		}
+		field := value.Field(i)
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			err := applyEnvironment(field, name)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < field.Len(); j++ {
+				err := applyEnvironment(field.Index(j), name+"_"+strconv.Itoa(j))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		raw, found, err := lookupEnv(name)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		err = setField(field, raw)
+		if err != nil {
+			return errors.New("environment variable " + name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// lookupEnv returns the value of name, or the contents of the file named by
+// name_FILE.
+func lookupEnv(name string) (string, bool, error) {
+	value, found := os.LookupEnv(name)
+	filePath, fileFound := os.LookupEnv(name + "_FILE")
+	if found && fileFound {
+		return "", false, errors.New("both " + name + " and " + name + "_FILE are set")
+	}
+	if fileFound {
+		contents, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", false, errors.New("environment variable " + name + "_FILE: " + err.Error())
+		}
+		return strings.TrimRight(string(contents), "\r\n"), true, nil
+	}
+	return value, found, nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return errors.New("unsupported list type " + field.Type().String())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return errors.New("unsupported type " + field.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// LiveSettings are the sections of SysConfig that can change while the
+// server is running. Readers must call Live for every use rather than keep
+// the returned pointer, so a reload is picked up on the next request.
+type LiveSettings struct {
+	Logging        LoggingConfig
+	Mail           MailConfig
+	PasswordPolicy PasswordPolicyConfig
+	RateLimit      RateLimitConfig
+	Leaderboard    LeaderboardConfig
+	Badges         []BadgeRule
+	Kiosk          KioskConfig
+}
+
+var live atomic.Pointer[LiveSettings]
+
+// liveSettingsOf picks the reloadable sections out of config. Reload copies
+// them back with applyTo and reloadableSections is derived from
+// LiveSettings, so a new section is only added here and to LiveSettings.
+func liveSettingsOf(config *SysConfig) *LiveSettings {
+	return &LiveSettings{
+		Logging:        config.Logging,
+		Mail:           config.Mail,
+		PasswordPolicy: config.PasswordPolicy,
+		RateLimit:      config.RateLimit,
+		Leaderboard:    config.Leaderboard,
+		Badges:         config.Badges,
+		Kiosk:          config.Kiosk,
+	}
+}
+
+// applyTo copies every section of settings into the SysConfig field of the
+// same name.
+func (settings *LiveSettings) applyTo(config *SysConfig) {
+	from := reflect.ValueOf(settings).Elem()
+	to := reflect.ValueOf(config).Elem()
+	for i := 0; i < from.NumField(); i++ {
+		to.FieldByName(from.Type().Field(i).Name).Set(from.Field(i))
+	}
+}
+
+// reloadableSections are the top level YAML keys of the LiveSettings
+// sections. Changes to any other section only take effect after a restart.
+var reloadableSections = func() map[string]bool {
+	sections := map[string]bool{}
+	configType := reflect.TypeOf(SysConfig{})
+	liveType := reflect.TypeOf(LiveSettings{})
+	for i := 0; i < liveType.NumField(); i++ {
+		field, _ := configType.FieldByName(liveType.Field(i).Name)
+		sections[strings.Split(field.Tag.Get("yaml"), ",")[0]] = true
+	}
+	return sections
+}()
+
+// Live returns the current reloadable settings, or the defaults if SetLive
+// has not been called yet.
+func Live() *LiveSettings {
+	settings := live.Load()
+	if settings == nil {
+		return liveSettingsOf(DefaultConfig())
+	}
+	return settings
+}
+
+// SetLive atomically replaces the reloadable settings with those in config.
+func SetLive(config *SysConfig) {
+	live.Store(liveSettingsOf(config))
+}
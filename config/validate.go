@@ -0,0 +1,334 @@
+package config
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"blue-beetle/logging"
+)
+
+// ValidationProblem is a single invalid setting, identified by its YAML path
+// (e.g. "database.port").
+type ValidationProblem struct {
+	Path    string
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ValidationErrors collects every problem found by Validate so they can be
+// reported together instead of one per run.
+type ValidationErrors []ValidationProblem
+
+func (v ValidationErrors) Error() string {
+	lines := make([]string, 0, len(v))
+	for _, problem := range v {
+		lines = append(lines, problem.String())
+	}
+	return "invalid configuration:\n  " + strings.Join(lines, "\n  ")
+}
+
+func (v *ValidationErrors) add(path string, message string) {
+	*v = append(*v, ValidationProblem{Path: path, Message: message})
+}
+
+func (v ValidationErrors) errorOrNil() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// Validate checks every section of the configuration and returns a
+// ValidationErrors listing all problems, or nil. It never modifies c.
+func (c *SysConfig) Validate() error {
+	var problems ValidationErrors
+	c.Database.validate("database", &problems)
+	c.Server.validate("server", &problems)
+	c.Logging.validate("logging", &problems)
+	c.Mail.validate("mail", &problems)
+	c.PasswordPolicy.validate("password-policy", &problems)
+	c.RateLimit.validate("rate-limit", &problems)
+	c.Points.validate("points", &problems)
+	c.Leaderboard.validate("leaderboard", &problems)
+	validateBadges("badges", c.Badges, &problems)
+	c.Kiosk.validate("kiosk", &problems)
+	return problems.errorOrNil()
+}
+
+// Validate checks only the database section.
+func (c DBConfig) Validate() error {
+	var problems ValidationErrors
+	c.validate("database", &problems)
+	return problems.errorOrNil()
+}
+
+func (c DBConfig) validate(path string, problems *ValidationErrors) {
+	validatePositiveDuration(path+".query-timeout", c.QueryTimeout, problems)
+	c.validatePool(path, problems)
+	dbType := strings.ToLower(c.Type)
+	if dbType == "" {
+		problems.add(path+".type", "no database type defined")
+		return
+	}
+	if dbType == "sqlite" {
+		if len(c.FilePath) == 0 && len(c.DSN) == 0 {
+			problems.add(path+".file-path", "sqlite database types must define a file path or dsn")
+		}
+		if len(c.Replicas) > 0 {
+			problems.add(path+".replicas", "sqlite databases do not support replicas")
+		}
+		return
+	}
+	if dbType != "mysql" && dbType != "postgresql" && dbType != "sql" {
+		problems.add(path+".type", "unsupported database type "+strconv.Quote(c.Type)+", expected sqlite, mysql, postgresql or sql")
+		return
+	}
+	c.validateReplicas(path, problems)
+	if len(c.DSN) > 0 {
+		return
+	}
+	if len(c.Username) == 0 {
+		problems.add(path+".username", "missing username for database")
+	}
+	if len(c.Password) == 0 {
+		problems.add(path+".password", "missing password for database")
+	}
+	if len(c.Server) == 0 {
+		problems.add(path+".server", "missing server for database")
+	}
+	if len(c.Protocol) == 0 && dbType == "mysql" {
+		problems.add(path+".protocol", "missing protocol for database")
+	}
+	validatePort(path+".port", c.Port, problems)
+	if len(c.DBName) == 0 {
+		problems.add(path+".db-name", "missing database name for database")
+	}
+}
+
+func (c DBConfig) validateReplicas(path string, problems *ValidationErrors) {
+	for i, replica := range c.Replicas {
+		replicaPath := path + ".replicas[" + strconv.Itoa(i) + "]"
+		if len(replica.DSN) > 0 {
+			continue
+		}
+		if len(c.DSN) > 0 {
+			problems.add(replicaPath+".dsn", "replicas must define a dsn when the primary does")
+			continue
+		}
+		if len(replica.Server) == 0 {
+			problems.add(replicaPath+".server", "missing server for replica")
+		}
+		if replica.Port != 0 {
+			validatePort(replicaPath+".port", replica.Port, problems)
+		}
+	}
+}
+
+func (c DBConfig) validatePool(path string, problems *ValidationErrors) {
+	if c.MaxOpenConns < 0 {
+		problems.add(path+".max-open-conns", "must not be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		problems.add(path+".max-idle-conns", "must not be negative")
+	} else if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		problems.add(path+".max-idle-conns", "must not exceed max-open-conns")
+	}
+	validateNonNegativeDuration(path+".conn-max-lifetime", c.ConnMaxLifetime, problems)
+	validateNonNegativeDuration(path+".conn-max-idle-time", c.ConnMaxIdleTime, problems)
+	validateNonNegativeDuration(path+".slow-query-threshold", c.SlowQueryThreshold, problems)
+	validateNonNegativeDuration(path+".health-check-interval", c.HealthCheckInterval, problems)
+}
+
+func (c ServerConfig) validate(path string, problems *ValidationErrors) {
+	validatePort(path+".port", c.Port, problems)
+	c.TLS.validate(path+".tls", c.Port, problems)
+	validatePositiveDuration(path+".read-timeout", c.ReadTimeout, problems)
+	validatePositiveDuration(path+".read-header-timeout", c.ReadHeaderTimeout, problems)
+	validatePositiveDuration(path+".write-timeout", c.WriteTimeout, problems)
+	validatePositiveDuration(path+".idle-timeout", c.IdleTimeout, problems)
+	validatePositiveDuration(path+".shutdown-timeout", c.ShutdownTimeout, problems)
+	if c.MaxHeaderBytes <= 0 {
+		problems.add(path+".max-header-bytes", "must be positive")
+	}
+	if c.PublicURL != "" {
+		validatePublicURL(path+".public-url", c.PublicURL, problems)
+	}
+}
+
+// validatePublicURL checks raw is an absolute http or https URL links can
+// be appended to.
+func validatePublicURL(path string, raw string, problems *ValidationErrors) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		problems.add(path, "invalid URL: "+err.Error())
+		return
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems.add(path, "must be an absolute http or https URL")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		problems.add(path, "must not have a query or fragment")
+	}
+}
+
+func (c TLSConfig) validate(path string, serverPort int, problems *ValidationErrors) {
+	if !c.Enabled {
+		return
+	}
+	validateTLSFile(path+".cert-file", c.CertFile, c.SelfSigned, problems)
+	validateTLSFile(path+".key-file", c.KeyFile, c.SelfSigned, problems)
+	if c.MinVersion != "1.2" && c.MinVersion != "1.3" {
+		problems.add(path+".min-version", "unsupported TLS version "+strconv.Quote(c.MinVersion)+", expected 1.2 or 1.3")
+	}
+	if c.CipherPolicy != "modern" && c.CipherPolicy != "intermediate" {
+		problems.add(path+".cipher-policy", "unsupported cipher policy "+strconv.Quote(c.CipherPolicy)+", expected modern or intermediate")
+	}
+	if c.RedirectPort != 0 {
+		validatePort(path+".redirect-port", c.RedirectPort, problems)
+		if c.RedirectPort == serverPort {
+			problems.add(path+".redirect-port", "must differ from server.port")
+		}
+	}
+	if c.HSTSMaxAge < 0 {
+		problems.add(path+".hsts-max-age", "must not be negative")
+	}
+}
+
+// validateTLSFile checks a certificate or key file exists. With self-signed
+// certificates a missing file is fine as long as it can be created.
+func validateTLSFile(path string, file string, selfSigned bool, problems *ValidationErrors) {
+	if len(file) == 0 {
+		problems.add(path, "missing file path while TLS is enabled")
+		return
+	}
+	_, err := os.Stat(file)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		problems.add(path, err.Error())
+		return
+	}
+	if !selfSigned {
+		problems.add(path, "file "+strconv.Quote(file)+" does not exist")
+	}
+}
+
+func (c LoggingConfig) validate(path string, problems *ValidationErrors) {
+	_, err := logging.ParseLevel(c.Level)
+	if err != nil {
+		problems.add(path+".level", err.Error())
+	}
+}
+
+func (c MailConfig) validate(path string, problems *ValidationErrors) {
+	if !c.Enabled {
+		return
+	}
+	if len(c.Host) == 0 {
+		problems.add(path+".host", "missing SMTP host while mail is enabled")
+	}
+	validatePort(path+".port", c.Port, problems)
+	if len(c.From) == 0 {
+		problems.add(path+".from", "missing sender address while mail is enabled")
+	} else if _, err := mail.ParseAddress(c.From); err != nil {
+		problems.add(path+".from", "invalid sender address: "+err.Error())
+	}
+	if len(c.Username) == 0 && len(c.Password) > 0 {
+		problems.add(path+".username", "password is set without a username")
+	}
+	if len(c.Username) > 0 && len(c.Password) == 0 {
+		problems.add(path+".password", "username is set without a password")
+	}
+}
+
+func (c PasswordPolicyConfig) validate(path string, problems *ValidationErrors) {
+	// bcrypt only uses the first 72 bytes of a password.
+	if c.MinLength < 1 || c.MinLength > 72 {
+		problems.add(path+".min-length", "minimum length "+strconv.Itoa(c.MinLength)+" must be between 1 and 72")
+	}
+}
+
+func (c RateLimitConfig) validate(path string, problems *ValidationErrors) {
+	if c.MaxLoginAttempts == 0 {
+		problems.add(path+".max-login-attempts", "must be at least 1")
+	}
+}
+
+func (c PointsConfig) validate(path string, problems *ValidationErrors) {
+	if c.CarryOverPercent < 0 || c.CarryOverPercent > 100 {
+		problems.add(path+".carry-over-percent", "must be between 0 and 100")
+	}
+}
+
+func (c LeaderboardConfig) validate(path string, problems *ValidationErrors) {
+	if c.Size < 1 || c.Size > 100 {
+		problems.add(path+".size", "size "+strconv.Itoa(c.Size)+" must be between 1 and 100")
+	}
+}
+
+func (c KioskConfig) validate(path string, problems *ValidationErrors) {
+	if c.AttendancePoints < 1 {
+		problems.add(path+".attendance-points", "must be at least 1")
+	}
+	if c.EarlyCheckIn < 0 {
+		problems.add(path+".early-check-in", "must not be negative")
+	}
+}
+
+func validateBadges(path string, rules []BadgeRule, problems *ValidationErrors) {
+	keys := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		rulePath := path + "[" + strconv.Itoa(i) + "]"
+		if len(rule.Key) == 0 {
+			problems.add(rulePath+".key", "missing badge key")
+		} else if keys[rule.Key] {
+			problems.add(rulePath+".key", "duplicate badge key "+strconv.Quote(rule.Key))
+		}
+		keys[rule.Key] = true
+		if len(rule.Name) == 0 {
+			problems.add(rulePath+".name", "missing badge name")
+		}
+		switch rule.Kind {
+		case BadgePoints, BadgeEntries:
+			if rule.Threshold < 1 {
+				problems.add(rulePath+".threshold", "must be at least 1")
+			}
+		case BadgeMonthlyAttendance:
+			if len(rule.Category) > 0 {
+				problems.add(rulePath+".category", "attendance badges do not count a category")
+			}
+		default:
+			problems.add(rulePath+".kind", "unsupported badge kind "+strconv.Quote(rule.Kind)+", expected points, entries or monthly-attendance")
+		}
+		if rule.Bonus < 0 {
+			problems.add(rulePath+".bonus", "must not be negative")
+		}
+	}
+}
+
+func validatePositiveDuration(path string, duration time.Duration, problems *ValidationErrors) {
+	if duration <= 0 {
+		problems.add(path, "duration "+duration.String()+" must be positive")
+	}
+}
+
+func validateNonNegativeDuration(path string, duration time.Duration, problems *ValidationErrors) {
+	if duration < 0 {
+		problems.add(path, "duration "+duration.String()+" must not be negative")
+	}
+}
+
+func validatePort(path string, port int, problems *ValidationErrors) {
+	if port < 1 || port > 65535 {
+		problems.add(path, "port "+strconv.Itoa(port)+" must be between 1 and 65535")
+	}
+}
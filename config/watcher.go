@@ -0,0 +1,180 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"blue-beetle/logging"
+)
+
+// WatchInterval is how often the config file is checked for changes.
+const WatchInterval = 5 * time.Second
+
+// SettingChange is a single setting that differs between two configs.
+type SettingChange struct {
+	Path            string
+	RequiresRestart bool
+}
+
+// Watcher reloads the config file on SIGHUP or when the file changes,
+// validates it and swaps in the new reloadable settings.
+type Watcher struct {
+	path      string
+	overrides func(*SysConfig)
+	onReload  []func(*SysConfig)
+
+	mu      sync.Mutex
+	current *SysConfig
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher returns a watcher for the file at path. current is the config
+// the server started with; overrides, if not nil, is applied to every
+// reloaded config so command-line flags keep precedence.
+func NewWatcher(path string, current *SysConfig, overrides func(*SysConfig)) *Watcher {
+	w := &Watcher{path: path, current: current, overrides: overrides}
+	w.modTime, w.size = w.stat()
+	return w
+}
+
+// OnReload registers a callback run after every successful reload with the
+// config now in effect.
+func (w *Watcher) OnReload(callback func(*SysConfig)) {
+	w.onReload = append(w.onReload, callback)
+}
+
+// Current returns the config in effect, which keeps the startup values for
+// sections that require a restart.
+func (w *Watcher) Current() *SysConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Run watches for SIGHUP and file changes until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			logging.Infof("Received SIGHUP, reloading %s", w.path)
+			w.reloadAndLog()
+		case <-ticker.C:
+			if w.fileChanged() {
+				logging.Infof("Config file %s changed, reloading", w.path)
+				w.reloadAndLog()
+			}
+		}
+	}
+}
+
+func (w *Watcher) reloadAndLog() {
+	_, err := w.Reload()
+	if err != nil {
+		logging.Errorf("Config reload failed, keeping the current settings: %v", err)
+	}
+}
+
+// Reload reads and validates the config file. If it is valid the reloadable
+// sections are swapped in and every change is logged. It returns the
+// settings that changed, including those that require a restart.
+func (w *Watcher) Reload() ([]SettingChange, error) {
+	w.mu.Lock()
+	w.modTime, w.size = w.stat()
+	next, err := LoadConfig(w.path)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	if w.overrides != nil {
+		w.overrides(next)
+	}
+	err = next.Validate()
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+
+	changes := Diff(w.current, next)
+	applied := *w.current
+	liveSettingsOf(next).applyTo(&applied)
+	w.current = &applied
+	SetLive(&applied)
+	w.mu.Unlock()
+
+	for _, change := range changes {
+		if change.RequiresRestart {
+			logging.Warnf("Setting %s changed but requires a restart to take effect", change.Path)
+		} else {
+			logging.Infof("Setting %s changed", change.Path)
+		}
+	}
+	if len(changes) == 0 {
+		logging.Infof("Config reloaded, no settings changed")
+	}
+	for _, callback := range w.onReload {
+		callback(&applied)
+	}
+	return changes, nil
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+func (w *Watcher) fileChanged() bool {
+	modTime, size := w.stat()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !modTime.IsZero() && (!modTime.Equal(w.modTime) || size != w.size)
+}
+
+// Diff lists the YAML paths of every setting that differs between old and
+// next. Values are not included so secrets never reach the logs.
+func Diff(old *SysConfig, next *SysConfig) []SettingChange {
+	var changes []SettingChange
+	diffValues(reflect.ValueOf(*old), reflect.ValueOf(*next), "", &changes)
+	return changes
+}
+
+func diffValues(old reflect.Value, next reflect.Value, path string, changes *[]SettingChange) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		oldField := old.Field(i)
+		nextField := next.Field(i)
+		if oldField.Kind() == reflect.Struct && oldField.Type() != durationType {
+			diffValues(oldField, nextField, fieldPath, changes)
+			continue
+		}
+		if reflect.DeepEqual(oldField.Interface(), nextField.Interface()) {
+			continue
+		}
+		section := strings.Split(fieldPath, ".")[0]
+		*changes = append(*changes, SettingChange{Path: fieldPath, RequiresRestart: !reloadableSections[section]})
+	}
+}
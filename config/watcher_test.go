@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, path string, yamlData string) {
+	err := os.WriteFile(path, []byte(yamlData), 0600)
+	assert.Nil(t, err)
+}
+
+func TestWatcherReload_ShouldSwapReloadableSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "server:\n  port: 8080\nlogging:\n  level: info\n")
+	current, err := LoadConfig(path)
+	assert.Nil(t, err)
+	SetLive(current)
+
+	writeConfigFile(t, path, "server:\n  port: 9090\nlogging:\n  level: debug\nrate-limit:\n  max-login-attempts: 3\nleaderboard:\n  size: 5\n")
+	watcher := NewWatcher(path, current, nil)
+	var reloaded *SysConfig
+	watcher.OnReload(func(c *SysConfig) { reloaded = c })
+
+	changes, err := watcher.Reload()
+	assert.Nil(t, err)
+	assert.Equal(t, []SettingChange{
+		{Path: "server.port", RequiresRestart: true},
+		{Path: "logging.level", RequiresRestart: false},
+		{Path: "rate-limit.max-login-attempts", RequiresRestart: false},
+		{Path: "leaderboard.size", RequiresRestart: false},
+	}, changes)
+	assert.Equal(t, "debug", Live().Logging.Level)
+	assert.Equal(t, uint(3), Live().RateLimit.MaxLoginAttempts)
+	assert.Equal(t, 5, Live().Leaderboard.Size)
+	assert.Equal(t, 8080, watcher.Current().Server.Port)
+	assert.Equal(t, watcher.Current(), reloaded)
+}
+
+func TestWatcherReload_ShouldKeepSettingsWhenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "logging:\n  level: info\n")
+	current, err := LoadConfig(path)
+	assert.Nil(t, err)
+	SetLive(current)
+
+	writeConfigFile(t, path, "logging:\n  level: loud\n")
+	watcher := NewWatcher(path, current, nil)
+
+	_, err = watcher.Reload()
+	assert.NotNil(t, err)
+	assert.Equal(t, "info", Live().Logging.Level)
+	assert.Equal(t, current, watcher.Current())
+}
+
+func TestLiveSettings_ShouldDeriveReloadableSections(t *testing.T) {
+	assert.Equal(t, map[string]bool{
+		"logging":         true,
+		"mail":            true,
+		"password-policy": true,
+		"rate-limit":      true,
+		"leaderboard":     true,
+		"badges":          true,
+		"kiosk":           true,
+	}, reloadableSections)
+
+	next := DefaultConfig()
+	next.Server.Port = 9090
+	next.Kiosk.AttendancePoints = 7
+	next.Badges = []BadgeRule{{Key: "first", Name: "First", Kind: BadgePoints, Threshold: 1}}
+	applied := *DefaultConfig()
+	liveSettingsOf(next).applyTo(&applied)
+	assert.Equal(t, liveSettingsOf(next), liveSettingsOf(&applied))
+	assert.Equal(t, 8080, applied.Server.Port)
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AwardBatch groups the ledger entries of one bulk award, such as the
+// points for everyone at an event, so they can be reversed together.
+// EventID and TeamID record what the batch was awarded for and may be
+// empty. A reversed batch has ReversedAt set and a reversal entry for each
+// of its entries.
+type AwardBatch struct {
+	ID         string `gorm:"primaryKey"`
+	SeasonID   string `gorm:"not null;index"`
+	EventID    string `gorm:"index"`
+	TeamID     string
+	Reason     string `gorm:"size:255"`
+	CreatedBy  string `gorm:"size:255"`
+	ReversedAt *time.Time
+	ReversedBy string `gorm:"size:255"`
+	CreatedAt  time.Time
+}
+
+func (batch *AwardBatch) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	batch.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", batch.ID)
+	return
+}
+
+func (r *UserRepository) SaveAwardBatch(ctx context.Context, batch AwardBatch) (AwardBatch, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if batch.ID == "" {
+		err = db.Create(&batch).Error
+	} else {
+		err = db.Save(&batch).Error
+	}
+	return batch, err
+}
+
+// MarkBatchReversed records the reversal of a batch unless it was already
+// reversed, in which case ErrConflict is returned.
+func (r *UserRepository) MarkBatchReversed(ctx context.Context, id string, reversedBy string, at time.Time) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Model(&AwardBatch{}).
+		Where("id = ? AND reversed_at IS NULL", id).
+		Updates(map[string]interface{}{"reversed_at": at, "reversed_by": reversedBy})
+	if record.Error != nil {
+		return record.Error
+	}
+	if record.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *UserRepository) LoadAwardBatch(ctx context.Context, id string) (AwardBatch, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var batch AwardBatch
+	err := db.Where("id = ?", id).First(&batch).Error
+	return batch, err
+}
+
+// AwardBatches returns the latest batches of a season, newest first.
+func (r *UserRepository) AwardBatches(ctx context.Context, seasonID string, limit int) ([]AwardBatch, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var batches []AwardBatch
+	err := db.Where("season_id = ?", seasonID).Order("created_at DESC").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// BatchEntries returns the ledger entries of a batch, reversals included.
+func (r *UserRepository) BatchEntries(ctx context.Context, batchID string) ([]PointEntry, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var entries []PointEntry
+	err := db.Where("batch_id = ?", batchID).Order("created_at").Find(&entries).Error
+	return entries, err
+}
+
+// AttendedEvents returns the IDs of the events starting in [from, to) the
+// participant earned points at through a batch that was not reversed.
+func (r *UserRepository) AttendedEvents(ctx context.Context, participantID string, from time.Time, to time.Time) ([]string, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var eventIDs []string
+	err := db.Model(&AwardBatch{}).
+		Distinct("award_batches.event_id").
+		Joins("JOIN events ON events.id = award_batches.event_id").
+		Joins("JOIN point_entries ON point_entries.batch_id = award_batches.id").
+		Where("award_batches.reversed_at IS NULL AND point_entries.participant_id = ? AND point_entries.points > 0", participantID).
+		Where("events.deleted_at IS NULL AND events.starts_at >= ? AND events.starts_at < ?", from, to).
+		Pluck("award_batches.event_id", &eventIDs).Error
+	return eventIDs, err
+}
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// AwardService awards points to many participants at once, such as
+// everyone at an event, as one batch that can be reversed as a whole.
+// Awarding and reversing need ADD_POINTS_WRITE, listing ADD_POINTS_READ.
+type AwardService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewAwardService(stores Stores, tx TxRunner) *AwardService {
+	return &AwardService{stores: stores, tx: tx}
+}
+
+// AwardLine is the points one participant earns in one category of a bulk
+// award, an empty CategoryID leaves them uncategorized.
+type AwardLine struct {
+	ParticipantID string
+	CategoryID    string
+	Points        int64
+}
+
+// BulkAward is what a batch awards. EventID and TeamID record the event or
+// team the participants were picked from and may be empty. Reason defaults
+// to the event's name.
+type BulkAward struct {
+	EventID string
+	TeamID  string
+	Reason  string
+	Lines   []AwardLine
+}
+
+// maxRecentBatches bounds the batches Batches returns.
+const maxRecentBatches = 20
+
+// Roster returns the participants a bulk award picks from: the current
+// members of the team, or everyone when teamID is empty.
+func (s *AwardService) Roster(ctx context.Context, actor User, teamID string) ([]Participant, error) {
+	err := requirePermission(actor, ADD_POINTS_READ)
+	if err != nil {
+		return nil, err
+	}
+	if teamID == "" {
+		return s.stores.Points.Participants(ctx)
+	}
+	memberships, err := s.stores.Teams.Memberships(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	var participants []Participant
+	for _, membership := range memberships {
+		if membership.LeftAt != nil {
+			continue
+		}
+		participant, err := s.stores.Points.LoadParticipant(ctx, membership.ParticipantID)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	sortParticipants(participants)
+	return participants, nil
+}
+
+// Batches returns the latest batches of the open season, newest first.
+func (s *AwardService) Batches(ctx context.Context, actor User) ([]AwardBatch, error) {
+	err := requirePermission(actor, ADD_POINTS_READ)
+	if err != nil {
+		return nil, err
+	}
+	season, err := s.stores.Seasons.CurrentSeason(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.stores.Awards.AwardBatches(ctx, season.ID, maxRecentBatches)
+}
+
+// Award writes every line of the award to the ledger of the open season in
+// one transaction, so either the whole batch is recorded or nothing.
+func (s *AwardService) Award(ctx context.Context, actor User, award BulkAward) (AwardBatch, error) {
+	err := requirePermission(actor, ADD_POINTS_WRITE)
+	if err != nil {
+		return AwardBatch{}, err
+	}
+	err = validateBulkAward(award)
+	if err != nil {
+		return AwardBatch{}, err
+	}
+	var batch AwardBatch
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		var err error
+		batch, err = awardWithin(ctx, tx, actor, award)
+		return err
+	})
+	return batch, err
+}
+
+// awardWithin records a validated bulk award as one batch in tx.
+func awardWithin(ctx context.Context, tx Stores, actor User, award BulkAward) (AwardBatch, error) {
+	reason := strings.TrimSpace(award.Reason)
+	if award.EventID != "" {
+		event, err := tx.Events.LoadEvent(ctx, award.EventID)
+		if err != nil {
+			return AwardBatch{}, err
+		}
+		if reason == "" {
+			reason = event.Name
+		}
+	}
+	if award.TeamID != "" {
+		_, err := tx.Teams.LoadTeam(ctx, award.TeamID)
+		if err != nil {
+			return AwardBatch{}, err
+		}
+	}
+	if reason == "" {
+		return AwardBatch{}, ValidationErrorNew("reason", "a reason or an event is required", EMPTY_VALUE_CODE)
+	}
+	season, err := tx.Seasons.CurrentSeason(ctx)
+	if err != nil {
+		return AwardBatch{}, err
+	}
+	batch, err := tx.Awards.SaveAwardBatch(ctx, AwardBatch{
+		SeasonID:  season.ID,
+		EventID:   award.EventID,
+		TeamID:    award.TeamID,
+		Reason:    reason,
+		CreatedBy: actor.Username,
+	})
+	if err != nil {
+		return AwardBatch{}, err
+	}
+	for _, line := range award.Lines {
+		_, err = tx.Points.LoadParticipant(ctx, line.ParticipantID)
+		if err != nil {
+			return AwardBatch{}, err
+		}
+		if line.CategoryID != "" {
+			_, err = tx.Categories.LoadCategory(ctx, line.CategoryID)
+			if err != nil {
+				return AwardBatch{}, err
+			}
+		}
+		_, err = recordPoints(ctx, tx, PointEntry{
+			SeasonID:      season.ID,
+			ParticipantID: line.ParticipantID,
+			CategoryID:    line.CategoryID,
+			BatchID:       batch.ID,
+			Points:        line.Points,
+			Reason:        reason,
+		})
+		if err != nil {
+			return AwardBatch{}, err
+		}
+	}
+	return batch, nil
+}
+
+func validateBulkAward(award BulkAward) error {
+	if len(award.Lines) == 0 {
+		return ValidationErrorNew("lines", "pick at least one participant", EMPTY_VALUE_CODE)
+	}
+	seen := make(map[AwardLine]bool, len(award.Lines))
+	for _, line := range award.Lines {
+		err := validatePoints(line.Points)
+		if err != nil {
+			return err
+		}
+		key := AwardLine{ParticipantID: line.ParticipantID, CategoryID: line.CategoryID}
+		if seen[key] {
+			return ValidationErrorNew("lines", "a participant is listed twice in the same category", DUPLICATE_VALUE_CODE)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Reverse takes back every point of the batch by appending a reversal entry
+// for each of its entries, leaving the original entries in the ledger. The
+// reversals lower the participants' earned points and may leave a balance
+// below zero when the points were already spent. Only batches of the open
+// season can be reversed, others return ErrSeasonClosed.
+func (s *AwardService) Reverse(ctx context.Context, actor User, batchID string) (AwardBatch, error) {
+	err := requirePermission(actor, ADD_POINTS_WRITE)
+	if err != nil {
+		return AwardBatch{}, err
+	}
+	var batch AwardBatch
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		var err error
+		batch, err = tx.Awards.LoadAwardBatch(ctx, batchID)
+		if err != nil {
+			return err
+		}
+		season, err := tx.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return err
+		}
+		if batch.SeasonID != season.ID {
+			return ErrSeasonClosed
+		}
+		// Marking the batch first lets only one of two concurrent reversals
+		// append the reversal entries.
+		now := time.Now()
+		err = tx.Awards.MarkBatchReversed(ctx, batch.ID, actor.Username, now)
+		if errors.Is(err, ErrConflict) {
+			return ValidationErrorNew("batch", "the batch was already reversed", INVALID_VALUE_CODE)
+		} else if err != nil {
+			return err
+		}
+		batch.ReversedAt = &now
+		batch.ReversedBy = actor.Username
+		entries, err := tx.Awards.BatchEntries(ctx, batch.ID)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			_, err = recordPoints(ctx, tx, PointEntry{
+				SeasonID:      batch.SeasonID,
+				ParticipantID: entry.ParticipantID,
+				CategoryID:    entry.CategoryID,
+				BatchID:       batch.ID,
+				Points:        -entry.Points,
+				Reason:        "Reversed: " + entry.Reason,
+				Reversal:      true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return batch, err
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var eventLeader = User{Username: "leader", Role: Role{Permissions: ADD_POINTS_READ | ADD_POINTS_WRITE | EVENT_WRITE | PARTICIPENT_WRITE}}
+
+func TestAwardService_ShouldAwardAndReverseBatch(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		teams := NewTeamService(stores, tx)
+		events := NewEventService(stores, tx)
+		awards := NewAwardService(stores, tx)
+		leaderboards := NewLeaderboardService(stores, tx)
+		start := time.Now().Add(-time.Hour)
+		event, err := events.CreateEvent(ctx, eventLeader, "Camp day", start, start.Add(3*time.Hour))
+		assert.Nil(t, err)
+		red, err := teams.CreateTeam(ctx, eventLeader, "Red", "#ff0000")
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		bob, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Bob", LastName: "Builder"})
+		assert.Nil(t, err)
+		_, err = teams.MoveParticipant(ctx, eventLeader, ada.ID, red.ID)
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 2, "earlier")
+		assert.Nil(t, err)
+
+		roster, err := awards.Roster(ctx, eventLeader, red.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{ada.ID}, []string{roster[0].ID})
+		roster, err = awards.Roster(ctx, eventLeader, "")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(roster))
+		assert.Equal(t, bob.ID, roster[0].ID)
+
+		batch, err := awards.Award(ctx, eventLeader, BulkAward{EventID: event.ID, Lines: []AwardLine{
+			{ParticipantID: ada.ID, Points: 5},
+			{ParticipantID: bob.ID, Points: 3},
+		}})
+		assert.Nil(t, err)
+		assert.Equal(t, "Camp day", batch.Reason)
+		assert.Equal(t, "leader", batch.CreatedBy)
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), balance.Balance)
+		total, err := teams.TeamTotal(ctx, red.ID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), total.Total)
+
+		reversed, err := awards.Reverse(ctx, eventLeader, batch.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, reversed.ReversedAt)
+		assert.Equal(t, "leader", reversed.ReversedBy)
+		balance, err = points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), balance.Earned)
+		assert.Equal(t, int64(0), balance.Spent)
+		assert.Equal(t, int64(2), balance.Balance)
+		total, err = teams.TeamTotal(ctx, red.ID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), total.Total)
+		rows, err := leaderboards.Participants(ctx, LeaderboardQuery{})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(rows))
+		assert.Equal(t, int64(2), rows[0].Points)
+
+		drift, err := points.Reconcile(ctx, false)
+		assert.Nil(t, err)
+		assert.Empty(t, drift)
+
+		_, err = awards.Reverse(ctx, eventLeader, batch.ID)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.ErrorIs(t, stores.Awards.MarkBatchReversed(ctx, batch.ID, "late", time.Now()), ErrConflict)
+		batches, err := awards.Batches(ctx, eventLeader)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(batches))
+		assert.NotNil(t, batches[0].ReversedAt)
+	})
+}
+
+func TestAwardService_ShouldRecordNothingWhenALineFails(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		awards := NewAwardService(stores, tx)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+
+		_, err = awards.Award(ctx, eventLeader, BulkAward{Reason: "game", Lines: []AwardLine{
+			{ParticipantID: ada.ID, Points: 5},
+			{ParticipantID: "missing", Points: 5},
+		}})
+		assert.True(t, errors.Is(err, ErrNotFound))
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), balance.Balance)
+		batches, err := awards.Batches(ctx, eventLeader)
+		assert.Nil(t, err)
+		assert.Empty(t, batches)
+
+		_, err = awards.Award(ctx, eventLeader, BulkAward{Lines: []AwardLine{{ParticipantID: ada.ID, Points: 5}}})
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		_, err = awards.Award(ctx, eventLeader, BulkAward{Reason: "game", Lines: []AwardLine{
+			{ParticipantID: ada.ID, Points: 5},
+			{ParticipantID: ada.ID, Points: 1},
+		}})
+		assert.True(t, errors.As(err, &validationErr))
+		_, err = awards.Award(ctx, User{Role: Role{Permissions: ADD_POINTS_READ}}, BulkAward{Reason: "game", Lines: []AwardLine{{ParticipantID: ada.ID, Points: 5}}})
+		assert.Equal(t, ErrPermissionDenied, err)
+	})
+}
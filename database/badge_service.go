@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"blue-beetle/config"
+	"blue-beetle/logging"
+)
+
+// BadgeService awards badges for the milestone rules of the badges config
+// section. Rules are checked by every ledger write that earns points, in
+// the same transaction (see recordPoints), so there is no separate job to
+// run. Badges are kept when the points behind them are spent or reversed.
+type BadgeService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewBadgeService(stores Stores, tx TxRunner) *BadgeService {
+	return &BadgeService{stores: stores, tx: tx}
+}
+
+// Badges returns the participant's badges, oldest first.
+func (s *BadgeService) Badges(ctx context.Context, participantID string) ([]Badge, error) {
+	return s.stores.Badges.Badges(ctx, participantID)
+}
+
+// Evaluate checks every rule for the participant in the open season, for
+// instance after a rule was added, and returns the badges it awarded.
+func (s *BadgeService) Evaluate(ctx context.Context, actor User, participantID string) ([]Badge, error) {
+	err := requirePermission(actor, ADD_POINTS_WRITE)
+	if err != nil {
+		return nil, err
+	}
+	var awarded []Badge
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		_, err := tx.Points.LoadParticipant(ctx, participantID)
+		if err != nil {
+			return err
+		}
+		season, err := tx.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return err
+		}
+		awarded, err = awardBadges(ctx, tx, participantID, season.ID)
+		return err
+	})
+	return awarded, err
+}
+
+// EvaluateAll runs Evaluate for every participant and returns how many
+// badges were awarded. Each participant is evaluated in a transaction of
+// their own.
+func (s *BadgeService) EvaluateAll(ctx context.Context, actor User) (int, error) {
+	err := requirePermission(actor, ADD_POINTS_WRITE)
+	if err != nil {
+		return 0, err
+	}
+	participants, err := s.stores.Points.Participants(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, participant := range participants {
+		awarded, err := s.Evaluate(ctx, actor, participant.ID)
+		if err != nil {
+			return count, err
+		}
+		count += len(awarded)
+	}
+	return count, nil
+}
+
+// recordPoints appends entry to the ledger with the stores of a running
+// transaction and, when it earns points, awards the badges it reaches.
+// Every ledger write of the services goes through it, except spends which
+// never reach a badge.
+func recordPoints(ctx context.Context, tx Stores, entry PointEntry) (PointEntry, error) {
+	entry, err := tx.Points.RecordPoints(ctx, entry)
+	if err != nil || entry.Points <= 0 || entry.CarryOver || entry.Reversal {
+		return entry, err
+	}
+	_, err = awardBadges(ctx, tx, entry.ParticipantID, entry.SeasonID)
+	return entry, err
+}
+
+// awardBadges awards the participant every badge reached but not yet
+// received in the season. Bonus points may reach further badges, so the
+// rules are checked again until a pass awards nothing; each badge is only
+// awarded once per period, which bounds the passes.
+func awardBadges(ctx context.Context, tx Stores, participantID string, seasonID string) ([]Badge, error) {
+	rules := config.Live().Badges
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	categories, err := categoryIDs(ctx, tx, rules)
+	if err != nil {
+		return nil, err
+	}
+	var awarded []Badge
+	for {
+		bonus := false
+		for _, rule := range rules {
+			now := time.Now()
+			period := badgePeriod(rule, now)
+			has, err := tx.Badges.HasBadge(ctx, participantID, rule.Key, seasonID, period)
+			if err != nil {
+				return awarded, err
+			}
+			if has {
+				continue
+			}
+			met, err := ruleMet(ctx, tx, rule, categories, participantID, seasonID, now)
+			if err != nil {
+				return awarded, err
+			}
+			if !met {
+				continue
+			}
+			// The badge is saved before its bonus: when a concurrent
+			// evaluation awarded it first, nothing is inserted and the
+			// bonus is not granted twice.
+			badge, inserted, err := tx.Badges.SaveBadge(ctx, Badge{ParticipantID: participantID, RuleKey: rule.Key, SeasonID: seasonID, Period: period, Name: rule.Name, AwardedAt: now})
+			if err != nil {
+				return awarded, err
+			}
+			if !inserted {
+				continue
+			}
+			if rule.Bonus > 0 {
+				entry, err := tx.Points.RecordPoints(ctx, PointEntry{SeasonID: seasonID, ParticipantID: participantID, Points: rule.Bonus, Reason: "Badge: " + rule.Name})
+				if err != nil {
+					return awarded, err
+				}
+				err = tx.Badges.SetBadgeEntry(ctx, badge.ID, entry.ID)
+				if err != nil {
+					return awarded, err
+				}
+				badge.PointEntryID = entry.ID
+				bonus = true
+			}
+			awarded = append(awarded, badge)
+		}
+		if !bonus {
+			return awarded, nil
+		}
+	}
+}
+
+// categoryIDs maps the category names the rules count to their IDs. Rules
+// naming a category that does not exist are never met.
+func categoryIDs(ctx context.Context, tx Stores, rules []config.BadgeRule) (map[string]string, error) {
+	var ids map[string]string
+	for _, rule := range rules {
+		if rule.Category == "" {
+			continue
+		}
+		if ids == nil {
+			ids = make(map[string]string)
+			categories, err := tx.Categories.Categories(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, category := range categories {
+				ids[category.Name] = category.ID
+			}
+		}
+		if _, ok := ids[rule.Category]; !ok {
+			logging.Warnf("Badge %s counts the unknown category %q", rule.Key, rule.Category)
+		}
+	}
+	return ids, nil
+}
+
+// badgePeriod is the period a badge is awarded once in at now.
+func badgePeriod(rule config.BadgeRule, now time.Time) string {
+	if rule.Kind == config.BadgeMonthlyAttendance {
+		return now.Format("2006-01")
+	}
+	return ""
+}
+
+// ruleMet reports whether the participant reached the rule's milestone at
+// now. Attendance counts the events of now's month, so attendance awarded
+// after the month ended does not count towards it.
+func ruleMet(ctx context.Context, tx Stores, rule config.BadgeRule, categories map[string]string, participantID string, seasonID string, now time.Time) (bool, error) {
+	switch rule.Kind {
+	case config.BadgePoints, config.BadgeEntries:
+		filter := LeaderboardFilter{SeasonID: seasonID}
+		if rule.Category != "" {
+			id, ok := categories[rule.Category]
+			if !ok {
+				return false, nil
+			}
+			filter.CategoryID = id
+		}
+		progress, err := tx.Badges.Progress(ctx, filter, participantID)
+		if err != nil {
+			return false, err
+		}
+		if rule.Kind == config.BadgePoints {
+			return progress.Points >= rule.Threshold, nil
+		}
+		return progress.Entries >= rule.Threshold, nil
+	case config.BadgeMonthlyAttendance:
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		to := from.AddDate(0, 1, 0)
+		events, err := tx.Events.Events(ctx, from, to)
+		if err != nil {
+			return false, err
+		}
+		attendedIDs, err := tx.Awards.AttendedEvents(ctx, participantID, from, to)
+		if err != nil {
+			return false, err
+		}
+		attended := make(map[string]bool, len(attendedIDs))
+		for _, id := range attendedIDs {
+			attended[id] = true
+		}
+		held := 0
+		for _, event := range events {
+			if event.StartsAt.Before(from) {
+				// Started in the previous month.
+				continue
+			}
+			if !attended[event.ID] {
+				return false, nil
+			}
+			held++
+		}
+		return held > 0, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"blue-beetle/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useBadgeRules makes rules the live badge rules for the rest of the test.
+func useBadgeRules(t *testing.T, rules []config.BadgeRule) {
+	settings := config.DefaultConfig()
+	settings.Badges = rules
+	config.SetLive(settings)
+	t.Cleanup(func() { config.SetLive(config.DefaultConfig()) })
+}
+
+func badgeKeys(badges []Badge) []string {
+	keys := make([]string, len(badges))
+	for i, badge := range badges {
+		keys[i] = badge.RuleKey
+	}
+	return keys
+}
+
+func TestBadgeService_ShouldAwardMilestonesOnce(t *testing.T) {
+	ctx := context.Background()
+	rules := []config.BadgeRule{
+		{Key: "hundred", Name: "100 points", Kind: config.BadgePoints, Threshold: 100},
+		{Key: "verses", Name: "3 memory verses", Kind: config.BadgeEntries, Category: "Memory verses", Threshold: 3, Bonus: 90},
+	}
+	useBadgeRules(t, rules)
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		categories := NewCategoryService(stores, tx)
+		badges := NewBadgeService(stores, tx)
+		verses, err := categories.CreateCategory(ctx, SystemUser, "Memory verses")
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = points.AwardCategoryPoints(ctx, ada.ID, verses.ID, 5, "verse")
+			assert.Nil(t, err)
+		}
+		earned, err := badges.Badges(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, earned)
+
+		// The third verse earns its badge, whose bonus reaches 100 points.
+		_, err = points.AwardCategoryPoints(ctx, ada.ID, verses.ID, 5, "verse")
+		assert.Nil(t, err)
+		earned, err = badges.Badges(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"verses", "hundred"}, badgeKeys(earned))
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(105), balance.Balance)
+
+		_, err = points.AwardCategoryPoints(ctx, ada.ID, verses.ID, 5, "verse")
+		assert.Nil(t, err)
+		awarded, err := badges.Evaluate(ctx, SystemUser, ada.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, awarded)
+		earned, err = badges.Badges(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(earned))
+	})
+}
+
+func TestBadgeService_ShouldAwardPerfectAttendance(t *testing.T) {
+	ctx := context.Background()
+	rules := []config.BadgeRule{{Key: "attendance", Name: "Perfect attendance", Kind: config.BadgeMonthlyAttendance, Bonus: 10}}
+	useBadgeRules(t, rules)
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		events := NewEventService(stores, tx)
+		awards := NewAwardService(stores, tx)
+		badges := NewBadgeService(stores, tx)
+		now := time.Now()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		first, err := events.CreateEvent(ctx, eventLeader, "First meeting", month.Add(time.Hour), month.Add(2*time.Hour))
+		assert.Nil(t, err)
+		second, err := events.CreateEvent(ctx, eventLeader, "Second meeting", month.Add(25*time.Hour), month.Add(26*time.Hour))
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+
+		_, err = awards.Award(ctx, eventLeader, BulkAward{EventID: first.ID, Lines: []AwardLine{{ParticipantID: ada.ID, Points: 5}}})
+		assert.Nil(t, err)
+		earned, err := badges.Badges(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Empty(t, earned)
+
+		_, err = awards.Award(ctx, eventLeader, BulkAward{EventID: second.ID, Lines: []AwardLine{{ParticipantID: ada.ID, Points: 5}}})
+		assert.Nil(t, err)
+		earned, err = badges.Badges(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"attendance"}, badgeKeys(earned))
+		assert.Equal(t, now.Format("2006-01"), earned[0].Period)
+		assert.NotEmpty(t, earned[0].PointEntryID)
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(20), balance.Balance)
+	})
+}
+
+func TestSaveBadge_ShouldSkipDuplicateWithoutFailingTheTransaction(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		season, err := stores.Seasons.CurrentSeason(ctx)
+		assert.Nil(t, err)
+		badge := Badge{ParticipantID: ada.ID, RuleKey: "hundred", SeasonID: season.ID, Name: "100 points", AwardedAt: time.Now()}
+
+		err = tx.WithinTx(ctx, func(tx Stores) error {
+			_, inserted, err := tx.Badges.SaveBadge(ctx, badge)
+			assert.True(t, inserted)
+			if err != nil {
+				return err
+			}
+			_, inserted, err = tx.Badges.SaveBadge(ctx, badge)
+			assert.False(t, inserted)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Points.RecordPoints(ctx, PointEntry{SeasonID: season.ID, ParticipantID: ada.ID, Points: 1})
+			return err
+		})
+		assert.Nil(t, err)
+
+		earned, err := stores.Badges.Badges(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(earned))
+		balance, err := stores.Points.LoadBalance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), balance.Balance)
+	})
+}
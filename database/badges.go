@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Badge is a milestone a participant reached, recorded under the key of the
+// rule that awarded it. Period is the month ("2006-01") of attendance
+// badges and empty for badges awarded once per season. PointEntryID is the
+// bonus entry granted with the badge, if any.
+type Badge struct {
+	ID            string `gorm:"primaryKey"`
+	ParticipantID string `gorm:"not null;size:36;uniqueIndex:idx_badges_award"`
+	RuleKey       string `gorm:"not null;size:100;uniqueIndex:idx_badges_award"`
+	SeasonID      string `gorm:"not null;size:36;uniqueIndex:idx_badges_award"`
+	Period        string `gorm:"not null;size:7;uniqueIndex:idx_badges_award"`
+	Name          string `gorm:"size:255"`
+	PointEntryID  string
+	AwardedAt     time.Time
+}
+
+func (badge *Badge) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	badge.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", badge.ID)
+	return
+}
+
+// LedgerProgress is what a participant earned in the ledger entries a
+// LeaderboardFilter selects.
+type LedgerProgress struct {
+	Points  int64
+	Entries int64
+}
+
+// SaveBadge inserts the badge unless the participant already has it. A
+// duplicate is skipped by the database rather than failing, so it does not
+// abort the enclosing transaction.
+func (r *UserRepository) SaveBadge(ctx context.Context, badge Badge) (Badge, bool, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "participant_id"}, {Name: "rule_key"}, {Name: "season_id"}, {Name: "period"}},
+		DoNothing: true,
+	}).Create(&badge)
+	// SQL Server merges on the primary key only and reports the duplicate,
+	// which leaves its transaction usable.
+	if record.Error != nil && isDuplicateKeyError(record.Error) {
+		return badge, false, nil
+	}
+	return badge, record.Error == nil && record.RowsAffected > 0, record.Error
+}
+
+// SetBadgeEntry links a badge to the bonus entry granted with it.
+func (r *UserRepository) SetBadgeEntry(ctx context.Context, badgeID string, pointEntryID string) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	return db.Model(&Badge{}).Where("id = ?", badgeID).Update("point_entry_id", pointEntryID).Error
+}
+
+func (r *UserRepository) HasBadge(ctx context.Context, participantID string, ruleKey string, seasonID string, period string) (bool, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var count int64
+	err := db.Model(&Badge{}).
+		Where("participant_id = ? AND rule_key = ? AND season_id = ? AND period = ?", participantID, ruleKey, seasonID, period).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Badges returns every badge of a participant, oldest first.
+func (r *UserRepository) Badges(ctx context.Context, participantID string) ([]Badge, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var badges []Badge
+	err := db.Where("participant_id = ?", participantID).Order("awarded_at").Find(&badges).Error
+	return badges, err
+}
+
+// Progress sums the participant's entries counted by filter. It reads the
+// primary, not a replica, so it sees the writes of a running transaction.
+func (r *UserRepository) Progress(ctx context.Context, filter LeaderboardFilter, participantID string) (LedgerProgress, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var progress LedgerProgress
+	err := ledgerFilter(db.Model(&PointEntry{}), filter).
+		Select("COALESCE(SUM(point_entries.points), 0) AS points, COUNT(*) AS entries").
+		Where("point_entries.participant_id = ?", participantID).
+		Scan(&progress).Error
+	return progress, err
+}
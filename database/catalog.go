@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrConflict is returned when a record changed since it was read, such as
+// a catalog item sold or repriced by another checkout station.
+var ErrConflict = errors.New("the record was changed by someone else")
+
+// CatalogItem is a reward participants can redeem points for. Stock is the
+// number still on the shelf. PurchaseLimit caps how many one participant
+// may redeem per season, 0 means no limit. The item can only be redeemed
+// between AvailableFrom and AvailableUntil when they are set.
+//
+// Version starts at 1 and grows with every change, including stock taken by
+// a redemption. Updates and redemptions name the version they were based on
+// and fail with ErrConflict if it is no longer current.
+type CatalogItem struct {
+	ID             string `gorm:"primaryKey"`
+	Name           string `gorm:"not null;size:200;uniqueIndex"`
+	Description    string `gorm:"size:1024"`
+	ImageURL       string `gorm:"size:1024"`
+	Price          int64  `gorm:"not null"`
+	Stock          int    `gorm:"not null"`
+	PurchaseLimit  int    `gorm:"not null"`
+	AvailableFrom  *time.Time
+	AvailableUntil *time.Time
+	Version        int `gorm:"not null;default:1"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (item *CatalogItem) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	item.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", item.ID)
+	item.Version = 1
+	tx.Statement.SetColumn("Version", item.Version)
+	return
+}
+
+// AvailableAt reports whether the item may be redeemed at the given time.
+func (item CatalogItem) AvailableAt(at time.Time) bool {
+	return (item.AvailableFrom == nil || !at.Before(*item.AvailableFrom)) &&
+		(item.AvailableUntil == nil || at.Before(*item.AvailableUntil))
+}
+
+// Redemption records the items a participant took for a spend entry in the
+// ledger. Redemptions confirmed together at checkout share a ReceiptNumber.
+type Redemption struct {
+	ID            string `gorm:"primaryKey"`
+	SeasonID      string `gorm:"not null;index"`
+	ItemID        string `gorm:"not null;index"`
+	ParticipantID string `gorm:"not null;index"`
+	PointEntryID  string `gorm:"not null"`
+	ReceiptNumber string `gorm:"size:36;index"`
+	Quantity      int    `gorm:"not null"`
+	Points        int64  `gorm:"not null"`
+	CreatedAt     time.Time
+}
+
+func (redemption *Redemption) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	redemption.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", redemption.ID)
+	return
+}
+
+// SaveCatalogItem creates the item when it has no ID yet. Otherwise it
+// updates the item if item.Version is still the stored version, and bumps
+// the version.
+func (r *UserRepository) SaveCatalogItem(ctx context.Context, item CatalogItem) (CatalogItem, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if item.ID == "" {
+		err = db.Create(&item).Error
+	} else {
+		item.Version++
+		record := db.Model(&CatalogItem{}).
+			Where("id = ? AND version = ?", item.ID, item.Version-1).
+			Select("*").Omit("id", "created_at", "deleted_at").
+			Updates(&item)
+		err = record.Error
+		if err == nil && record.RowsAffected == 0 {
+			err = ErrConflict
+		}
+	}
+	if err != nil && isDuplicateKeyError(err) {
+		return item, ValidationErrorNew("name", "item name already exists", DUPLICATE_VALUE_CODE)
+	}
+	return item, err
+}
+
+func (r *UserRepository) LoadCatalogItem(ctx context.Context, id string) (CatalogItem, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var item CatalogItem
+	err := db.Where("id = ?", id).First(&item).Error
+	return item, err
+}
+
+// CatalogItems returns every item ordered by name.
+func (r *UserRepository) CatalogItems(ctx context.Context) ([]CatalogItem, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var items []CatalogItem
+	err := db.Order("name").Find(&items).Error
+	return items, err
+}
+
+// TakeStock removes quantity from the item's stock in a single conditional
+// update on its version, so two stations selling from the same version
+// cannot both succeed and the stock never goes below zero.
+func (r *UserRepository) TakeStock(ctx context.Context, itemID string, quantity int, version int) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Model(&CatalogItem{}).
+		Where("id = ? AND version = ? AND stock >= ?", itemID, version, quantity).
+		Updates(map[string]interface{}{
+			"stock":   gorm.Expr("stock - ?", quantity),
+			"version": gorm.Expr("version + 1"),
+		})
+	if record.Error != nil || record.RowsAffected > 0 {
+		return record.Error
+	}
+	var item CatalogItem
+	err := db.Where("id = ?", itemID).First(&item).Error
+	if err != nil {
+		return err
+	}
+	if item.Version != version {
+		return ErrConflict
+	}
+	return outOfStock(item)
+}
+
+func outOfStock(item CatalogItem) error {
+	return ValidationErrorNew("stock", "only "+strconv.Itoa(item.Stock)+" of "+item.Name+" left in stock", OUT_OF_STOCK_CODE)
+}
+
+func (r *UserRepository) RecordRedemption(ctx context.Context, redemption Redemption) (Redemption, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	err := db.Create(&redemption).Error
+	return redemption, err
+}
+
+// RedeemedQuantity returns how many of the item the participant redeemed in
+// the season.
+func (r *UserRepository) RedeemedQuantity(ctx context.Context, seasonID string, itemID string, participantID string) (int, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var quantity int
+	err := db.Model(&Redemption{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("season_id = ? AND item_id = ? AND participant_id = ?", seasonID, itemID, participantID).
+		Scan(&quantity).Error
+	return quantity, err
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CatalogService manages the reward catalog and redeems points for its
+// items. Both need SPENT_POINTS_WRITE.
+type CatalogService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewCatalogService(stores Stores, tx TxRunner) *CatalogService {
+	return &CatalogService{stores: stores, tx: tx}
+}
+
+// SaveItem creates the item when it has no ID yet, otherwise updates it.
+func (s *CatalogService) SaveItem(ctx context.Context, actor User, item CatalogItem) (CatalogItem, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return CatalogItem{}, err
+	}
+	item.Name = strings.TrimSpace(item.Name)
+	err = validateCatalogItem(item)
+	if err != nil {
+		return CatalogItem{}, err
+	}
+	return s.stores.Catalog.SaveCatalogItem(ctx, item)
+}
+
+func validateCatalogItem(item CatalogItem) error {
+	if item.Name == "" {
+		return ValidationErrorNew("name", "item name is required", EMPTY_VALUE_CODE)
+	}
+	if item.Price <= 0 {
+		return ValidationErrorNew("price", "price must be positive", INVALID_VALUE_CODE)
+	}
+	if item.Stock < 0 {
+		return ValidationErrorNew("stock", "stock cannot be negative", INVALID_VALUE_CODE)
+	}
+	if item.PurchaseLimit < 0 {
+		return ValidationErrorNew("purchaseLimit", "purchase limit cannot be negative", INVALID_VALUE_CODE)
+	}
+	if item.AvailableFrom != nil && item.AvailableUntil != nil && !item.AvailableUntil.After(*item.AvailableFrom) {
+		return ValidationErrorNew("availableUntil", "the item must become unavailable after it becomes available", INVALID_VALUE_CODE)
+	}
+	return nil
+}
+
+func (s *CatalogService) Item(ctx context.Context, id string) (CatalogItem, error) {
+	return s.stores.Catalog.LoadCatalogItem(ctx, id)
+}
+
+// Items returns the whole catalog, including items out of stock or outside
+// their availability window.
+func (s *CatalogService) Items(ctx context.Context) ([]CatalogItem, error) {
+	return s.stores.Catalog.CatalogItems(ctx)
+}
+
+// AvailableItems returns the items that can be redeemed right now.
+func (s *CatalogService) AvailableItems(ctx context.Context) ([]CatalogItem, error) {
+	items, err := s.stores.Catalog.CatalogItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	available := items[:0]
+	for _, item := range items {
+		if item.Stock > 0 && item.AvailableAt(now) {
+			available = append(available, item)
+		}
+	}
+	return available, nil
+}
+
+// Redeem trades the participant's points for quantity of the item at its
+// current price. The stock is taken, the spend is written to the ledger and
+// the redemption is recorded in one transaction, so either all of it
+// happens or none.
+func (s *CatalogService) Redeem(ctx context.Context, actor User, participantID string, itemID string, quantity int) (Redemption, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return Redemption{}, err
+	}
+	var redemption Redemption
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		item, err := tx.Catalog.LoadCatalogItem(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		redemption, err = redeem(ctx, tx, participantID, "", CartLine{ItemID: itemID, Version: item.Version, Quantity: quantity})
+		return err
+	})
+	return redemption, err
+}
+
+// redeem runs one redemption with the stores of a running transaction. It
+// returns ErrConflict if the item is no longer at line.Version.
+func redeem(ctx context.Context, tx Stores, participantID string, receiptNumber string, line CartLine) (Redemption, error) {
+	if line.Quantity <= 0 {
+		return Redemption{}, ValidationErrorNew("quantity", "quantity must be positive", INVALID_VALUE_CODE)
+	}
+	item, err := tx.Catalog.LoadCatalogItem(ctx, line.ItemID)
+	if err != nil {
+		return Redemption{}, err
+	}
+	if item.Version != line.Version {
+		return Redemption{}, ErrConflict
+	}
+	if !item.AvailableAt(time.Now()) {
+		return Redemption{}, ValidationErrorNew("item", item.Name+" is not available", UNAVAILABLE_CODE)
+	}
+	season, err := tx.Seasons.CurrentSeason(ctx)
+	if err != nil {
+		return Redemption{}, err
+	}
+	if item.PurchaseLimit > 0 {
+		redeemed, err := tx.Catalog.RedeemedQuantity(ctx, season.ID, item.ID, participantID)
+		if err != nil {
+			return Redemption{}, err
+		}
+		if redeemed+line.Quantity > item.PurchaseLimit {
+			return Redemption{}, ValidationErrorNew("quantity", "only "+strconv.Itoa(item.PurchaseLimit)+" of "+item.Name+" per participant", PURCHASE_LIMIT_CODE)
+		}
+	}
+	err = tx.Catalog.TakeStock(ctx, item.ID, line.Quantity, line.Version)
+	if err != nil {
+		return Redemption{}, err
+	}
+	points := item.Price * int64(line.Quantity)
+	entry, err := spendPoints(ctx, tx, participantID, points, "Redeemed "+strconv.Itoa(line.Quantity)+" x "+item.Name)
+	if err != nil {
+		return Redemption{}, err
+	}
+	return tx.Catalog.RecordRedemption(ctx, Redemption{
+		SeasonID:      season.ID,
+		ItemID:        item.ID,
+		ParticipantID: participantID,
+		PointEntryID:  entry.ID,
+		ReceiptNumber: receiptNumber,
+		Quantity:      line.Quantity,
+		Points:        points,
+	})
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var storeVolunteer = User{Username: "volunteer", Role: Role{Permissions: SPENT_POINTS_WRITE}}
+
+func TestCatalogService_RedeemShouldTakeStockAndPoints(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		catalog := NewCatalogService(stores, tx)
+		item, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Yo-yo", Price: 15, Stock: 3, PurchaseLimit: 2})
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 50, "attendance")
+		assert.Nil(t, err)
+
+		redemption, err := catalog.Redeem(ctx, storeVolunteer, ada.ID, item.ID, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(30), redemption.Points)
+		assert.NotEmpty(t, redemption.PointEntryID)
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(20), balance.Balance)
+		item, err = catalog.Item(ctx, item.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, item.Stock)
+
+		_, err = catalog.Redeem(ctx, storeVolunteer, ada.ID, item.ID, 1)
+		assert.Equal(t, int(PURCHASE_LIMIT_CODE), err.(*ValidationError).ErrorCode())
+	})
+}
+
+func TestCatalogService_FailedRedeemShouldChangeNothing(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		catalog := NewCatalogService(stores, tx)
+		kite, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Kite", Price: 40, Stock: 5})
+		assert.Nil(t, err)
+		ball, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Ball", Price: 1, Stock: 1})
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 10, "attendance")
+		assert.Nil(t, err)
+
+		_, err = catalog.Redeem(ctx, storeVolunteer, ada.ID, kite.ID, 1)
+		assert.Equal(t, int(INSUFFICIENT_POINTS_CODE), err.(*ValidationError).ErrorCode())
+		kite, err = catalog.Item(ctx, kite.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, kite.Stock)
+
+		_, err = catalog.Redeem(ctx, storeVolunteer, ada.ID, ball.ID, 2)
+		assert.Equal(t, int(OUT_OF_STOCK_CODE), err.(*ValidationError).ErrorCode())
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), balance.Balance)
+
+		later := time.Now().Add(time.Hour)
+		ball.AvailableFrom = &later
+		ball, err = catalog.SaveItem(ctx, storeVolunteer, ball)
+		assert.Nil(t, err)
+		_, err = catalog.Redeem(ctx, storeVolunteer, ada.ID, ball.ID, 1)
+		assert.Equal(t, int(UNAVAILABLE_CODE), err.(*ValidationError).ErrorCode())
+		available, err := catalog.AvailableItems(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(available))
+		assert.Equal(t, "Kite", available[0].Name)
+	})
+}
+
+func TestCatalogService_ShouldRequireSpentPointsWrite(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		catalog := NewCatalogService(stores, tx)
+		reader := User{Role: Role{Permissions: SPENT_POINTS_READ}}
+		_, err := catalog.SaveItem(ctx, reader, CatalogItem{Name: "Kite", Price: 40, Stock: 5})
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+		_, err = catalog.Redeem(ctx, reader, "participant", "item", 1)
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+		_, err = catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Kite", Price: 0})
+		assert.Equal(t, int(INVALID_VALUE_CODE), err.(*ValidationError).ErrorCode())
+	})
+}
+
+func TestCatalogService_SaveItemShouldRejectStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		catalog := NewCatalogService(stores, tx)
+		item, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Kite", Price: 40, Stock: 5})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, item.Version)
+
+		repriced := item
+		repriced.Price = 30
+		repriced, err = catalog.SaveItem(ctx, storeVolunteer, repriced)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, repriced.Version)
+
+		item.Stock = 10
+		_, err = catalog.SaveItem(ctx, storeVolunteer, item)
+		assert.ErrorIs(t, err, ErrConflict)
+		stored, err := catalog.Item(ctx, item.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(30), stored.Price)
+		assert.Equal(t, 5, stored.Stock)
+	})
+}
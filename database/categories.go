@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Category groups ledger entries by what the points were earned for, such
+// as attendance or memory verses.
+type Category struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string `gorm:"not null;size:255;uniqueIndex"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (category *Category) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	category.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", category.ID)
+	return
+}
+
+func (r *UserRepository) SaveCategory(ctx context.Context, category Category) (Category, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if category.ID == "" {
+		err = db.Create(&category).Error
+	} else {
+		err = db.Save(&category).Error
+	}
+	if err != nil && isDuplicateKeyError(err) {
+		return category, ValidationErrorNew("name", "category name already exists", DUPLICATE_VALUE_CODE)
+	}
+	return category, err
+}
+
+func (r *UserRepository) LoadCategory(ctx context.Context, id string) (Category, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var category Category
+	err := db.Where("id = ?", id).First(&category).Error
+	return category, err
+}
+
+// Categories returns every category ordered by name.
+func (r *UserRepository) Categories(ctx context.Context) ([]Category, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var categories []Category
+	err := db.Order("name").Find(&categories).Error
+	return categories, err
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"strings"
+)
+
+// CategoryService manages the categories points are awarded in. Changes
+// need CATEGORY_WRITE.
+type CategoryService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewCategoryService(stores Stores, tx TxRunner) *CategoryService {
+	return &CategoryService{stores: stores, tx: tx}
+}
+
+func (s *CategoryService) CreateCategory(ctx context.Context, actor User, name string) (Category, error) {
+	err := requirePermission(actor, CATEGORY_WRITE)
+	if err != nil {
+		return Category{}, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Category{}, ValidationErrorNew("name", "category name is required", EMPTY_VALUE_CODE)
+	}
+	return s.stores.Categories.SaveCategory(ctx, Category{Name: name})
+}
+
+func (s *CategoryService) Categories(ctx context.Context) ([]Category, error) {
+	return s.stores.Categories.Categories(ctx)
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CheckoutService runs the store night checkout: a volunteer finds a
+// participant, builds a cart from the catalog and confirms it. Several
+// stations may sell at once; carts remember the item versions they were
+// priced at, so an item sold out or repriced by another station fails the
+// checkout instead of being sold twice. Every step needs SPENT_POINTS_WRITE.
+type CheckoutService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewCheckoutService(stores Stores, tx TxRunner) *CheckoutService {
+	return &CheckoutService{stores: stores, tx: tx}
+}
+
+// CartLine is quantity of an item as it was at Version.
+type CartLine struct {
+	ItemID   string
+	Version  int
+	Quantity int
+}
+
+// Cart is what a participant is about to redeem.
+type Cart struct {
+	ParticipantID string
+	Lines         []CartLine
+}
+
+// PricedLine is a cart line with the item it refers to.
+type PricedLine struct {
+	Item     CatalogItem
+	Quantity int
+	Points   int64
+	// Changed is set when the item no longer is at the line's version.
+	Changed bool
+}
+
+// CartSummary is a priced cart and the participant's running balance.
+type CartSummary struct {
+	Participant Participant
+	Balance     int64
+	Lines       []PricedLine
+	Total       int64
+	// Remaining is the balance left after checkout, negative when the cart
+	// costs more than the participant has.
+	Remaining int64
+}
+
+// Receipt is the result of a confirmed checkout. Number is stored with
+// each of its redemptions.
+type Receipt struct {
+	Number      string
+	Participant Participant
+	Volunteer   string
+	Lines       []PricedLine
+	Total       int64
+	Balance     int64
+	At          time.Time
+}
+
+// maxSearchResults bounds the participants a search returns.
+const maxSearchResults = 20
+
+// SearchParticipants finds participants by scanned ID or name.
+func (s *CheckoutService) SearchParticipants(ctx context.Context, actor User, text string) ([]Participant, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return nil, err
+	}
+	return s.stores.Points.SearchParticipants(ctx, text, maxSearchResults)
+}
+
+// AddToCart adds quantity of the item to the cart at the item's current
+// version. Adding an item already in the cart reprices that line.
+func (s *CheckoutService) AddToCart(ctx context.Context, actor User, cart Cart, itemID string, quantity int) (Cart, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return cart, err
+	}
+	if quantity <= 0 {
+		return cart, ValidationErrorNew("quantity", "quantity must be positive", INVALID_VALUE_CODE)
+	}
+	item, err := s.stores.Catalog.LoadCatalogItem(ctx, itemID)
+	if err != nil {
+		return cart, err
+	}
+	lines := make([]CartLine, 0, len(cart.Lines)+1)
+	for _, line := range cart.Lines {
+		if line.ItemID == itemID {
+			quantity += line.Quantity
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if quantity > item.Stock {
+		return cart, outOfStock(item)
+	}
+	cart.Lines = append(lines, CartLine{ItemID: itemID, Version: item.Version, Quantity: quantity})
+	return cart, nil
+}
+
+// RemoveFromCart drops the item's line from the cart.
+func RemoveFromCart(cart Cart, itemID string) Cart {
+	lines := make([]CartLine, 0, len(cart.Lines))
+	for _, line := range cart.Lines {
+		if line.ItemID != itemID {
+			lines = append(lines, line)
+		}
+	}
+	cart.Lines = lines
+	return cart
+}
+
+// Reprice moves every line of the cart to its item's current version, after
+// the volunteer reviewed the changes.
+func (s *CheckoutService) Reprice(ctx context.Context, actor User, cart Cart) (Cart, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return cart, err
+	}
+	lines := make([]CartLine, len(cart.Lines))
+	for i, line := range cart.Lines {
+		item, err := s.stores.Catalog.LoadCatalogItem(ctx, line.ItemID)
+		if err != nil {
+			return cart, err
+		}
+		line.Version = item.Version
+		lines[i] = line
+	}
+	cart.Lines = lines
+	return cart, nil
+}
+
+// Summarize prices the cart at the current catalog and balance.
+func (s *CheckoutService) Summarize(ctx context.Context, actor User, cart Cart) (CartSummary, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return CartSummary{}, err
+	}
+	return summarize(ctx, s.stores, cart)
+}
+
+func summarize(ctx context.Context, stores Stores, cart Cart) (CartSummary, error) {
+	var summary CartSummary
+	participant, err := stores.Points.LoadParticipant(ctx, cart.ParticipantID)
+	if err != nil {
+		return summary, err
+	}
+	balance, err := stores.Points.LoadBalance(ctx, cart.ParticipantID)
+	if err != nil {
+		return summary, err
+	}
+	summary.Participant = participant
+	summary.Balance = balance.Balance
+	for _, line := range cart.Lines {
+		item, err := stores.Catalog.LoadCatalogItem(ctx, line.ItemID)
+		if err != nil {
+			return summary, err
+		}
+		priced := PricedLine{Item: item, Quantity: line.Quantity, Points: item.Price * int64(line.Quantity), Changed: item.Version != line.Version}
+		summary.Lines = append(summary.Lines, priced)
+		summary.Total += priced.Points
+	}
+	summary.Remaining = summary.Balance - summary.Total
+	return summary, nil
+}
+
+// Checkout redeems the whole cart in one transaction. If any item changed
+// since it was added, ErrConflict is returned and nothing is redeemed; the
+// station shows the repriced cart and the volunteer confirms again.
+func (s *CheckoutService) Checkout(ctx context.Context, actor User, cart Cart) (Receipt, error) {
+	err := requirePermission(actor, SPENT_POINTS_WRITE)
+	if err != nil {
+		return Receipt{}, err
+	}
+	if len(cart.Lines) == 0 {
+		return Receipt{}, ValidationErrorNew("cart", "the cart is empty", EMPTY_VALUE_CODE)
+	}
+	var receipt Receipt
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		summary, err := summarize(ctx, tx, cart)
+		if err != nil {
+			return err
+		}
+		number := uuid.NewString()
+		for _, line := range cart.Lines {
+			_, err = redeem(ctx, tx, cart.ParticipantID, number, line)
+			if err != nil {
+				return err
+			}
+		}
+		receipt = Receipt{
+			Number:      number,
+			Participant: summary.Participant,
+			Volunteer:   actor.Username,
+			Lines:       summary.Lines,
+			Total:       summary.Total,
+			Balance:     summary.Remaining,
+			At:          time.Now(),
+		}
+		return nil
+	})
+	return receipt, err
+}
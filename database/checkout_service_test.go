@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckoutService_ShouldRedeemCartWithReceipt(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		catalog := NewCatalogService(stores, tx)
+		checkout := NewCheckoutService(stores, tx)
+		kite, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Kite", Price: 20, Stock: 2})
+		assert.Nil(t, err)
+		ball, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Ball", Price: 5, Stock: 10})
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 40, "attendance")
+		assert.Nil(t, err)
+
+		found, err := checkout.SearchParticipants(ctx, storeVolunteer, "love")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(found))
+		found, err = checkout.SearchParticipants(ctx, storeVolunteer, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(found))
+		// Wildcards typed in the search match themselves only.
+		for _, text := range []string{"%", "l_ve", "[l]ove"} {
+			found, err = checkout.SearchParticipants(ctx, storeVolunteer, text)
+			assert.Nil(t, err)
+			assert.Empty(t, found, text)
+		}
+
+		cart := Cart{ParticipantID: ada.ID}
+		cart, err = checkout.AddToCart(ctx, storeVolunteer, cart, kite.ID, 1)
+		assert.Nil(t, err)
+		cart, err = checkout.AddToCart(ctx, storeVolunteer, cart, ball.ID, 2)
+		assert.Nil(t, err)
+		cart, err = checkout.AddToCart(ctx, storeVolunteer, cart, ball.ID, 1)
+		assert.Nil(t, err)
+		_, err = checkout.AddToCart(ctx, storeVolunteer, cart, kite.ID, 2)
+		assert.Equal(t, int(OUT_OF_STOCK_CODE), err.(*ValidationError).ErrorCode())
+		summary, err := checkout.Summarize(ctx, storeVolunteer, cart)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(35), summary.Total)
+		assert.Equal(t, int64(5), summary.Remaining)
+
+		receipt, err := checkout.Checkout(ctx, storeVolunteer, cart)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, receipt.Number)
+		assert.Equal(t, int64(35), receipt.Total)
+		assert.Equal(t, int64(5), receipt.Balance)
+		assert.Equal(t, 2, len(receipt.Lines))
+		kite, err = catalog.Item(ctx, kite.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, kite.Stock)
+	})
+}
+
+func TestCheckoutService_ShouldNotSellTheLastItemTwice(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		catalog := NewCatalogService(stores, tx)
+		checkout := NewCheckoutService(stores, tx)
+		kite, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Kite", Price: 20, Stock: 1})
+		assert.Nil(t, err)
+		ball, err := catalog.SaveItem(ctx, storeVolunteer, CatalogItem{Name: "Ball", Price: 5, Stock: 10})
+		assert.Nil(t, err)
+		var carts []Cart
+		for _, first := range []string{"Ada", "Bob"} {
+			participant, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: first})
+			assert.Nil(t, err)
+			_, err = points.AwardPoints(ctx, participant.ID, 40, "attendance")
+			assert.Nil(t, err)
+			cart, err := checkout.AddToCart(ctx, storeVolunteer, Cart{ParticipantID: participant.ID}, ball.ID, 1)
+			assert.Nil(t, err)
+			cart, err = checkout.AddToCart(ctx, storeVolunteer, cart, kite.ID, 1)
+			assert.Nil(t, err)
+			carts = append(carts, cart)
+		}
+
+		_, err = checkout.Checkout(ctx, storeVolunteer, carts[0])
+		assert.Nil(t, err)
+		_, err = checkout.Checkout(ctx, storeVolunteer, carts[1])
+		assert.ErrorIs(t, err, ErrConflict)
+
+		// Nothing of the failed cart was redeemed.
+		balance, err := points.Balance(ctx, carts[1].ParticipantID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(40), balance.Balance)
+		ball, err = catalog.Item(ctx, ball.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 9, ball.Stock)
+
+		summary, err := checkout.Summarize(ctx, storeVolunteer, carts[1])
+		assert.Nil(t, err)
+		assert.True(t, summary.Lines[0].Changed)
+		repriced, err := checkout.Reprice(ctx, storeVolunteer, carts[1])
+		assert.Nil(t, err)
+		_, err = checkout.Checkout(ctx, storeVolunteer, repriced)
+		assert.Equal(t, int(OUT_OF_STOCK_CODE), err.(*ValidationError).ErrorCode())
+		_, err = checkout.Checkout(ctx, storeVolunteer, RemoveFromCart(repriced, kite.ID))
+		assert.Nil(t, err)
+	})
+}
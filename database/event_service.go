@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// EventService manages the events points are awarded for. Changes need
+// EVENT_WRITE.
+type EventService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewEventService(stores Stores, tx TxRunner) *EventService {
+	return &EventService{stores: stores, tx: tx}
+}
+
+func (s *EventService) CreateEvent(ctx context.Context, actor User, name string, startsAt time.Time, endsAt time.Time) (Event, error) {
+	err := requirePermission(actor, EVENT_WRITE)
+	if err != nil {
+		return Event{}, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Event{}, ValidationErrorNew("name", "event name is required", EMPTY_VALUE_CODE)
+	}
+	if !endsAt.After(startsAt) {
+		return Event{}, ValidationErrorNew("endsAt", "the event must end after it starts", INVALID_VALUE_CODE)
+	}
+	return s.stores.Events.SaveEvent(ctx, Event{Name: name, StartsAt: startsAt, EndsAt: endsAt})
+}
+
+func (s *EventService) Event(ctx context.Context, id string) (Event, error) {
+	return s.stores.Events.LoadEvent(ctx, id)
+}
+
+// Events returns the events overlapping [from, to) in start order.
+func (s *EventService) Events(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
+	return s.stores.Events.Events(ctx, from, to)
+}
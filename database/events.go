@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event is a club meeting or outing points can be awarded for.
+type Event struct {
+	ID        string    `gorm:"primaryKey"`
+	Name      string    `gorm:"not null;size:255"`
+	StartsAt  time.Time `gorm:"not null;index"`
+	EndsAt    time.Time `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (event *Event) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	event.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", event.ID)
+	return
+}
+
+func (r *UserRepository) SaveEvent(ctx context.Context, event Event) (Event, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if event.ID == "" {
+		err = db.Create(&event).Error
+	} else {
+		err = db.Save(&event).Error
+	}
+	return event, err
+}
+
+func (r *UserRepository) LoadEvent(ctx context.Context, id string) (Event, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var event Event
+	err := db.Where("id = ?", id).First(&event).Error
+	return event, err
+}
+
+// Events returns the events that overlap [from, to), in start order.
+func (r *UserRepository) Events(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var events []Event
+	err := db.Where("starts_at < ? AND ends_at > ?", to, from).Order("starts_at").Find(&events).Error
+	return events, err
+}
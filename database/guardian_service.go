@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"time"
+)
+
+// GuardianRoleName is the role of guardian accounts, which only grants
+// GUARDIAN_READ.
+const GuardianRoleName = "GUARDIAN"
+
+// ErrInviteInvalid is returned for an invite token that is unknown, expired
+// or already used.
+var ErrInviteInvalid = errors.New("the invitation is invalid, expired or already used")
+
+const (
+	// guardianInviteLifetime is how long an invitation link can be used.
+	guardianInviteLifetime = 7 * 24 * time.Hour
+	// guardianHistoryLength is how many ledger entries a guardian sees.
+	guardianHistoryLength = 20
+	// upcomingEventsWindow is how far ahead guardians see events.
+	upcomingEventsWindow = 30 * 24 * time.Hour
+)
+
+// GuardianService links guardian accounts to participants through emailed
+// invitations and shows guardians the participants they follow. Inviting
+// needs USER_WRITE; the guardian pages need GUARDIAN_READ and only show
+// linked participants.
+type GuardianService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewGuardianService(stores Stores, tx TxRunner) *GuardianService {
+	return &GuardianService{stores: stores, tx: tx}
+}
+
+// GuardianOverview is what a guardian sees of a participant.
+type GuardianOverview struct {
+	Participant Participant
+	Balance     ParticipantBalance
+	// History holds the latest ledger entries, newest first.
+	History []PointEntry
+	Badges  []Badge
+}
+
+// SearchParticipants finds the participant to invite a guardian for.
+func (s *GuardianService) SearchParticipants(ctx context.Context, actor User, text string) ([]Participant, error) {
+	err := requirePermission(actor, USER_WRITE)
+	if err != nil {
+		return nil, err
+	}
+	return s.stores.Points.SearchParticipants(ctx, text, maxSearchResults)
+}
+
+// Invite records an invitation for email to follow the participant and
+// returns it with the token for the link to send. The token is not stored
+// and cannot be recovered later.
+func (s *GuardianService) Invite(ctx context.Context, actor User, participantID string, email string) (GuardianInvite, string, error) {
+	err := requirePermission(actor, USER_WRITE)
+	if err != nil {
+		return GuardianInvite{}, "", err
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return GuardianInvite{}, "", ValidationErrorNew("email", "invalid email address", INVALID_VALUE_CODE)
+	}
+	_, err = s.stores.Points.LoadParticipant(ctx, participantID)
+	if err != nil {
+		return GuardianInvite{}, "", err
+	}
+	token, err := newSecretToken()
+	if err != nil {
+		return GuardianInvite{}, "", err
+	}
+	invite, err := s.stores.Guardians.SaveGuardianInvite(ctx, GuardianInvite{
+		Email:         address.Address,
+		ParticipantID: participantID,
+		TokenHash:     hashSecretToken(token),
+		InvitedBy:     actor.Username,
+		ExpiresAt:     time.Now().Add(guardianInviteLifetime),
+	})
+	return invite, token, err
+}
+
+// newSecretToken returns a random token for a link or device. Only its
+// hashSecretToken is stored.
+func newSecretToken() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Invitation returns the open invitation of token and its participant.
+func (s *GuardianService) Invitation(ctx context.Context, token string) (GuardianInvite, Participant, error) {
+	invite, err := openInvite(ctx, s.stores, token)
+	if err != nil {
+		return GuardianInvite{}, Participant{}, err
+	}
+	participant, err := s.stores.Points.LoadParticipant(ctx, invite.ParticipantID)
+	return invite, participant, err
+}
+
+func openInvite(ctx context.Context, stores Stores, token string) (GuardianInvite, error) {
+	invite, err := stores.Guardians.LoadGuardianInvite(ctx, hashSecretToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return GuardianInvite{}, ErrInviteInvalid
+	}
+	if err != nil {
+		return GuardianInvite{}, err
+	}
+	if invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return GuardianInvite{}, ErrInviteInvalid
+	}
+	return invite, nil
+}
+
+// AcceptInvite links the participant of the invitation to a guardian
+// account and uses up the invitation. An existing account logs on like at
+// the login form, so failed passwords count towards MaxLoginAttempts and
+// locked accounts are refused, and it must be a guardian account. Otherwise
+// a guardian account is created for the invited email.
+func (s *GuardianService) AcceptInvite(ctx context.Context, token string, username string, password string) (User, error) {
+	_, err := openInvite(ctx, s.stores, token)
+	if err != nil {
+		return User{}, err
+	}
+	var existing *User
+	_, err = s.stores.Users.LoadUser(ctx, username)
+	switch {
+	case err == nil:
+		// The logon runs outside the transaction below so a failed attempt
+		// is counted even though nothing else is written.
+		user, err := NewUserService(s.stores, s.tx).LogonUser(ctx, username, password)
+		if err != nil {
+			return User{}, err
+		}
+		if user.Role.RoleName != GuardianRoleName {
+			return User{}, ValidationErrorNew("username", "only guardian accounts can accept an invitation", INVALID_VALUE_CODE)
+		}
+		existing = &user
+	case !errors.Is(err, ErrNotFound):
+		return User{}, err
+	}
+
+	var user User
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		invite, err := openInvite(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+		// Claiming the invite first lets only one of two concurrent
+		// acceptances go on.
+		acceptedBy := NormalizeUsername(username)
+		if existing != nil {
+			acceptedBy = existing.Username
+		}
+		err = tx.Guardians.ClaimGuardianInvite(ctx, invite.ID, acceptedBy, time.Now())
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			user = *existing
+		} else {
+			user, err = createGuardian(ctx, tx, username, invite.Email, password)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.Guardians.SaveGuardianship(ctx, Guardianship{UserID: user.ID, ParticipantID: invite.ParticipantID})
+		return err
+	})
+	return user, err
+}
+
+func createGuardian(ctx context.Context, tx Stores, username string, email string, password string) (User, error) {
+	err := validatePassword(password)
+	if err != nil {
+		return User{}, ValidationErrorNew("password", err.Error(), INVALID_VALUE_CODE)
+	}
+	users := &UserService{stores: tx}
+	user, err := users.CreateNewUser(ctx, username, email, password)
+	if err != nil {
+		return User{}, err
+	}
+	role, err := tx.Roles.LoadRole(ctx, GuardianRoleName)
+	if err != nil {
+		return User{}, err
+	}
+	user.Role = role
+	user.RoleID = role.ID
+	err = tx.Users.SaveUser(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+	return tx.Users.LoadUser(ctx, user.Username)
+}
+
+// Participants returns an overview of every participant the guardian
+// follows.
+func (s *GuardianService) Participants(ctx context.Context, actor User) ([]GuardianOverview, error) {
+	err := requirePermission(actor, GUARDIAN_READ)
+	if err != nil {
+		return nil, err
+	}
+	participants, err := s.stores.Guardians.GuardedParticipants(ctx, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	overviews := make([]GuardianOverview, 0, len(participants))
+	for _, participant := range participants {
+		overview := GuardianOverview{Participant: participant}
+		overview.Balance, err = s.stores.Points.LoadBalance(ctx, participant.ID)
+		if err != nil {
+			return nil, err
+		}
+		overview.History, err = s.stores.Points.Entries(ctx, participant.ID, guardianHistoryLength)
+		if err != nil {
+			return nil, err
+		}
+		overview.Badges, err = s.stores.Badges.Badges(ctx, participant.ID)
+		if err != nil {
+			return nil, err
+		}
+		overviews = append(overviews, overview)
+	}
+	return overviews, nil
+}
+
+// UpcomingEvents returns the events of the next weeks in start order.
+func (s *GuardianService) UpcomingEvents(ctx context.Context, actor User) ([]Event, error) {
+	err := requirePermission(actor, GUARDIAN_READ)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return s.stores.Events.Events(ctx, now, now.Add(upcomingEventsWindow))
+}
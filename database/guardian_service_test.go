@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardianService_ShouldLinkInvitedGuardian(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		assert.Nil(t, NewUserService(stores, tx).InitiateModels(ctx))
+		points := NewPointService(stores, tx)
+		guardians := NewGuardianService(stores, tx)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		bob, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Bob", LastName: "Builder"})
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 5, "attendance")
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, bob.ID, 9, "attendance")
+		assert.Nil(t, err)
+
+		_, _, err = guardians.Invite(ctx, eventLeader, ada.ID, "parent@example.com")
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+		_, _, err = guardians.Invite(ctx, SystemUser, ada.ID, "not an address")
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+
+		invite, token, err := guardians.Invite(ctx, SystemUser, ada.ID, "Parent <parent@example.com>")
+		assert.Nil(t, err)
+		assert.Equal(t, "parent@example.com", invite.Email)
+		assert.NotEqual(t, token, invite.TokenHash)
+		_, participant, err := guardians.Invitation(ctx, token)
+		assert.Nil(t, err)
+		assert.Equal(t, ada.ID, participant.ID)
+
+		guardian, err := guardians.AcceptInvite(ctx, token, "parent", "Password_1")
+		assert.Nil(t, err)
+		assert.Equal(t, GuardianRoleName, guardian.Role.RoleName)
+		assert.Equal(t, "parent@example.com", guardian.Email)
+		_, err = guardians.AcceptInvite(ctx, token, "parent", "Password_1")
+		assert.True(t, errors.Is(err, ErrInviteInvalid))
+
+		overviews, err := guardians.Participants(ctx, guardian)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(overviews))
+		assert.Equal(t, ada.ID, overviews[0].Participant.ID)
+		assert.Equal(t, int64(5), overviews[0].Balance.Balance)
+		assert.Equal(t, "attendance", overviews[0].History[0].Reason)
+		_, err = guardians.SearchParticipants(ctx, guardian, "Bob")
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+
+		// A second child is added to the existing login.
+		_, token, err = guardians.Invite(ctx, SystemUser, bob.ID, "parent@example.com")
+		assert.Nil(t, err)
+		_, err = guardians.AcceptInvite(ctx, token, "parent", "Wrong_Password1")
+		var logonErr *LogonError
+		assert.True(t, errors.As(err, &logonErr))
+		// The failed password counts like one at the login form.
+		parent, err := stores.Users.LoadUser(ctx, "parent")
+		assert.Nil(t, err)
+		assert.Equal(t, uint(1), parent.LoginAttempts)
+		_, err = guardians.AcceptInvite(ctx, token, "parent", "Password_1")
+		assert.Nil(t, err)
+		overviews, err = guardians.Participants(ctx, guardian)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(overviews))
+	})
+}
+
+func TestGuardianService_AcceptShouldRefuseOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		users := NewUserService(stores, tx)
+		assert.Nil(t, users.InitiateModels(ctx))
+		guardians := NewGuardianService(stores, tx)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		_, err = users.CreateNewUser(ctx, "staff", "staff@example.com", "Password_1")
+		assert.Nil(t, err)
+		invite, token, err := guardians.Invite(ctx, SystemUser, ada.ID, "parent@example.com")
+		assert.Nil(t, err)
+
+		// The admin must reset the seeded password before logging on.
+		_, err = guardians.AcceptInvite(ctx, token, "admin", "Password_1")
+		var logonErr *LogonError
+		assert.True(t, errors.As(err, &logonErr))
+		_, err = guardians.AcceptInvite(ctx, token, "staff", "Password_1")
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+
+		// The invite is still open and can only be claimed once.
+		assert.Nil(t, stores.Guardians.ClaimGuardianInvite(ctx, invite.ID, "parent", time.Now()))
+		assert.ErrorIs(t, stores.Guardians.ClaimGuardianInvite(ctx, invite.ID, "other", time.Now()), ErrInviteInvalid)
+		_, err = guardians.AcceptInvite(ctx, token, "parent", "Password_1")
+		assert.ErrorIs(t, err, ErrInviteInvalid)
+	})
+}
+
+func TestGuardianService_ShouldRejectExpiredInvite(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		assert.Nil(t, NewUserService(stores, tx).InitiateModels(ctx))
+		guardians := NewGuardianService(stores, tx)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		invite, token, err := guardians.Invite(ctx, SystemUser, ada.ID, "parent@example.com")
+		assert.Nil(t, err)
+		invite.ExpiresAt = time.Now().Add(-time.Minute)
+		_, err = stores.Guardians.SaveGuardianInvite(ctx, invite)
+		assert.Nil(t, err)
+
+		_, err = guardians.AcceptInvite(ctx, token, "parent", "Password_1")
+
+		assert.True(t, errors.Is(err, ErrInviteInvalid))
+		_, err = stores.Users.LoadUser(ctx, "parent")
+		assert.True(t, errors.Is(err, ErrNotFound))
+		_, _, err = guardians.Invitation(ctx, "unknown")
+		assert.True(t, errors.Is(err, ErrInviteInvalid))
+	})
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Guardianship links a guardian's user account to a participant whose
+// points they may follow.
+type Guardianship struct {
+	ID            string `gorm:"primaryKey"`
+	UserID        string `gorm:"not null;size:36;uniqueIndex:idx_guardianships_link"`
+	ParticipantID string `gorm:"not null;size:36;uniqueIndex:idx_guardianships_link;index"`
+	CreatedAt     time.Time
+}
+
+func (guardianship *Guardianship) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	guardianship.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", guardianship.ID)
+	return
+}
+
+// GuardianInvite is an emailed invitation to follow a participant. Only the
+// SHA-256 hash of the token sent in the link is stored.
+type GuardianInvite struct {
+	ID            string `gorm:"primaryKey"`
+	Email         string `gorm:"not null;size:255"`
+	ParticipantID string `gorm:"not null;index"`
+	TokenHash     string `gorm:"not null;size:64;uniqueIndex"`
+	InvitedBy     string `gorm:"size:255"`
+	ExpiresAt     time.Time
+	AcceptedAt    *time.Time
+	AcceptedBy    string `gorm:"size:255"`
+	CreatedAt     time.Time
+}
+
+func (invite *GuardianInvite) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	invite.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", invite.ID)
+	return
+}
+
+func (r *UserRepository) SaveGuardianship(ctx context.Context, guardianship Guardianship) (Guardianship, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	err := db.Create(&guardianship).Error
+	if err != nil && isDuplicateKeyError(err) {
+		return guardianship, ValidationErrorNew("participant", "the guardian already follows this participant", DUPLICATE_VALUE_CODE)
+	}
+	return guardianship, err
+}
+
+func (r *UserRepository) IsGuardian(ctx context.Context, userID string, participantID string) (bool, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var count int64
+	err := db.Model(&Guardianship{}).Where("user_id = ? AND participant_id = ?", userID, participantID).Count(&count).Error
+	return count > 0, err
+}
+
+// GuardedParticipants returns the participants a guardian follows ordered
+// by last and first name.
+func (r *UserRepository) GuardedParticipants(ctx context.Context, userID string) ([]Participant, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var participants []Participant
+	err := db.Joins("JOIN guardianships ON guardianships.participant_id = participants.id").
+		Where("guardianships.user_id = ?", userID).
+		Order("participants.last_name, participants.first_name").
+		Find(&participants).Error
+	return participants, err
+}
+
+func (r *UserRepository) SaveGuardianInvite(ctx context.Context, invite GuardianInvite) (GuardianInvite, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if invite.ID == "" {
+		err = db.Create(&invite).Error
+	} else {
+		err = db.Save(&invite).Error
+	}
+	return invite, err
+}
+
+// ClaimGuardianInvite accepts the invite with a conditional update, so of
+// two concurrent acceptances only one succeeds.
+func (r *UserRepository) ClaimGuardianInvite(ctx context.Context, id string, acceptedBy string, at time.Time) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Model(&GuardianInvite{}).
+		Where("id = ? AND accepted_at IS NULL", id).
+		Updates(map[string]interface{}{"accepted_at": at, "accepted_by": acceptedBy})
+	if record.Error != nil {
+		return record.Error
+	}
+	if record.RowsAffected == 0 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+func (r *UserRepository) LoadGuardianInvite(ctx context.Context, tokenHash string) (GuardianInvite, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var invite GuardianInvite
+	err := db.Where("token_hash = ?", tokenHash).First(&invite).Error
+	return invite, err
+}
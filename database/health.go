@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"blue-beetle/logging"
+)
+
+// Pinger is anything whose connection can be checked, such as UserRepository.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthStatus is the result of the last database check. A zero CheckedAt
+// means no check has run yet.
+type HealthStatus struct {
+	Healthy   bool
+	CheckedAt time.Time
+	Err       error
+}
+
+// HealthMonitor pings the database in the background and keeps the last
+// result for readiness checks.
+type HealthMonitor struct {
+	// name identifies the database in log messages.
+	name     string
+	pinger   Pinger
+	interval time.Duration
+	timeout  time.Duration
+
+	mu     sync.RWMutex
+	status HealthStatus
+}
+
+// NewHealthMonitor checks pinger every interval. Each ping may take at most
+// half the interval so a hanging database is reported before the next check.
+func NewHealthMonitor(pinger Pinger, interval time.Duration) *HealthMonitor {
+	return &HealthMonitor{name: "Database", pinger: pinger, interval: interval, timeout: interval / 2}
+}
+
+// Status returns the result of the last check.
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Ready returns the status used for readiness. Without a background check
+// the database is pinged on every call.
+func (m *HealthMonitor) Ready(ctx context.Context) HealthStatus {
+	if m.interval <= 0 {
+		return m.Check(ctx)
+	}
+	return m.Status()
+}
+
+// Check pings the database now, records and returns the result. Changes
+// between healthy and unhealthy are logged.
+func (m *HealthMonitor) Check(ctx context.Context) HealthStatus {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	err := m.pinger.Ping(ctx)
+	status := HealthStatus{Healthy: err == nil, CheckedAt: time.Now(), Err: err}
+
+	m.mu.Lock()
+	previous := m.status
+	m.status = status
+	m.mu.Unlock()
+
+	if !status.Healthy && (previous.Healthy || previous.CheckedAt.IsZero()) {
+		logging.Errorf("%s is unreachable: %v", m.name, err)
+	} else if status.Healthy && !previous.Healthy && !previous.CheckedAt.IsZero() {
+		logging.Infof("%s is reachable again", m.name)
+	}
+	return status
+}
+
+// Run checks immediately and then every interval until ctx is done.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	m.Check(ctx)
+	if m.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
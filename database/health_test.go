@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	return p.err
+}
+
+func TestHealthMonitor_ShouldTrackPingResults(t *testing.T) {
+	pinger := &fakePinger{}
+	monitor := NewHealthMonitor(pinger, time.Minute)
+	assert.True(t, monitor.Status().CheckedAt.IsZero())
+	assert.False(t, monitor.Ready(context.Background()).Healthy)
+
+	assert.True(t, monitor.Check(context.Background()).Healthy)
+	assert.True(t, monitor.Ready(context.Background()).Healthy)
+
+	pinger.err = errors.New("connection refused")
+	monitor.Check(context.Background())
+	status := monitor.Ready(context.Background())
+	assert.False(t, status.Healthy)
+	assert.ErrorIs(t, status.Err, pinger.err)
+}
+
+func TestHealthMonitor_ShouldPingOnDemandWithoutInterval(t *testing.T) {
+	pinger := &fakePinger{err: errors.New("connection refused")}
+	monitor := NewHealthMonitor(pinger, 0)
+	assert.False(t, monitor.Ready(context.Background()).Healthy)
+
+	pinger.err = nil
+	assert.True(t, monitor.Ready(context.Background()).Healthy)
+}
+
+func TestUserRepository_ShouldPingDatabase(t *testing.T) {
+	repo := NewUserRepository(DbTemp(t), time.Second)
+	assert.Nil(t, repo.Ping(context.Background()))
+	assert.Nil(t, repo.Close())
+	assert.NotNil(t, repo.Ping(context.Background()))
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"blue-beetle/config"
+	"blue-beetle/logging"
+)
+
+// ErrKioskDenied is returned for a device token that is unknown or revoked.
+var ErrKioskDenied = errors.New("the kiosk device is not paired or was revoked")
+
+// ErrNoCurrentEvent is returned when no event is open for check-in.
+var ErrNoCurrentEvent = errors.New("no event is open for check-in")
+
+// kioskSeenInterval is how often a device's LastSeenAt is refreshed.
+const kioskSeenInterval = time.Minute
+
+// KioskService runs the check-in tablets at the door. Staff with
+// KIOSK_WRITE pair and revoke devices; a paired device can only search
+// participants and check them in to the current event, which awards the
+// configured attendance points through the normal award path.
+type KioskService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewKioskService(stores Stores, tx TxRunner) *KioskService {
+	return &KioskService{stores: stores, tx: tx}
+}
+
+// KioskCheckIn is the outcome of a check-in shown on the kiosk.
+type KioskCheckIn struct {
+	CheckIn     CheckIn
+	Participant Participant
+	Event       Event
+	Points      int64
+}
+
+// RegisterDevice pairs a new device and returns it with its token. The
+// token is not stored and cannot be recovered later.
+func (s *KioskService) RegisterDevice(ctx context.Context, actor User, name string) (KioskDevice, string, error) {
+	err := requirePermission(actor, KIOSK_WRITE)
+	if err != nil {
+		return KioskDevice{}, "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return KioskDevice{}, "", ValidationErrorNew("name", "the device needs a name", EMPTY_VALUE_CODE)
+	}
+	if len(name) > 100 {
+		return KioskDevice{}, "", ValidationErrorNew("name", "the device name is longer than 100 characters", INVALID_VALUE_CODE)
+	}
+	token, err := newSecretToken()
+	if err != nil {
+		return KioskDevice{}, "", err
+	}
+	device, err := s.stores.Kiosks.SaveKioskDevice(ctx, KioskDevice{
+		Name:      name,
+		TokenHash: hashSecretToken(token),
+		CreatedBy: actor.Username,
+	})
+	return device, token, err
+}
+
+// Devices returns every paired device, revoked ones included.
+func (s *KioskService) Devices(ctx context.Context, actor User) ([]KioskDevice, error) {
+	err := requirePermission(actor, KIOSK_WRITE)
+	if err != nil {
+		return nil, err
+	}
+	return s.stores.Kiosks.KioskDevices(ctx)
+}
+
+// Revoke ends the device's access; its token is refused from then on.
+func (s *KioskService) Revoke(ctx context.Context, actor User, deviceID string) (KioskDevice, error) {
+	err := requirePermission(actor, KIOSK_WRITE)
+	if err != nil {
+		return KioskDevice{}, err
+	}
+	device, err := s.stores.Kiosks.LoadKioskDevice(ctx, deviceID)
+	if err != nil {
+		return KioskDevice{}, err
+	}
+	alreadyRevoked := ValidationErrorNew("device", "the device is already revoked", INVALID_VALUE_CODE)
+	if device.RevokedAt != nil {
+		return device, alreadyRevoked
+	}
+	// The update only applies to a device still active, so two admins
+	// revoking at once cannot both succeed.
+	err = s.stores.Kiosks.RevokeKioskDevice(ctx, deviceID, actor.Username, time.Now())
+	if errors.Is(err, ErrConflict) {
+		return device, alreadyRevoked
+	}
+	if err != nil {
+		return KioskDevice{}, err
+	}
+	return s.stores.Kiosks.LoadKioskDevice(ctx, deviceID)
+}
+
+// Authenticate returns the device of token, or ErrKioskDenied when it is
+// unknown or revoked.
+func (s *KioskService) Authenticate(ctx context.Context, token string) (KioskDevice, error) {
+	if token == "" {
+		return KioskDevice{}, ErrKioskDenied
+	}
+	device, err := s.stores.Kiosks.KioskDeviceByToken(ctx, hashSecretToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return KioskDevice{}, ErrKioskDenied
+	}
+	if err != nil {
+		return KioskDevice{}, err
+	}
+	if device.RevokedAt != nil {
+		return KioskDevice{}, ErrKioskDenied
+	}
+	now := time.Now()
+	if device.LastSeenAt == nil || now.Sub(*device.LastSeenAt) >= kioskSeenInterval {
+		// Only the heartbeat is written, so a revocation made since the
+		// device was loaded is kept and refuses the token.
+		err = s.stores.Kiosks.TouchKioskDevice(ctx, device.ID, now)
+		if err != nil {
+			return KioskDevice{}, err
+		}
+		device.LastSeenAt = &now
+	}
+	return device, nil
+}
+
+func requireDevice(device KioskDevice) error {
+	if device.ID == "" || device.RevokedAt != nil {
+		return ErrKioskDenied
+	}
+	return nil
+}
+
+// kioskActor is the user check-ins of device are awarded as.
+func kioskActor(device KioskDevice) User {
+	return User{Username: "kiosk: " + device.Name, Role: Role{RoleName: "KIOSK", Permissions: ADD_POINTS_WRITE}}
+}
+
+// CurrentEvent returns the event open for check-in: the earliest one that
+// has not ended and starts within the configured early check-in time.
+func (s *KioskService) CurrentEvent(ctx context.Context, device KioskDevice) (Event, error) {
+	err := requireDevice(device)
+	if err != nil {
+		return Event{}, err
+	}
+	return currentEvent(ctx, s.stores)
+}
+
+func currentEvent(ctx context.Context, stores Stores) (Event, error) {
+	now := time.Now()
+	events, err := stores.Events.Events(ctx, now, now.Add(config.Live().Kiosk.EarlyCheckIn))
+	if err != nil {
+		return Event{}, err
+	}
+	for _, event := range events {
+		if event.EndsAt.After(now) {
+			return event, nil
+		}
+	}
+	return Event{}, ErrNoCurrentEvent
+}
+
+// Search finds participants by name or by the ID on their scanned card.
+func (s *KioskService) Search(ctx context.Context, device KioskDevice, text string) ([]Participant, error) {
+	err := requireDevice(device)
+	if err != nil {
+		return nil, err
+	}
+	return s.stores.Points.SearchParticipants(ctx, text, maxSearchResults)
+}
+
+// CheckIns returns the check-ins of the current event.
+func (s *KioskService) CheckIns(ctx context.Context, device KioskDevice, eventID string) ([]CheckIn, error) {
+	err := requireDevice(device)
+	if err != nil {
+		return nil, err
+	}
+	return s.stores.Kiosks.CheckIns(ctx, eventID)
+}
+
+// CheckIn checks the participant in to the current event and awards the
+// attendance points as a batch of that event, in one transaction. Each
+// participant can check in once per event.
+func (s *KioskService) CheckIn(ctx context.Context, device KioskDevice, participantID string) (KioskCheckIn, error) {
+	err := requireDevice(device)
+	if err != nil {
+		return KioskCheckIn{}, err
+	}
+	actor := kioskActor(device)
+	err = requirePermission(actor, ADD_POINTS_WRITE)
+	if err != nil {
+		return KioskCheckIn{}, err
+	}
+	settings := config.Live().Kiosk
+	var result KioskCheckIn
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		event, err := currentEvent(ctx, tx)
+		if err != nil {
+			return err
+		}
+		participant, err := tx.Points.LoadParticipant(ctx, participantID)
+		if err != nil {
+			return err
+		}
+		categoryID, err := attendanceCategory(ctx, tx, settings.Category)
+		if err != nil {
+			return err
+		}
+		award := BulkAward{
+			EventID: event.ID,
+			Reason:  "Check-in: " + event.Name,
+			Lines:   []AwardLine{{ParticipantID: participant.ID, CategoryID: categoryID, Points: settings.AttendancePoints}},
+		}
+		err = validateBulkAward(award)
+		if err != nil {
+			return err
+		}
+		batch, err := awardWithin(ctx, tx, actor, award)
+		if err != nil {
+			return err
+		}
+		checkIn, err := tx.Kiosks.SaveCheckIn(ctx, CheckIn{
+			EventID:       event.ID,
+			ParticipantID: participant.ID,
+			DeviceID:      device.ID,
+			BatchID:       batch.ID,
+		})
+		if err != nil {
+			return err
+		}
+		result = KioskCheckIn{CheckIn: checkIn, Participant: participant, Event: event, Points: settings.AttendancePoints}
+		return nil
+	})
+	return result, err
+}
+
+// attendanceCategory returns the ID of the category named in the kiosk
+// settings. An unknown name is logged and books the points uncategorized.
+func attendanceCategory(ctx context.Context, tx Stores, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	categories, err := tx.Categories.Categories(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, category := range categories {
+		if category.Name == name {
+			return category.ID, nil
+		}
+	}
+	logging.Warnf("Kiosk attendance points use the unknown category %q", name)
+	return "", nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKioskService_ShouldCheckInOncePerEvent(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		events := NewEventService(stores, tx)
+		awards := NewAwardService(stores, tx)
+		kiosk := NewKioskService(stores, tx)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		_, _, err = kiosk.RegisterDevice(ctx, eventLeader, "Front door")
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+		registered, token, err := kiosk.RegisterDevice(ctx, SystemUser, "Front door")
+		assert.Nil(t, err)
+		assert.NotEqual(t, token, registered.TokenHash)
+
+		device, err := kiosk.Authenticate(ctx, token)
+		assert.Nil(t, err)
+		assert.Equal(t, registered.ID, device.ID)
+		assert.NotNil(t, device.LastSeenAt)
+		_, err = kiosk.CheckIn(ctx, device, ada.ID)
+		assert.True(t, errors.Is(err, ErrNoCurrentEvent))
+
+		// Check-in opens before the event starts.
+		start := time.Now().Add(10 * time.Minute)
+		event, err := events.CreateEvent(ctx, eventLeader, "Club night", start, start.Add(2*time.Hour))
+		assert.Nil(t, err)
+		current, err := kiosk.CurrentEvent(ctx, device)
+		assert.Nil(t, err)
+		assert.Equal(t, event.ID, current.ID)
+
+		found, err := kiosk.Search(ctx, device, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(found))
+		result, err := kiosk.CheckIn(ctx, device, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, event.ID, result.CheckIn.EventID)
+		assert.Equal(t, "Ada", result.Participant.FirstName)
+		assert.Equal(t, int64(1), result.Points)
+		batches, err := awards.Batches(ctx, SystemUser)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(batches))
+		assert.Equal(t, event.ID, batches[0].EventID)
+		assert.Equal(t, "Check-in: Club night", batches[0].Reason)
+		assert.Equal(t, "kiosk: Front door", batches[0].CreatedBy)
+
+		_, err = kiosk.CheckIn(ctx, device, ada.ID)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), balance.Balance)
+		batches, err = awards.Batches(ctx, SystemUser)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(batches))
+		checkIns, err := kiosk.CheckIns(ctx, device, event.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(checkIns))
+	})
+}
+
+func TestKioskService_ShouldRefuseRevokedDevice(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		events := NewEventService(stores, tx)
+		kiosk := NewKioskService(stores, tx)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		start := time.Now().Add(-time.Hour)
+		_, err = events.CreateEvent(ctx, eventLeader, "Club night", start, start.Add(2*time.Hour))
+		assert.Nil(t, err)
+		registered, token, err := kiosk.RegisterDevice(ctx, SystemUser, "Front door")
+		assert.Nil(t, err)
+		device, err := kiosk.Authenticate(ctx, token)
+		assert.Nil(t, err)
+
+		revoked, err := kiosk.Revoke(ctx, SystemUser, registered.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "system", revoked.RevokedBy)
+
+		_, err = kiosk.Authenticate(ctx, token)
+		assert.True(t, errors.Is(err, ErrKioskDenied))
+		_, err = kiosk.Authenticate(ctx, "unknown")
+		assert.True(t, errors.Is(err, ErrKioskDenied))
+		_, err = kiosk.CheckIn(ctx, revoked, ada.ID)
+		assert.True(t, errors.Is(err, ErrKioskDenied))
+		_, err = kiosk.Revoke(ctx, SystemUser, device.ID)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		devices, err := kiosk.Devices(ctx, SystemUser)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(devices))
+		assert.NotNil(t, devices[0].RevokedAt)
+	})
+}
+
+// revokingKiosks revokes the device right after it is looked up by token,
+// as an admin acting while a check-in is in flight would.
+type revokingKiosks struct {
+	KioskStore
+}
+
+func (k revokingKiosks) KioskDeviceByToken(ctx context.Context, tokenHash string) (KioskDevice, error) {
+	device, err := k.KioskStore.KioskDeviceByToken(ctx, tokenHash)
+	if err != nil {
+		return device, err
+	}
+	return device, k.RevokeKioskDevice(ctx, device.ID, "admin", time.Now())
+}
+
+func TestKioskService_ShouldKeepRevocationMadeDuringAuthentication(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		kiosk := NewKioskService(stores, tx)
+		registered, token, err := kiosk.RegisterDevice(ctx, SystemUser, "Front door")
+		assert.Nil(t, err)
+
+		racing := stores
+		racing.Kiosks = revokingKiosks{stores.Kiosks}
+		_, err = NewKioskService(racing, tx).Authenticate(ctx, token)
+		assert.True(t, errors.Is(err, ErrKioskDenied))
+
+		device, err := stores.Kiosks.LoadKioskDevice(ctx, registered.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, device.RevokedAt)
+		assert.Equal(t, "admin", device.RevokedBy)
+		assert.Nil(t, device.LastSeenAt)
+		_, err = kiosk.Authenticate(ctx, token)
+		assert.True(t, errors.Is(err, ErrKioskDenied))
+		_, err = kiosk.Revoke(ctx, SystemUser, registered.ID)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		device, err = stores.Kiosks.LoadKioskDevice(ctx, registered.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "admin", device.RevokedBy)
+	})
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KioskDevice is a tablet paired for participant check-in. It logs in with
+// a device token instead of a user password; only the SHA-256 hash of the
+// token is stored.
+type KioskDevice struct {
+	ID         string `gorm:"primaryKey"`
+	Name       string `gorm:"not null;size:100"`
+	TokenHash  string `gorm:"not null;size:64;uniqueIndex"`
+	CreatedBy  string `gorm:"size:255"`
+	LastSeenAt *time.Time
+	// RevokedAt is set once the device may no longer be used.
+	RevokedAt *time.Time
+	RevokedBy string `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+func (device *KioskDevice) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	device.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", device.ID)
+	return
+}
+
+// CheckIn records a participant checking in to an event and the award
+// batch of the attendance points.
+type CheckIn struct {
+	ID            string `gorm:"primaryKey"`
+	EventID       string `gorm:"not null;size:36;uniqueIndex:idx_check_ins_attendee"`
+	ParticipantID string `gorm:"not null;size:36;uniqueIndex:idx_check_ins_attendee;index"`
+	DeviceID      string `gorm:"index"`
+	BatchID       string
+	CreatedAt     time.Time
+}
+
+func (checkIn *CheckIn) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	checkIn.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", checkIn.ID)
+	return
+}
+
+func (r *UserRepository) SaveKioskDevice(ctx context.Context, device KioskDevice) (KioskDevice, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if device.ID == "" {
+		err = db.Create(&device).Error
+	} else {
+		err = db.Save(&device).Error
+	}
+	return device, err
+}
+
+func (r *UserRepository) TouchKioskDevice(ctx context.Context, id string, at time.Time) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Model(&KioskDevice{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("last_seen_at", at)
+	if record.Error != nil {
+		return record.Error
+	}
+	if record.RowsAffected == 0 {
+		return ErrKioskDenied
+	}
+	return nil
+}
+
+func (r *UserRepository) RevokeKioskDevice(ctx context.Context, id string, revokedBy string, at time.Time) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Model(&KioskDevice{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": at, "revoked_by": revokedBy})
+	if record.Error != nil {
+		return record.Error
+	}
+	if record.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *UserRepository) LoadKioskDevice(ctx context.Context, id string) (KioskDevice, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var device KioskDevice
+	err := db.Where("id = ?", id).First(&device).Error
+	return device, err
+}
+
+func (r *UserRepository) KioskDeviceByToken(ctx context.Context, tokenHash string) (KioskDevice, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var device KioskDevice
+	err := db.Where("token_hash = ?", tokenHash).First(&device).Error
+	return device, err
+}
+
+// KioskDevices returns every device, revoked ones included, ordered by name.
+func (r *UserRepository) KioskDevices(ctx context.Context) ([]KioskDevice, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var devices []KioskDevice
+	err := db.Order("name, created_at").Find(&devices).Error
+	return devices, err
+}
+
+func (r *UserRepository) SaveCheckIn(ctx context.Context, checkIn CheckIn) (CheckIn, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	err := db.Create(&checkIn).Error
+	if err != nil && isDuplicateKeyError(err) {
+		return checkIn, ValidationErrorNew("participant", "the participant is already checked in", DUPLICATE_VALUE_CODE)
+	}
+	return checkIn, err
+}
+
+// CheckIns returns the check-ins of an event, oldest first.
+func (r *UserRepository) CheckIns(ctx context.Context, eventID string) ([]CheckIn, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var checkIns []CheckIn
+	err := db.Where("event_id = ?", eventID).Order("created_at, id").Find(&checkIns).Error
+	return checkIns, err
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"blue-beetle/config"
+)
+
+// LeaderboardService ranks participants and teams for the leaderboard
+// pages. The leaderboard config section decides how many places are shown
+// and whether names are reduced to initials.
+type LeaderboardService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewLeaderboardService(stores Stores, tx TxRunner) *LeaderboardService {
+	return &LeaderboardService{stores: stores, tx: tx}
+}
+
+// LeaderboardQuery selects a leaderboard. Empty fields mean the open
+// season, every category and the whole season; a zero Limit means the
+// configured size. Week is an ISO 8601 week such as "2026-W07".
+type LeaderboardQuery struct {
+	SeasonID   string
+	CategoryID string
+	Week       string
+	Limit      int
+}
+
+// LeaderboardRow is one place on a leaderboard. Tied scores share a rank
+// and the next rank is skipped, so two firsts are followed by a third.
+type LeaderboardRow struct {
+	Rank   int
+	Name   string
+	Color  string
+	Points int64
+}
+
+// maxLeaderboardLimit bounds the number of places a request may ask for.
+const maxLeaderboardLimit = 100
+
+// Participants returns the top participants. Participants who opted out are
+// never listed.
+func (s *LeaderboardService) Participants(ctx context.Context, query LeaderboardQuery) ([]LeaderboardRow, error) {
+	filter, limit, err := s.filterOf(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.stores.Leaderboards.ParticipantScores(ctx, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	initialsOnly := config.Live().Leaderboard.InitialsOnly
+	rows := make([]LeaderboardRow, len(scores))
+	for i, score := range scores {
+		rows[i] = LeaderboardRow{Name: displayName(score.FirstName, score.LastName, initialsOnly), Points: score.Points}
+	}
+	return rankRows(rows, limit), nil
+}
+
+// Teams returns the top teams. A category filter leaves out team bonuses,
+// which have no category.
+func (s *LeaderboardService) Teams(ctx context.Context, query LeaderboardQuery) ([]LeaderboardRow, error) {
+	filter, limit, err := s.filterOf(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := s.stores.Leaderboards.TeamScores(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]LeaderboardRow, len(scores))
+	for i, score := range scores {
+		rows[i] = LeaderboardRow{Name: score.Name, Color: score.Color, Points: score.Points}
+	}
+	return rankRows(rows, limit), nil
+}
+
+// Categories returns the categories a leaderboard can be filtered by.
+func (s *LeaderboardService) Categories(ctx context.Context) ([]Category, error) {
+	return s.stores.Categories.Categories(ctx)
+}
+
+// SetOptOut takes a participant off the leaderboards or puts them back.
+func (s *LeaderboardService) SetOptOut(ctx context.Context, actor User, participantID string, optOut bool) error {
+	err := requirePermission(actor, PARTICIPENT_WRITE)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(tx Stores) error {
+		participant, err := tx.Points.LoadParticipant(ctx, participantID)
+		if err != nil {
+			return err
+		}
+		participant.LeaderboardOptOut = optOut
+		_, err = tx.Points.SaveParticipant(ctx, participant)
+		return err
+	})
+}
+
+func (s *LeaderboardService) filterOf(ctx context.Context, query LeaderboardQuery) (LeaderboardFilter, int, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = config.Live().Leaderboard.Size
+	}
+	if limit < 1 || limit > maxLeaderboardLimit {
+		return LeaderboardFilter{}, 0, ValidationErrorNew("limit", "limit must be between 1 and "+strconv.Itoa(maxLeaderboardLimit), INVALID_VALUE_CODE)
+	}
+	filter := LeaderboardFilter{SeasonID: query.SeasonID, CategoryID: query.CategoryID}
+	if filter.SeasonID == "" {
+		season, err := s.stores.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return filter, 0, err
+		}
+		filter.SeasonID = season.ID
+	}
+	if query.Week != "" {
+		from, err := ParseISOWeek(query.Week, time.Local)
+		if err != nil {
+			return filter, 0, err
+		}
+		filter.From = from
+		filter.To = from.AddDate(0, 0, 7)
+	}
+	return filter, limit, nil
+}
+
+// ParseISOWeek returns the start of the Monday that begins an ISO 8601 week
+// written as "2026-W07".
+func ParseISOWeek(week string, location *time.Location) (time.Time, error) {
+	invalid := ValidationErrorNew("week", "week must look like 2026-W07", INVALID_VALUE_CODE)
+	yearText, weekText, ok := strings.Cut(week, "-W")
+	if !ok {
+		return time.Time{}, invalid
+	}
+	year, err := strconv.Atoi(yearText)
+	if err != nil || year < 1 {
+		return time.Time{}, invalid
+	}
+	number, err := strconv.Atoi(weekText)
+	if err != nil || number < 1 || number > 53 {
+		return time.Time{}, invalid
+	}
+	// January 4th is always in week 1.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, location)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(number-1)*7)
+	if _, check := monday.ISOWeek(); check != number {
+		return time.Time{}, invalid
+	}
+	return monday, nil
+}
+
+// rankRows gives rows, sorted by points, competition ranks and cuts them
+// after limit places, keeping everyone tied with the last place.
+func rankRows(rows []LeaderboardRow, limit int) []LeaderboardRow {
+	for i := range rows {
+		rows[i].Rank = i + 1
+		if i > 0 && rows[i].Points == rows[i-1].Points {
+			rows[i].Rank = rows[i-1].Rank
+		}
+		if i >= limit && rows[i].Rank != rows[limit-1].Rank {
+			return rows[:i]
+		}
+	}
+	return rows
+}
+
+// displayName is the participant's full name, or initials like "A. L."
+// when initialsOnly is set.
+func displayName(firstName string, lastName string, initialsOnly bool) string {
+	if !initialsOnly {
+		return strings.TrimSpace(firstName + " " + lastName)
+	}
+	var initials []string
+	for _, name := range []string{firstName, lastName} {
+		first, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+		if first != utf8.RuneError {
+			initials = append(initials, string(unicode.ToUpper(first))+".")
+		}
+	}
+	return strings.Join(initials, " ")
+}
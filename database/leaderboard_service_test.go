@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"blue-beetle/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func names(rows []LeaderboardRow) []string {
+	list := make([]string, len(rows))
+	for i, row := range rows {
+		list[i] = row.Name
+	}
+	return list
+}
+
+func ranks(rows []LeaderboardRow) []int {
+	list := make([]int, len(rows))
+	for i, row := range rows {
+		list[i] = row.Rank
+	}
+	return list
+}
+
+func TestLeaderboardService_ShouldRankParticipantsWithTies(t *testing.T) {
+	ctx := context.Background()
+	settings := config.DefaultConfig()
+	settings.Leaderboard.InitialsOnly = false
+	config.SetLive(settings)
+	t.Cleanup(func() { config.SetLive(config.DefaultConfig()) })
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		categories := NewCategoryService(stores, tx)
+		leaderboards := NewLeaderboardService(stores, tx)
+		verses, err := categories.CreateCategory(ctx, SystemUser, "Memory verses")
+		assert.Nil(t, err)
+		award := func(first string, last string, amount int64, categoryID string) Participant {
+			participant, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: first, LastName: last})
+			assert.Nil(t, err)
+			_, err = points.AwardCategoryPoints(ctx, participant.ID, categoryID, amount, "test")
+			assert.Nil(t, err)
+			return participant
+		}
+		ada := award("Ada", "Lovelace", 30, verses.ID)
+		award("Bob", "Brown", 20, "")
+		award("Cy", "Carter", 20, verses.ID)
+		award("Dee", "Dunn", 20, "")
+		award("Eve", "Evans", 5, "")
+		hidden := award("Fay", "Fox", 50, "")
+		assert.Nil(t, leaderboards.SetOptOut(ctx, SystemUser, hidden.ID, true))
+		// Spending does not lower a score.
+		_, err = points.SpendPoints(ctx, ada.ID, 25, "store")
+		assert.Nil(t, err)
+
+		rows, err := leaderboards.Participants(ctx, LeaderboardQuery{Limit: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Ada Lovelace", "Bob Brown", "Cy Carter", "Dee Dunn"}, names(rows))
+		assert.Equal(t, []int{1, 2, 2, 2}, ranks(rows))
+		assert.Equal(t, int64(30), rows[0].Points)
+
+		rows, err = leaderboards.Participants(ctx, LeaderboardQuery{CategoryID: verses.ID})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Ada Lovelace", "Cy Carter"}, names(rows))
+	})
+}
+
+func TestLeaderboardService_ShouldShowInitialsOnly(t *testing.T) {
+	ctx := context.Background()
+	// Initials are the default.
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		leaderboards := NewLeaderboardService(stores, tx)
+		participant, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "ada", LastName: "Lovelace"})
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, participant.ID, 3, "attendance")
+		assert.Nil(t, err)
+
+		rows, err := leaderboards.Participants(ctx, LeaderboardQuery{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"A. L."}, names(rows))
+	})
+}
+
+func TestLeaderboardService_ShouldRankTeamsByWeek(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		teams := NewTeamService(stores, tx)
+		leaderboards := NewLeaderboardService(stores, tx)
+		red, err := teams.CreateTeam(ctx, SystemUser, "Red", "#ff0000")
+		assert.Nil(t, err)
+		_, err = teams.CreateTeam(ctx, SystemUser, "Blue", "#0000ff")
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		_, err = teams.MoveParticipant(ctx, SystemUser, ada.ID, red.ID)
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 7, "attendance")
+		assert.Nil(t, err)
+		_, err = teams.AwardTeamBonus(ctx, SystemUser, red.ID, 3, "game")
+		assert.Nil(t, err)
+
+		year, week := time.Now().ISOWeek()
+		thisWeek := fmt.Sprintf("%d-W%02d", year, week)
+		rows, err := leaderboards.Teams(ctx, LeaderboardQuery{Week: thisWeek})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Red", "Blue"}, names(rows))
+		assert.Equal(t, int64(10), rows[0].Points)
+		assert.Equal(t, "#ff0000", rows[0].Color)
+
+		rows, err = leaderboards.Teams(ctx, LeaderboardQuery{Week: "2001-W01"})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1, 1}, ranks(rows))
+		assert.Equal(t, int64(0), rows[0].Points)
+	})
+}
+
+func TestParseISOWeek(t *testing.T) {
+	monday, err := ParseISOWeek("2026-W01", time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2025, time.December, 29, 0, 0, 0, 0, time.UTC), monday)
+	monday, err = ParseISOWeek("2020-W53", time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, time.December, 28, 0, 0, 0, 0, time.UTC), monday)
+
+	for _, week := range []string{"2026-W54", "2025-W53", "2026-07", "W07", "2026-Wx"} {
+		_, err = ParseISOWeek(week, time.UTC)
+		assert.Equal(t, int(INVALID_VALUE_CODE), err.(*ValidationError).ErrorCode(), week)
+	}
+}
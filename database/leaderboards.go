@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LeaderboardFilter selects the ledger entries a leaderboard counts. Only
+// earned points count, spending never lowers a score and carried over
+// points are not earned in the season. Reversed award batches count as if
+// they never happened. An empty CategoryID counts every
+// category, zero From or To leave that end of the period open.
+type LeaderboardFilter struct {
+	SeasonID   string
+	CategoryID string
+	From       time.Time
+	To         time.Time
+}
+
+// countsFor reports whether entry is counted by a leaderboard with filter.
+// reversed holds the IDs of reversed award batches.
+func (filter LeaderboardFilter) countsFor(entry PointEntry, reversed map[string]bool) bool {
+	return entry.SeasonID == filter.SeasonID && entry.Points > 0 && !entry.CarryOver && !reversed[entry.BatchID] &&
+		(filter.CategoryID == "" || entry.CategoryID == filter.CategoryID) &&
+		filter.covers(entry.CreatedAt)
+}
+
+func (filter LeaderboardFilter) covers(at time.Time) bool {
+	return (filter.From.IsZero() || !at.Before(filter.From)) && (filter.To.IsZero() || at.Before(filter.To))
+}
+
+// ParticipantScore is a participant's points under a LeaderboardFilter.
+type ParticipantScore struct {
+	ParticipantID string
+	FirstName     string
+	LastName      string
+	Points        int64
+}
+
+// TeamScore is a team's points under a LeaderboardFilter: what its members
+// earned while on the team plus, unless a category is selected, its bonuses.
+type TeamScore struct {
+	TeamID string
+	Name   string
+	Color  string
+	Points int64
+}
+
+// earnedEntries restricts a query over point_entries to the earned points of
+// a season, leaving out carry over and reversed award batches.
+func earnedEntries(db *gorm.DB, seasonID string) *gorm.DB {
+	return db.Where("point_entries.season_id = ? AND point_entries.points > 0 AND point_entries.carry_over = ?", seasonID, false).
+		Where("point_entries.batch_id NOT IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&AwardBatch{}).
+			Select("id").Where("reversed_at IS NOT NULL"))
+}
+
+// ledgerFilter applies filter to a query over point_entries.
+func ledgerFilter(db *gorm.DB, filter LeaderboardFilter) *gorm.DB {
+	db = earnedEntries(db, filter.SeasonID)
+	if filter.CategoryID != "" {
+		db = db.Where("point_entries.category_id = ?", filter.CategoryID)
+	}
+	if !filter.From.IsZero() {
+		db = db.Where("point_entries.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		db = db.Where("point_entries.created_at < ?", filter.To)
+	}
+	return db
+}
+
+// ParticipantScores returns the limit best scores, highest first, extended
+// by everyone tied with the last of them. Participants who opted out, were
+// deleted or earned nothing are left out. The sums are computed by the
+// database and read from a replica when one is configured.
+func (r *UserRepository) ParticipantScores(ctx context.Context, filter LeaderboardFilter, limit int) ([]ParticipantScore, error) {
+	db, cancel := r.reportSession(ctx)
+	defer cancel()
+	query := func() *gorm.DB {
+		return ledgerFilter(db.Model(&PointEntry{}), filter).
+			Select("participants.id AS participant_id, participants.first_name, participants.last_name, SUM(point_entries.points) AS points").
+			Joins("JOIN participants ON participants.id = point_entries.participant_id").
+			Where("participants.deleted_at IS NULL AND participants.leaderboard_opt_out = ?", false).
+			Group("participants.id, participants.first_name, participants.last_name").
+			Order("points DESC, participants.last_name, participants.first_name, participants.id")
+	}
+	var scores []ParticipantScore
+	err := query().Limit(limit).Scan(&scores).Error
+	if err != nil || len(scores) < limit {
+		return scores, err
+	}
+	// A second pass picks up the ties a LIMIT would cut off.
+	cutoff := scores[len(scores)-1].Points
+	scores = nil
+	err = query().Having("SUM(point_entries.points) >= ?", cutoff).Scan(&scores).Error
+	return scores, err
+}
+
+// TeamScores returns the score of every team, highest first. Teams are few,
+// so member points and bonuses are summed by the database in two grouped
+// queries and merged here.
+func (r *UserRepository) TeamScores(ctx context.Context, filter LeaderboardFilter) ([]TeamScore, error) {
+	db, cancel := r.reportSession(ctx)
+	defer cancel()
+	var teams []Team
+	err := db.Find(&teams).Error
+	if err != nil {
+		return nil, err
+	}
+	type teamPoints struct {
+		TeamID string
+		Points int64
+	}
+	var members []teamPoints
+	err = ledgerFilter(db.Model(&PointEntry{}), filter).
+		Select("team_memberships.team_id, SUM(point_entries.points) AS points").
+		Joins(`JOIN team_memberships ON team_memberships.participant_id = point_entries.participant_id
+			AND point_entries.created_at >= team_memberships.joined_at
+			AND (team_memberships.left_at IS NULL OR point_entries.created_at < team_memberships.left_at)`).
+		Group("team_memberships.team_id").
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	var bonuses []teamPoints
+	if filter.CategoryID == "" {
+		query := db.Model(&TeamBonus{}).
+			Select("team_id, SUM(points) AS points").
+			Where("season_id = ?", filter.SeasonID)
+		if !filter.From.IsZero() {
+			query = query.Where("created_at >= ?", filter.From)
+		}
+		if !filter.To.IsZero() {
+			query = query.Where("created_at < ?", filter.To)
+		}
+		err = query.Group("team_id").Scan(&bonuses).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	points := make(map[string]int64, len(teams))
+	for _, sum := range append(members, bonuses...) {
+		points[sum.TeamID] += sum.Points
+	}
+	scores := make([]TeamScore, 0, len(teams))
+	for _, team := range teams {
+		scores = append(scores, TeamScore{TeamID: team.ID, Name: team.Name, Color: team.Color, Points: points[team.ID]})
+	}
+	sortTeamScores(scores)
+	return scores, nil
+}
+
+func sortTeamScores(scores []TeamScore) {
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Points != scores[j].Points {
+			return scores[i].Points > scores[j].Points
+		}
+		return scores[i].Name < scores[j].Name
+	})
+}
@@ -0,0 +1,1555 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps users, roles and participants in maps. It behaves like the GORM
+// stores (IDs, timestamps, upsert by name, role preloading) so business
+// logic and handlers can be tested without a database.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	users        map[string]User
+	roles        map[string]Role
+	participants map[string]Participant
+	ledger       []PointEntry
+	balances     map[string]ParticipantBalance
+	teams        map[string]Team
+	memberships  []TeamMembership
+	teamBonuses  []TeamBonus
+	seasons      map[string]Season
+	seasonTotals []SeasonTotal
+	categories   map[string]Category
+	catalog      map[string]CatalogItem
+	redemptions  []Redemption
+	events       map[string]Event
+	awardBatches map[string]AwardBatch
+	badges       []Badge
+	guardians    []Guardianship
+	invites      map[string]GuardianInvite
+	kiosks       map[string]KioskDevice
+	checkIns     []CheckIn
+	// txMu serializes WithinTx calls; writes made outside a transaction
+	// while one runs are lost if it rolls back.
+	txMu sync.Mutex
+}
+
+// NewMemoryStore returns an empty store with one open season, like a freshly
+// migrated database.
+func NewMemoryStore() *MemoryStore {
+	now := time.Now()
+	season := Season{ID: uuid.NewString(), Name: initialSeasonName, StartedAt: now, CreatedAt: now, UpdatedAt: now}
+	return &MemoryStore{
+		users:        make(map[string]User),
+		roles:        make(map[string]Role),
+		participants: make(map[string]Participant),
+		balances:     make(map[string]ParticipantBalance),
+		teams:        make(map[string]Team),
+		seasons:      map[string]Season{season.ID: season},
+		categories:   make(map[string]Category),
+		catalog:      make(map[string]CatalogItem),
+		events:       make(map[string]Event),
+		awardBatches: make(map[string]AwardBatch),
+		invites:      make(map[string]GuardianInvite),
+		kiosks:       make(map[string]KioskDevice),
+	}
+}
+
+// memoryStores is every store, implemented by the memory store both
+// directly and within a transaction.
+type memoryStores interface {
+	UserStore
+	RoleStore
+	PointStore
+	TeamStore
+	SeasonStore
+	CategoryStore
+	LeaderboardStore
+	CatalogStore
+	EventStore
+	AwardStore
+	BadgeStore
+	GuardianStore
+	KioskStore
+}
+
+func storesOf(store memoryStores) Stores {
+	return Stores{
+		Users:        store,
+		Roles:        store,
+		Points:       store,
+		Teams:        store,
+		Seasons:      store,
+		Categories:   store,
+		Leaderboards: store,
+		Catalog:      store,
+		Events:       store,
+		Awards:       store,
+		Badges:       store,
+		Guardians:    store,
+		Kiosks:       store,
+	}
+}
+
+func (m *MemoryStore) Stores() Stores {
+	return storesOf(m)
+}
+
+// memoryTx is the store as fn of WithinTx sees it. Its WithinTx nests like
+// a savepoint instead of waiting for the transaction it runs in.
+type memoryTx struct {
+	*MemoryStore
+}
+
+func (tx memoryTx) WithinTx(ctx context.Context, fn func(tx Stores) error) error {
+	return tx.savepoint(ctx, fn)
+}
+
+// WithinTx runs fn against the store itself and restores a snapshot taken
+// beforehand if fn fails. WithinTx of the stores fn receives nests: only
+// the inner writes are undone when the inner fn fails.
+func (m *MemoryStore) WithinTx(ctx context.Context, fn func(tx Stores) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+	return m.savepoint(ctx, fn)
+}
+
+// savepoint runs fn and restores the state it started from if fn fails.
+// m.txMu must be held.
+func (m *MemoryStore) savepoint(ctx context.Context, fn func(tx Stores) error) (err error) {
+	m.mu.RLock()
+	users := make(map[string]User, len(m.users))
+	for key, user := range m.users {
+		users[key] = user
+	}
+	roles := make(map[string]Role, len(m.roles))
+	for key, role := range m.roles {
+		roles[key] = role
+	}
+	participants := make(map[string]Participant, len(m.participants))
+	for key, participant := range m.participants {
+		participants[key] = participant
+	}
+	ledger := append([]PointEntry{}, m.ledger...)
+	balances := make(map[string]ParticipantBalance, len(m.balances))
+	for key, balance := range m.balances {
+		balances[key] = balance
+	}
+	teams := make(map[string]Team, len(m.teams))
+	for key, team := range m.teams {
+		teams[key] = team
+	}
+	memberships := append([]TeamMembership{}, m.memberships...)
+	teamBonuses := append([]TeamBonus{}, m.teamBonuses...)
+	seasons := make(map[string]Season, len(m.seasons))
+	for key, season := range m.seasons {
+		seasons[key] = season
+	}
+	seasonTotals := append([]SeasonTotal{}, m.seasonTotals...)
+	categories := make(map[string]Category, len(m.categories))
+	for key, category := range m.categories {
+		categories[key] = category
+	}
+	catalog := make(map[string]CatalogItem, len(m.catalog))
+	for key, item := range m.catalog {
+		catalog[key] = item
+	}
+	redemptions := append([]Redemption{}, m.redemptions...)
+	events := make(map[string]Event, len(m.events))
+	for key, event := range m.events {
+		events[key] = event
+	}
+	awardBatches := make(map[string]AwardBatch, len(m.awardBatches))
+	for key, batch := range m.awardBatches {
+		awardBatches[key] = batch
+	}
+	badges := append([]Badge{}, m.badges...)
+	guardians := append([]Guardianship{}, m.guardians...)
+	invites := make(map[string]GuardianInvite, len(m.invites))
+	for key, invite := range m.invites {
+		invites[key] = invite
+	}
+	kiosks := make(map[string]KioskDevice, len(m.kiosks))
+	for key, device := range m.kiosks {
+		kiosks[key] = device
+	}
+	checkIns := append([]CheckIn{}, m.checkIns...)
+	m.mu.RUnlock()
+	rollback := func() {
+		m.mu.Lock()
+		m.users = users
+		m.roles = roles
+		m.participants = participants
+		m.ledger = ledger
+		m.balances = balances
+		m.teams = teams
+		m.memberships = memberships
+		m.teamBonuses = teamBonuses
+		m.seasons = seasons
+		m.seasonTotals = seasonTotals
+		m.categories = categories
+		m.catalog = catalog
+		m.redemptions = redemptions
+		m.events = events
+		m.awardBatches = awardBatches
+		m.badges = badges
+		m.guardians = guardians
+		m.invites = invites
+		m.kiosks = kiosks
+		m.checkIns = checkIns
+		m.mu.Unlock()
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			rollback()
+			panic(recovered)
+		}
+	}()
+	err = fn(storesOf(memoryTx{m}))
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		rollback()
+	}
+	return err
+}
+
+// Ping always succeeds unless ctx is done; there is no connection to lose.
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (m *MemoryStore) LoadUser(ctx context.Context, username string) (User, error) {
+	if ctx.Err() != nil {
+		return User{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[NormalizeUsername(username)]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	user.Role = m.roleByID(user.RoleID)
+	return user, nil
+}
+
+func (m *MemoryStore) SaveUser(ctx context.Context, user User) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user.Username = NormalizeUsername(user.Username)
+	if user.Role.ID != "" {
+		user.RoleID = user.Role.ID
+	}
+	user.Role = Role{}
+	now := time.Now()
+	existing, ok := m.users[user.Username]
+	if ok && existing.ID != user.ID {
+		return ValidationErrorNew("username", "username already exits", DUPLICATE_VALUE_CODE)
+	}
+	if user.ID == "" {
+		user.ID = uuid.NewString()
+	}
+	user.CreatedAt = now
+	for key, other := range m.users {
+		if other.ID == user.ID {
+			// A renamed user moves to its new key.
+			user.CreatedAt = other.CreatedAt
+			delete(m.users, key)
+		}
+	}
+	user.UpdatedAt = now
+	m.users[user.Username] = user
+	return nil
+}
+
+func (m *MemoryStore) UsernameExists(ctx context.Context, username string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.users[NormalizeUsername(username)]
+	return ok, nil
+}
+
+func (m *MemoryStore) LoadRole(ctx context.Context, name string) (Role, error) {
+	if ctx.Err() != nil {
+		return Role{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	role, ok := m.roles[name]
+	if !ok {
+		return Role{}, ErrNotFound
+	}
+	return role, nil
+}
+
+func (m *MemoryStore) SaveRole(ctx context.Context, role Role) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	existing, ok := m.roles[role.RoleName]
+	if ok {
+		if role.ID == "" {
+			role.ID = existing.ID
+		}
+		role.CreatedAt = existing.CreatedAt
+	} else {
+		role.ID = uuid.NewString()
+		role.CreatedAt = now
+	}
+	role.UpdatedAt = now
+	m.roles[role.RoleName] = role
+	return nil
+}
+
+func (m *MemoryStore) SaveParticipant(ctx context.Context, participant Participant) (Participant, error) {
+	if ctx.Err() != nil {
+		return Participant{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	existing, ok := m.participants[participant.ID]
+	if ok {
+		participant.CreatedAt = existing.CreatedAt
+	} else {
+		if participant.ID == "" {
+			participant.ID = uuid.NewString()
+		}
+		participant.CreatedAt = now
+	}
+	participant.UpdatedAt = now
+	m.participants[participant.ID] = participant
+	return participant, nil
+}
+
+func (m *MemoryStore) LoadParticipant(ctx context.Context, id string) (Participant, error) {
+	if ctx.Err() != nil {
+		return Participant{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	participant, ok := m.participants[id]
+	if !ok {
+		return Participant{}, ErrNotFound
+	}
+	return participant, nil
+}
+
+func (m *MemoryStore) SearchParticipants(ctx context.Context, text string, limit int) ([]Participant, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	text = strings.TrimSpace(text)
+	if participant, ok := m.participants[text]; ok {
+		return []Participant{participant}, nil
+	}
+	words := strings.Fields(strings.ToLower(text))
+	var participants []Participant
+	for _, participant := range m.participants {
+		matches := len(words) > 0
+		for _, word := range words {
+			if !strings.HasPrefix(strings.ToLower(participant.FirstName), word) && !strings.HasPrefix(strings.ToLower(participant.LastName), word) {
+				matches = false
+			}
+		}
+		if matches {
+			participants = append(participants, participant)
+		}
+	}
+	sortParticipants(participants)
+	if len(participants) > limit {
+		participants = participants[:limit]
+	}
+	return participants, nil
+}
+
+func (m *MemoryStore) Participants(ctx context.Context) ([]Participant, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	participants := make([]Participant, 0, len(m.participants))
+	for _, participant := range m.participants {
+		participants = append(participants, participant)
+	}
+	sortParticipants(participants)
+	return participants, nil
+}
+
+func (m *MemoryStore) Entries(ctx context.Context, participantID string, limit int) ([]PointEntry, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var entries []PointEntry
+	for i := len(m.ledger) - 1; i >= 0 && len(entries) < limit; i-- {
+		if m.ledger[i].ParticipantID == participantID {
+			entries = append(entries, m.ledger[i])
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) RecordPoints(ctx context.Context, entry PointEntry) (PointEntry, error) {
+	if ctx.Err() != nil {
+		return PointEntry{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.seasonOpen(entry.SeasonID) {
+		return PointEntry{}, ErrSeasonClosed
+	}
+	return m.appendEntry(entry), nil
+}
+
+func (m *MemoryStore) SpendPoints(ctx context.Context, entry PointEntry) (PointEntry, error) {
+	if ctx.Err() != nil {
+		return PointEntry{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.seasonOpen(entry.SeasonID) {
+		return PointEntry{}, ErrSeasonClosed
+	}
+	if m.balances[entry.ParticipantID].Balance < -entry.Points {
+		return PointEntry{}, ErrInsufficientPoints
+	}
+	return m.appendEntry(entry), nil
+}
+
+// appendEntry adds entry to the ledger and its balance, m.mu must be held.
+func (m *MemoryStore) appendEntry(entry PointEntry) PointEntry {
+	entry.ID = uuid.NewString()
+	entry.CreatedAt = time.Now()
+	m.ledger = append(m.ledger, entry)
+	change := balanceOf(entry)
+	balance := m.balances[entry.ParticipantID]
+	balance.ParticipantID = entry.ParticipantID
+	balance.Earned += change.Earned
+	balance.Spent += change.Spent
+	balance.Balance += change.Balance
+	balance.UpdatedAt = entry.CreatedAt
+	m.balances[entry.ParticipantID] = balance
+	return entry
+}
+
+func (m *MemoryStore) LoadBalance(ctx context.Context, participantID string) (ParticipantBalance, error) {
+	if ctx.Err() != nil {
+		return ParticipantBalance{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	balance, ok := m.balances[participantID]
+	if !ok {
+		return ParticipantBalance{ParticipantID: participantID}, nil
+	}
+	return balance, nil
+}
+
+func (m *MemoryStore) Balances(ctx context.Context) ([]ParticipantBalance, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	balances := make([]ParticipantBalance, 0, len(m.balances))
+	for _, balance := range m.balances {
+		balances = append(balances, balance)
+	}
+	sortBalances(balances)
+	return balances, nil
+}
+
+func (m *MemoryStore) LockBalances(ctx context.Context, seasonID string) ([]ParticipantBalance, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	open := m.seasonOpen(seasonID)
+	m.mu.RUnlock()
+	if !open {
+		return nil, ErrSeasonClosed
+	}
+	// WithinTx already runs one transaction at a time, there is nothing
+	// more to lock.
+	return m.Balances(ctx)
+}
+
+func (m *MemoryStore) LedgerTotals(ctx context.Context, seasonID string) ([]ParticipantBalance, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	totals := make(map[string]ParticipantBalance)
+	for _, entry := range m.ledger {
+		if entry.SeasonID != seasonID {
+			continue
+		}
+		change := balanceOf(entry)
+		total := totals[entry.ParticipantID]
+		total.ParticipantID = entry.ParticipantID
+		total.Earned += change.Earned
+		total.Spent += change.Spent
+		total.Balance += change.Balance
+		totals[entry.ParticipantID] = total
+	}
+	list := make([]ParticipantBalance, 0, len(totals))
+	for _, total := range totals {
+		list = append(list, total)
+	}
+	sortBalances(list)
+	return list, nil
+}
+
+func (m *MemoryStore) SaveBalance(ctx context.Context, balance ParticipantBalance) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	balance.UpdatedAt = time.Now()
+	m.balances[balance.ParticipantID] = balance
+	return nil
+}
+
+func (m *MemoryStore) SaveTeam(ctx context.Context, team Team) (Team, error) {
+	if ctx.Err() != nil {
+		return Team{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.teams {
+		if other.Name == team.Name && other.ID != team.ID {
+			return team, ValidationErrorNew("name", "team name already exists", DUPLICATE_VALUE_CODE)
+		}
+	}
+	now := time.Now()
+	existing, ok := m.teams[team.ID]
+	if ok {
+		team.CreatedAt = existing.CreatedAt
+	} else {
+		if team.ID == "" {
+			team.ID = uuid.NewString()
+		}
+		team.CreatedAt = now
+	}
+	team.UpdatedAt = now
+	m.teams[team.ID] = team
+	return team, nil
+}
+
+func (m *MemoryStore) LoadTeam(ctx context.Context, id string) (Team, error) {
+	if ctx.Err() != nil {
+		return Team{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	team, ok := m.teams[id]
+	if !ok {
+		return Team{}, ErrNotFound
+	}
+	return team, nil
+}
+
+func (m *MemoryStore) Teams(ctx context.Context) ([]Team, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	teams := make([]Team, 0, len(m.teams))
+	for _, team := range m.teams {
+		teams = append(teams, team)
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].Name < teams[j].Name
+	})
+	return teams, nil
+}
+
+func (m *MemoryStore) CurrentMembership(ctx context.Context, participantID string) (TeamMembership, error) {
+	if ctx.Err() != nil {
+		return TeamMembership{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, membership := range m.memberships {
+		if membership.ParticipantID == participantID && membership.LeftAt == nil {
+			return membership, nil
+		}
+	}
+	return TeamMembership{}, ErrNotFound
+}
+
+func (m *MemoryStore) SaveMembership(ctx context.Context, membership TeamMembership) (TeamMembership, error) {
+	if ctx.Err() != nil {
+		return TeamMembership{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.memberships {
+		if existing.ID == membership.ID {
+			m.memberships[i] = membership
+			return membership, nil
+		}
+	}
+	if membership.ID == "" {
+		membership.ID = uuid.NewString()
+	}
+	m.memberships = append(m.memberships, membership)
+	return membership, nil
+}
+
+func (m *MemoryStore) Memberships(ctx context.Context, teamID string) ([]TeamMembership, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var memberships []TeamMembership
+	for _, membership := range m.memberships {
+		if membership.TeamID == teamID {
+			memberships = append(memberships, membership)
+		}
+	}
+	sort.SliceStable(memberships, func(i, j int) bool {
+		return memberships[i].JoinedAt.Before(memberships[j].JoinedAt)
+	})
+	return memberships, nil
+}
+
+func (m *MemoryStore) RecordTeamBonus(ctx context.Context, bonus TeamBonus) (TeamBonus, error) {
+	if ctx.Err() != nil {
+		return TeamBonus{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.seasonOpen(bonus.SeasonID) {
+		return TeamBonus{}, ErrSeasonClosed
+	}
+	bonus.ID = uuid.NewString()
+	bonus.CreatedAt = time.Now()
+	m.teamBonuses = append(m.teamBonuses, bonus)
+	return bonus, nil
+}
+
+func (m *MemoryStore) TeamTotal(ctx context.Context, teamID string, seasonID string) (TeamTotal, error) {
+	if ctx.Err() != nil {
+		return TeamTotal{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	total := TeamTotal{TeamID: teamID, SeasonID: seasonID}
+	reversed := m.reversedBatches()
+	for _, membership := range m.memberships {
+		if membership.TeamID != teamID {
+			continue
+		}
+		for _, entry := range m.ledger {
+			if entry.SeasonID != seasonID || entry.ParticipantID != membership.ParticipantID {
+				continue
+			}
+			if entry.Points > 0 && !entry.CarryOver && !reversed[entry.BatchID] && membership.covers(entry.CreatedAt) {
+				total.MemberPoints += entry.Points
+			}
+		}
+	}
+	for _, bonus := range m.teamBonuses {
+		if bonus.TeamID == teamID && bonus.SeasonID == seasonID {
+			total.BonusPoints += bonus.Points
+		}
+	}
+	total.Total = total.MemberPoints + total.BonusPoints
+	return total, nil
+}
+
+func (m *MemoryStore) DeleteBalances(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.balances = make(map[string]ParticipantBalance)
+	return nil
+}
+
+func (m *MemoryStore) CurrentSeason(ctx context.Context) (Season, error) {
+	if ctx.Err() != nil {
+		return Season{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, season := range m.seasons {
+		if season.ClosedAt == nil {
+			return season, nil
+		}
+	}
+	return Season{}, ErrNotFound
+}
+
+func (m *MemoryStore) LoadSeason(ctx context.Context, id string) (Season, error) {
+	if ctx.Err() != nil {
+		return Season{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	season, ok := m.seasons[id]
+	if !ok {
+		return Season{}, ErrNotFound
+	}
+	return season, nil
+}
+
+func (m *MemoryStore) Seasons(ctx context.Context) ([]Season, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seasons := make([]Season, 0, len(m.seasons))
+	for _, season := range m.seasons {
+		seasons = append(seasons, season)
+	}
+	sort.Slice(seasons, func(i, j int) bool {
+		return seasons[i].StartedAt.Before(seasons[j].StartedAt)
+	})
+	return seasons, nil
+}
+
+func (m *MemoryStore) SaveSeason(ctx context.Context, season Season) (Season, error) {
+	if ctx.Err() != nil {
+		return Season{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.seasons {
+		if other.Name == season.Name && other.ID != season.ID {
+			return season, ValidationErrorNew("name", "season name already exists", DUPLICATE_VALUE_CODE)
+		}
+	}
+	now := time.Now()
+	existing, ok := m.seasons[season.ID]
+	if ok {
+		season.CreatedAt = existing.CreatedAt
+	} else {
+		if season.ID == "" {
+			season.ID = uuid.NewString()
+		}
+		season.CreatedAt = now
+	}
+	season.UpdatedAt = now
+	m.seasons[season.ID] = season
+	return season, nil
+}
+
+func (m *MemoryStore) CloseSeason(ctx context.Context, id string, at time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.seasonOpen(id) {
+		return ErrSeasonClosed
+	}
+	season := m.seasons[id]
+	season.ClosedAt = &at
+	season.UpdatedAt = at
+	m.seasons[id] = season
+	return nil
+}
+
+func (m *MemoryStore) SaveSeasonTotals(ctx context.Context, totals []SeasonTotal) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seasonTotals = append(m.seasonTotals, totals...)
+	return nil
+}
+
+func (m *MemoryStore) SeasonTotals(ctx context.Context, seasonID string) ([]SeasonTotal, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var totals []SeasonTotal
+	for _, total := range m.seasonTotals {
+		if total.SeasonID == seasonID {
+			totals = append(totals, total)
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].ParticipantID < totals[j].ParticipantID
+	})
+	return totals, nil
+}
+
+func (m *MemoryStore) SaveCategory(ctx context.Context, category Category) (Category, error) {
+	if ctx.Err() != nil {
+		return Category{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.categories {
+		if other.Name == category.Name && other.ID != category.ID {
+			return category, ValidationErrorNew("name", "category name already exists", DUPLICATE_VALUE_CODE)
+		}
+	}
+	now := time.Now()
+	existing, ok := m.categories[category.ID]
+	if ok {
+		category.CreatedAt = existing.CreatedAt
+	} else {
+		if category.ID == "" {
+			category.ID = uuid.NewString()
+		}
+		category.CreatedAt = now
+	}
+	category.UpdatedAt = now
+	m.categories[category.ID] = category
+	return category, nil
+}
+
+func (m *MemoryStore) LoadCategory(ctx context.Context, id string) (Category, error) {
+	if ctx.Err() != nil {
+		return Category{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	category, ok := m.categories[id]
+	if !ok {
+		return Category{}, ErrNotFound
+	}
+	return category, nil
+}
+
+func (m *MemoryStore) Categories(ctx context.Context) ([]Category, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	categories := make([]Category, 0, len(m.categories))
+	for _, category := range m.categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+func (m *MemoryStore) SaveCatalogItem(ctx context.Context, item CatalogItem) (CatalogItem, error) {
+	if ctx.Err() != nil {
+		return CatalogItem{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.catalog {
+		if other.Name == item.Name && other.ID != item.ID {
+			return item, ValidationErrorNew("name", "item name already exists", DUPLICATE_VALUE_CODE)
+		}
+	}
+	now := time.Now()
+	if item.ID == "" {
+		item.ID = uuid.NewString()
+		item.Version = 1
+		item.CreatedAt = now
+	} else {
+		existing, ok := m.catalog[item.ID]
+		if !ok || existing.Version != item.Version {
+			return item, ErrConflict
+		}
+		item.Version++
+		item.CreatedAt = existing.CreatedAt
+	}
+	item.UpdatedAt = now
+	m.catalog[item.ID] = item
+	return item, nil
+}
+
+func (m *MemoryStore) LoadCatalogItem(ctx context.Context, id string) (CatalogItem, error) {
+	if ctx.Err() != nil {
+		return CatalogItem{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	item, ok := m.catalog[id]
+	if !ok {
+		return CatalogItem{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (m *MemoryStore) CatalogItems(ctx context.Context) ([]CatalogItem, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]CatalogItem, 0, len(m.catalog))
+	for _, item := range m.catalog {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+func (m *MemoryStore) TakeStock(ctx context.Context, itemID string, quantity int, version int) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.catalog[itemID]
+	if !ok {
+		return ErrNotFound
+	}
+	if item.Version != version {
+		return ErrConflict
+	}
+	if item.Stock < quantity {
+		return outOfStock(item)
+	}
+	item.Stock -= quantity
+	item.Version++
+	item.UpdatedAt = time.Now()
+	m.catalog[itemID] = item
+	return nil
+}
+
+func (m *MemoryStore) RecordRedemption(ctx context.Context, redemption Redemption) (Redemption, error) {
+	if ctx.Err() != nil {
+		return Redemption{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	redemption.ID = uuid.NewString()
+	redemption.CreatedAt = time.Now()
+	m.redemptions = append(m.redemptions, redemption)
+	return redemption, nil
+}
+
+func (m *MemoryStore) RedeemedQuantity(ctx context.Context, seasonID string, itemID string, participantID string) (int, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	quantity := 0
+	for _, redemption := range m.redemptions {
+		if redemption.SeasonID == seasonID && redemption.ItemID == itemID && redemption.ParticipantID == participantID {
+			quantity += redemption.Quantity
+		}
+	}
+	return quantity, nil
+}
+
+func (m *MemoryStore) ParticipantScores(ctx context.Context, filter LeaderboardFilter, limit int) ([]ParticipantScore, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	points := make(map[string]int64)
+	reversed := m.reversedBatches()
+	for _, entry := range m.ledger {
+		if filter.countsFor(entry, reversed) {
+			points[entry.ParticipantID] += entry.Points
+		}
+	}
+	var scores []ParticipantScore
+	for id, sum := range points {
+		participant, ok := m.participants[id]
+		if !ok || participant.LeaderboardOptOut {
+			continue
+		}
+		scores = append(scores, ParticipantScore{ParticipantID: id, FirstName: participant.FirstName, LastName: participant.LastName, Points: sum})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		if a.FirstName != b.FirstName {
+			return a.FirstName < b.FirstName
+		}
+		return a.ParticipantID < b.ParticipantID
+	})
+	for i := limit; i < len(scores); i++ {
+		if scores[i].Points < scores[limit-1].Points {
+			return scores[:i], nil
+		}
+	}
+	return scores, nil
+}
+
+func (m *MemoryStore) TeamScores(ctx context.Context, filter LeaderboardFilter) ([]TeamScore, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	points := make(map[string]int64)
+	reversed := m.reversedBatches()
+	for _, membership := range m.memberships {
+		for _, entry := range m.ledger {
+			if entry.ParticipantID == membership.ParticipantID && filter.countsFor(entry, reversed) && membership.covers(entry.CreatedAt) {
+				points[membership.TeamID] += entry.Points
+			}
+		}
+	}
+	if filter.CategoryID == "" {
+		for _, bonus := range m.teamBonuses {
+			if bonus.SeasonID == filter.SeasonID && filter.covers(bonus.CreatedAt) {
+				points[bonus.TeamID] += bonus.Points
+			}
+		}
+	}
+	scores := make([]TeamScore, 0, len(m.teams))
+	for _, team := range m.teams {
+		scores = append(scores, TeamScore{TeamID: team.ID, Name: team.Name, Color: team.Color, Points: points[team.ID]})
+	}
+	sortTeamScores(scores)
+	return scores, nil
+}
+
+func (m *MemoryStore) SaveEvent(ctx context.Context, event Event) (Event, error) {
+	if ctx.Err() != nil {
+		return Event{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	existing, ok := m.events[event.ID]
+	if ok {
+		event.CreatedAt = existing.CreatedAt
+	} else {
+		if event.ID == "" {
+			event.ID = uuid.NewString()
+		}
+		event.CreatedAt = now
+	}
+	event.UpdatedAt = now
+	m.events[event.ID] = event
+	return event, nil
+}
+
+func (m *MemoryStore) LoadEvent(ctx context.Context, id string) (Event, error) {
+	if ctx.Err() != nil {
+		return Event{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	event, ok := m.events[id]
+	if !ok {
+		return Event{}, ErrNotFound
+	}
+	return event, nil
+}
+
+func (m *MemoryStore) Events(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []Event
+	for _, event := range m.events {
+		if event.StartsAt.Before(to) && event.EndsAt.After(from) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].StartsAt.Before(events[j].StartsAt)
+	})
+	return events, nil
+}
+
+func (m *MemoryStore) SaveAwardBatch(ctx context.Context, batch AwardBatch) (AwardBatch, error) {
+	if ctx.Err() != nil {
+		return AwardBatch{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.awardBatches[batch.ID]
+	if ok {
+		batch.CreatedAt = existing.CreatedAt
+	} else {
+		if batch.ID == "" {
+			batch.ID = uuid.NewString()
+		}
+		batch.CreatedAt = time.Now()
+	}
+	m.awardBatches[batch.ID] = batch
+	return batch, nil
+}
+
+func (m *MemoryStore) MarkBatchReversed(ctx context.Context, id string, reversedBy string, at time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batch, ok := m.awardBatches[id]
+	if !ok || batch.ReversedAt != nil {
+		return ErrConflict
+	}
+	batch.ReversedAt = &at
+	batch.ReversedBy = reversedBy
+	m.awardBatches[id] = batch
+	return nil
+}
+
+func (m *MemoryStore) LoadAwardBatch(ctx context.Context, id string) (AwardBatch, error) {
+	if ctx.Err() != nil {
+		return AwardBatch{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	batch, ok := m.awardBatches[id]
+	if !ok {
+		return AwardBatch{}, ErrNotFound
+	}
+	return batch, nil
+}
+
+func (m *MemoryStore) AwardBatches(ctx context.Context, seasonID string, limit int) ([]AwardBatch, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var batches []AwardBatch
+	for _, batch := range m.awardBatches {
+		if batch.SeasonID == seasonID {
+			batches = append(batches, batch)
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.After(batches[j].CreatedAt)
+	})
+	if len(batches) > limit {
+		batches = batches[:limit]
+	}
+	return batches, nil
+}
+
+func (m *MemoryStore) BatchEntries(ctx context.Context, batchID string) ([]PointEntry, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var entries []PointEntry
+	for _, entry := range m.ledger {
+		if entry.BatchID == batchID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) AttendedEvents(ctx context.Context, participantID string, from time.Time, to time.Time) ([]string, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	attended := make(map[string]bool)
+	for _, entry := range m.ledger {
+		batch, ok := m.awardBatches[entry.BatchID]
+		if !ok || batch.ReversedAt != nil || entry.ParticipantID != participantID || entry.Points <= 0 {
+			continue
+		}
+		event, ok := m.events[batch.EventID]
+		if ok && !event.StartsAt.Before(from) && event.StartsAt.Before(to) {
+			attended[event.ID] = true
+		}
+	}
+	eventIDs := make([]string, 0, len(attended))
+	for eventID := range attended {
+		eventIDs = append(eventIDs, eventID)
+	}
+	return eventIDs, nil
+}
+
+func (m *MemoryStore) SaveBadge(ctx context.Context, badge Badge) (Badge, bool, error) {
+	if ctx.Err() != nil {
+		return Badge{}, false, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hasBadge(badge.ParticipantID, badge.RuleKey, badge.SeasonID, badge.Period) {
+		return badge, false, nil
+	}
+	badge.ID = uuid.NewString()
+	m.badges = append(m.badges, badge)
+	return badge, true, nil
+}
+
+func (m *MemoryStore) SetBadgeEntry(ctx context.Context, badgeID string, pointEntryID string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.badges {
+		if m.badges[i].ID == badgeID {
+			m.badges[i].PointEntryID = pointEntryID
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) HasBadge(ctx context.Context, participantID string, ruleKey string, seasonID string, period string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hasBadge(participantID, ruleKey, seasonID, period), nil
+}
+
+// hasBadge must be called with m.mu held.
+func (m *MemoryStore) hasBadge(participantID string, ruleKey string, seasonID string, period string) bool {
+	for _, badge := range m.badges {
+		if badge.ParticipantID == participantID && badge.RuleKey == ruleKey && badge.SeasonID == seasonID && badge.Period == period {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) Badges(ctx context.Context, participantID string) ([]Badge, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var badges []Badge
+	for _, badge := range m.badges {
+		if badge.ParticipantID == participantID {
+			badges = append(badges, badge)
+		}
+	}
+	sort.SliceStable(badges, func(i, j int) bool {
+		return badges[i].AwardedAt.Before(badges[j].AwardedAt)
+	})
+	return badges, nil
+}
+
+func (m *MemoryStore) Progress(ctx context.Context, filter LeaderboardFilter, participantID string) (LedgerProgress, error) {
+	if ctx.Err() != nil {
+		return LedgerProgress{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var progress LedgerProgress
+	reversed := m.reversedBatches()
+	for _, entry := range m.ledger {
+		if entry.ParticipantID == participantID && filter.countsFor(entry, reversed) {
+			progress.Points += entry.Points
+			progress.Entries++
+		}
+	}
+	return progress, nil
+}
+
+func (m *MemoryStore) SaveGuardianship(ctx context.Context, guardianship Guardianship) (Guardianship, error) {
+	if ctx.Err() != nil {
+		return Guardianship{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isGuardian(guardianship.UserID, guardianship.ParticipantID) {
+		return guardianship, ValidationErrorNew("participant", "the guardian already follows this participant", DUPLICATE_VALUE_CODE)
+	}
+	guardianship.ID = uuid.NewString()
+	guardianship.CreatedAt = time.Now()
+	m.guardians = append(m.guardians, guardianship)
+	return guardianship, nil
+}
+
+func (m *MemoryStore) IsGuardian(ctx context.Context, userID string, participantID string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.isGuardian(userID, participantID), nil
+}
+
+// isGuardian must be called with m.mu held.
+func (m *MemoryStore) isGuardian(userID string, participantID string) bool {
+	for _, guardianship := range m.guardians {
+		if guardianship.UserID == userID && guardianship.ParticipantID == participantID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) GuardedParticipants(ctx context.Context, userID string) ([]Participant, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var participants []Participant
+	for _, guardianship := range m.guardians {
+		participant, ok := m.participants[guardianship.ParticipantID]
+		if guardianship.UserID == userID && ok {
+			participants = append(participants, participant)
+		}
+	}
+	sortParticipants(participants)
+	return participants, nil
+}
+
+func (m *MemoryStore) SaveGuardianInvite(ctx context.Context, invite GuardianInvite) (GuardianInvite, error) {
+	if ctx.Err() != nil {
+		return GuardianInvite{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.invites[invite.TokenHash]
+	if ok && existing.ID != invite.ID {
+		return invite, errors.New("duplicate invite token")
+	}
+	if invite.ID == "" {
+		invite.ID = uuid.NewString()
+		invite.CreatedAt = time.Now()
+	}
+	m.invites[invite.TokenHash] = invite
+	return invite, nil
+}
+
+func (m *MemoryStore) ClaimGuardianInvite(ctx context.Context, id string, acceptedBy string, at time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for tokenHash, invite := range m.invites {
+		if invite.ID != id {
+			continue
+		}
+		if invite.AcceptedAt != nil {
+			return ErrInviteInvalid
+		}
+		invite.AcceptedAt = &at
+		invite.AcceptedBy = acceptedBy
+		m.invites[tokenHash] = invite
+		return nil
+	}
+	return ErrInviteInvalid
+}
+
+func (m *MemoryStore) LoadGuardianInvite(ctx context.Context, tokenHash string) (GuardianInvite, error) {
+	if ctx.Err() != nil {
+		return GuardianInvite{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	invite, ok := m.invites[tokenHash]
+	if !ok {
+		return GuardianInvite{}, ErrNotFound
+	}
+	return invite, nil
+}
+
+func (m *MemoryStore) SaveKioskDevice(ctx context.Context, device KioskDevice) (KioskDevice, error) {
+	if ctx.Err() != nil {
+		return KioskDevice{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if device.ID == "" {
+		device.ID = uuid.NewString()
+		device.CreatedAt = time.Now()
+	}
+	m.kiosks[device.ID] = device
+	return device, nil
+}
+
+func (m *MemoryStore) TouchKioskDevice(ctx context.Context, id string, at time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	device, ok := m.kiosks[id]
+	if !ok || device.RevokedAt != nil {
+		return ErrKioskDenied
+	}
+	device.LastSeenAt = &at
+	m.kiosks[id] = device
+	return nil
+}
+
+func (m *MemoryStore) RevokeKioskDevice(ctx context.Context, id string, revokedBy string, at time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	device, ok := m.kiosks[id]
+	if !ok || device.RevokedAt != nil {
+		return ErrConflict
+	}
+	device.RevokedAt = &at
+	device.RevokedBy = revokedBy
+	m.kiosks[id] = device
+	return nil
+}
+
+func (m *MemoryStore) LoadKioskDevice(ctx context.Context, id string) (KioskDevice, error) {
+	if ctx.Err() != nil {
+		return KioskDevice{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	device, ok := m.kiosks[id]
+	if !ok {
+		return KioskDevice{}, ErrNotFound
+	}
+	return device, nil
+}
+
+func (m *MemoryStore) KioskDeviceByToken(ctx context.Context, tokenHash string) (KioskDevice, error) {
+	if ctx.Err() != nil {
+		return KioskDevice{}, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, device := range m.kiosks {
+		if device.TokenHash == tokenHash {
+			return device, nil
+		}
+	}
+	return KioskDevice{}, ErrNotFound
+}
+
+func (m *MemoryStore) KioskDevices(ctx context.Context) ([]KioskDevice, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	devices := make([]KioskDevice, 0, len(m.kiosks))
+	for _, device := range m.kiosks {
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Name != devices[j].Name {
+			return devices[i].Name < devices[j].Name
+		}
+		return devices[i].CreatedAt.Before(devices[j].CreatedAt)
+	})
+	return devices, nil
+}
+
+func (m *MemoryStore) SaveCheckIn(ctx context.Context, checkIn CheckIn) (CheckIn, error) {
+	if ctx.Err() != nil {
+		return CheckIn{}, ctx.Err()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.checkIns {
+		if existing.EventID == checkIn.EventID && existing.ParticipantID == checkIn.ParticipantID {
+			return checkIn, ValidationErrorNew("participant", "the participant is already checked in", DUPLICATE_VALUE_CODE)
+		}
+	}
+	checkIn.ID = uuid.NewString()
+	checkIn.CreatedAt = time.Now()
+	m.checkIns = append(m.checkIns, checkIn)
+	return checkIn, nil
+}
+
+func (m *MemoryStore) CheckIns(ctx context.Context, eventID string) ([]CheckIn, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var checkIns []CheckIn
+	for _, checkIn := range m.checkIns {
+		if checkIn.EventID == eventID {
+			checkIns = append(checkIns, checkIn)
+		}
+	}
+	return checkIns, nil
+}
+
+// reversedBatches returns the IDs of reversed award batches. It must be
+// called with m.mu held.
+func (m *MemoryStore) reversedBatches() map[string]bool {
+	reversed := make(map[string]bool)
+	for _, batch := range m.awardBatches {
+		if batch.ReversedAt != nil {
+			reversed[batch.ID] = true
+		}
+	}
+	return reversed
+}
+
+// seasonOpen must be called with m.mu held.
+func (m *MemoryStore) seasonOpen(id string) bool {
+	season, ok := m.seasons[id]
+	return ok && season.ClosedAt == nil
+}
+
+func sortParticipants(participants []Participant) {
+	sort.Slice(participants, func(i, j int) bool {
+		if participants[i].LastName != participants[j].LastName {
+			return participants[i].LastName < participants[j].LastName
+		}
+		return participants[i].FirstName < participants[j].FirstName
+	})
+}
+
+func sortBalances(balances []ParticipantBalance) {
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].ParticipantID < balances[j].ParticipantID
+	})
+}
+
+func (m *MemoryStore) roleByID(id string) Role {
+	for _, role := range m.roles {
+		if role.ID == id {
+			return role
+		}
+	}
+	return Role{}
+}
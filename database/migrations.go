@@ -0,0 +1,1063 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Versions must be unique and are
+// applied in ascending order; Down must undo exactly what Up did.
+//
+// Migrations must not use the live model structs, which change over time.
+// Each one declares frozen copies of the tables as they were at that version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration in the schema_migrations table.
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus describes a known migration and whether it is applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// migrations is the ordered list of every schema change.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create roles and users",
+		Up:      migrateCreateRolesAndUsersUp,
+		Down:    migrateCreateRolesAndUsersDown,
+	},
+	{
+		Version: 2,
+		Name:    "link users to roles by foreign key",
+		Up:      migrateUserRoleForeignKeyUp,
+		Down:    migrateUserRoleForeignKeyDown,
+	},
+	{
+		Version: 3,
+		Name:    "create participants, points ledger and balances",
+		Up:      migrateCreatePointsLedgerUp,
+		Down:    migrateCreatePointsLedgerDown,
+	},
+	{
+		Version: 4,
+		Name:    "create teams, memberships and team bonuses",
+		Up:      migrateCreateTeamsUp,
+		Down:    migrateCreateTeamsDown,
+	},
+	{
+		Version: 5,
+		Name:    "scope the ledger to seasons",
+		Up:      migrateCreateSeasonsUp,
+		Down:    migrateCreateSeasonsDown,
+	},
+	{
+		Version: 6,
+		Name:    "add point categories and leaderboard opt-out",
+		Up:      migrateLeaderboardsUp,
+		Down:    migrateLeaderboardsDown,
+	},
+	{
+		Version: 7,
+		Name:    "create reward catalog and redemptions",
+		Up:      migrateCreateCatalogUp,
+		Down:    migrateCreateCatalogDown,
+	},
+	{
+		Version: 8,
+		Name:    "version catalog items and number receipts",
+		Up:      migrateCatalogVersionUp,
+		Down:    migrateCatalogVersionDown,
+	},
+	{
+		Version: 9,
+		Name:    "create events and reversible award batches",
+		Up:      migrateAwardBatchesUp,
+		Down:    migrateAwardBatchesDown,
+	},
+	{
+		Version: 10,
+		Name:    "create badges",
+		Up:      migrateCreateBadgesUp,
+		Down:    migrateCreateBadgesDown,
+	},
+	{
+		Version: 11,
+		Name:    "create guardianships and guardian invites",
+		Up:      migrateCreateGuardiansUp,
+		Down:    migrateCreateGuardiansDown,
+	},
+	{
+		Version: 12,
+		Name:    "create kiosk devices and check-ins",
+		Up:      migrateCreateKiosksUp,
+		Down:    migrateCreateKiosksDown,
+	},
+}
+
+// MigrateUp applies every pending migration and returns how many ran.
+func (r *UserRepository) MigrateUp(ctx context.Context) (int, error) {
+	return migrateUp(r.Database.WithContext(ctx), migrations)
+}
+
+// MigrateDown reverts the last steps applied migrations.
+func (r *UserRepository) MigrateDown(ctx context.Context, steps int) (int, error) {
+	return migrateDown(r.Database.WithContext(ctx), migrations, steps)
+}
+
+func (r *UserRepository) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return migrationStatus(r.Database.WithContext(ctx), migrations)
+}
+
+// transactionalDDL reports whether schema changes can be rolled back.
+// MySQL implicitly commits on every DDL statement.
+func transactionalDDL(db *gorm.DB) bool {
+	return db.Dialector.Name() != "mysql"
+}
+
+func sortedMigrations(list []Migration) ([]Migration, error) {
+	sorted := append([]Migration{}, list...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, errors.New("duplicate migration version " + strconv.Itoa(sorted[i].Version))
+		}
+	}
+	return sorted, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	err := db.AutoMigrate(&SchemaMigration{})
+	if err != nil {
+		return nil, err
+	}
+	var records []SchemaMigration
+	err = db.Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// runMigrationStep runs step and its bookkeeping in one transaction when the
+// dialect allows it.
+func runMigrationStep(db *gorm.DB, step func(tx *gorm.DB) error) error {
+	if transactionalDDL(db) {
+		return db.Transaction(step)
+	}
+	return step(db)
+}
+
+func migrateUp(db *gorm.DB, list []Migration) (int, error) {
+	sorted, err := sortedMigrations(list)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, migration := range sorted {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		m := migration
+		err = runMigrationStep(db, func(tx *gorm.DB) error {
+			err := m.Up(tx)
+			if err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return count, errors.New("migration " + strconv.Itoa(m.Version) + " (" + m.Name + ") failed: " + err.Error())
+		}
+		count++
+	}
+	return count, nil
+}
+
+func migrateDown(db *gorm.DB, list []Migration, steps int) (int, error) {
+	sorted, err := sortedMigrations(list)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := len(sorted) - 1; i >= 0 && count < steps; i-- {
+		m := sorted[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err = runMigrationStep(db, func(tx *gorm.DB) error {
+			err := m.Down(tx)
+			if err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return count, errors.New("reverting migration " + strconv.Itoa(m.Version) + " (" + m.Name + ") failed: " + err.Error())
+		}
+		count++
+	}
+	return count, nil
+}
+
+func migrationStatus(db *gorm.DB, list []Migration) ([]MigrationStatus, error) {
+	sorted, err := sortedMigrations(list)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(sorted))
+	for _, migration := range sorted {
+		record, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+type roleV1 struct {
+	ID          string `gorm:"primaryKey"`
+	RoleName    string `gorm:"not null,type:text"`
+	Permissions permission
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (roleV1) TableName() string {
+	return "roles"
+}
+
+type userV1 struct {
+	ID                 string `gorm:"primaryKey"`
+	Username           string `gorm:"not null;size:255;uniqueIndex"`
+	Email              string
+	Password           []byte
+	Role               roleV1 `gorm:"embedded"`
+	LoginAttempts      uint
+	LastLogin          time.Time `gorm:"embedded"`
+	ForcePasswordReset bool
+	DisableAccount     bool
+	CreatedAt          time.Time      `gorm:"embedded"`
+	UpdatedAt          time.Time      `gorm:"embedded"`
+	DeletedAt          gorm.DeletedAt `gorm:"index;embedded"`
+}
+
+func (userV1) TableName() string {
+	return "users"
+}
+
+// migrateCreateRolesAndUsersUp creates the original tables. Databases created
+// by the old AutoMigrate start-up already have them, so it only fills in
+// what is missing, normalizing stored usernames before the unique index.
+func migrateCreateRolesAndUsersUp(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&roleV1{})
+	if err != nil {
+		return err
+	}
+	if tx.Migrator().HasTable(&userV1{}) {
+		var users []userV1
+		err = tx.Unscoped().Select("id", "username").Order("id").Find(&users).Error
+		if err != nil {
+			return err
+		}
+		err = usernameCollisions(users)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			normalized := NormalizeUsername(user.Username)
+			if normalized == user.Username {
+				continue
+			}
+			err = tx.Model(&userV1{}).Where("id = ?", user.ID).Update("username", normalized).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.AutoMigrate(&userV1{})
+}
+
+// usernameCollisions reports the users whose names normalize to the same
+// username, which the unique index cannot be built over. They have to be
+// renamed by hand before migrating.
+func usernameCollisions(users []userV1) error {
+	claimed := make(map[string][]userV1)
+	var names []string
+	for _, user := range users {
+		normalized := NormalizeUsername(user.Username)
+		if len(claimed[normalized]) == 1 {
+			names = append(names, normalized)
+		}
+		claimed[normalized] = append(claimed[normalized], user)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	problems := make([]string, len(names))
+	for i, name := range names {
+		var owners []string
+		for _, user := range claimed[name] {
+			owners = append(owners, user.ID+" "+strconv.Quote(user.Username))
+		}
+		problems[i] = strconv.Quote(name) + " is claimed by " + strings.Join(owners, ", ")
+	}
+	return errors.New("usernames collide after normalization, rename all but one of each before migrating: " + strings.Join(problems, "; "))
+}
+
+func migrateCreateRolesAndUsersDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&userV1{}, &roleV1{})
+}
+
+type userV2 struct {
+	ID                 string `gorm:"primaryKey"`
+	Username           string `gorm:"not null;size:255;uniqueIndex"`
+	Email              string
+	Password           []byte
+	RoleID             string `gorm:"index"`
+	Role               roleV1 `gorm:"foreignKey:RoleID"`
+	LoginAttempts      uint
+	LastLogin          time.Time
+	ForcePasswordReset bool
+	DisableAccount     bool
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
+}
+
+func (userV2) TableName() string {
+	return "users"
+}
+
+// migrateUserRoleForeignKeyUp replaces the role columns embedded in every
+// user row with a role_id foreign key. Each user is linked to the role whose
+// name matches its embedded copy, or to NO_PERMISSIONS if none does.
+func migrateUserRoleForeignKeyUp(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&userV2{})
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(`UPDATE users SET role_id = (
+		SELECT MIN(roles.id) FROM roles
+		WHERE roles.role_name = users.role_name AND roles.deleted_at IS NULL
+	)`).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(`UPDATE users SET role_id = (
+		SELECT MIN(roles.id) FROM roles
+		WHERE roles.role_name = ? AND roles.deleted_at IS NULL
+	) WHERE role_id IS NULL`, "NO_PERMISSIONS").Error
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&userV1{}, "role_name")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&userV1{}, "permissions")
+	if err != nil {
+		return err
+	}
+	// sqlite drops columns by rebuilding the table, which loses its indexes.
+	return tx.AutoMigrate(&userV2{})
+}
+
+func migrateUserRoleForeignKeyDown(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&userV1{})
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(`UPDATE users SET
+		role_name = (SELECT roles.role_name FROM roles WHERE roles.id = users.role_id),
+		permissions = COALESCE((SELECT roles.permissions FROM roles WHERE roles.id = users.role_id), 0)`).Error
+	if err != nil {
+		return err
+	}
+	if tx.Migrator().HasIndex(&userV2{}, "RoleID") {
+		err = tx.Migrator().DropIndex(&userV2{}, "RoleID")
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Migrator().DropConstraint(&userV2{}, "Role")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&userV2{}, "role_id")
+	if err != nil {
+		return err
+	}
+	return tx.AutoMigrate(&userV1{})
+}
+
+type participantV3 struct {
+	ID        string `gorm:"primaryKey"`
+	FirstName string `gorm:"not null;size:255"`
+	LastName  string `gorm:"size:255"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (participantV3) TableName() string {
+	return "participants"
+}
+
+type pointEntryV3 struct {
+	ID            string        `gorm:"primaryKey"`
+	ParticipantID string        `gorm:"not null;index"`
+	Participant   participantV3 `gorm:"foreignKey:ParticipantID"`
+	Points        int64         `gorm:"not null"`
+	Reason        string        `gorm:"size:255"`
+	CreatedAt     time.Time
+}
+
+func (pointEntryV3) TableName() string {
+	return "point_entries"
+}
+
+type participantBalanceV3 struct {
+	ParticipantID string        `gorm:"primaryKey"`
+	Participant   participantV3 `gorm:"foreignKey:ParticipantID"`
+	Earned        int64         `gorm:"not null"`
+	Spent         int64         `gorm:"not null"`
+	Balance       int64         `gorm:"not null"`
+	UpdatedAt     time.Time
+}
+
+func (participantBalanceV3) TableName() string {
+	return "participant_balances"
+}
+
+func migrateCreatePointsLedgerUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&participantV3{}, &pointEntryV3{}, &participantBalanceV3{})
+}
+
+func migrateCreatePointsLedgerDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&participantBalanceV3{}, &pointEntryV3{}, &participantV3{})
+}
+
+type teamV4 struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string `gorm:"not null;size:255;uniqueIndex"`
+	Color     string `gorm:"size:32"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (teamV4) TableName() string {
+	return "teams"
+}
+
+type teamMembershipV4 struct {
+	ID            string        `gorm:"primaryKey"`
+	TeamID        string        `gorm:"not null;index"`
+	Team          teamV4        `gorm:"foreignKey:TeamID"`
+	ParticipantID string        `gorm:"not null;index"`
+	Participant   participantV3 `gorm:"foreignKey:ParticipantID"`
+	JoinedAt      time.Time     `gorm:"not null"`
+	LeftAt        *time.Time
+}
+
+func (teamMembershipV4) TableName() string {
+	return "team_memberships"
+}
+
+type teamBonusV4 struct {
+	ID        string `gorm:"primaryKey"`
+	TeamID    string `gorm:"not null;index"`
+	Team      teamV4 `gorm:"foreignKey:TeamID"`
+	Points    int64  `gorm:"not null"`
+	Reason    string `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+func (teamBonusV4) TableName() string {
+	return "team_bonuses"
+}
+
+func migrateCreateTeamsUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&teamV4{}, &teamMembershipV4{}, &teamBonusV4{})
+}
+
+func migrateCreateTeamsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&teamBonusV4{}, &teamMembershipV4{}, &teamV4{})
+}
+
+// initialSeasonName is the season migration 5 opens for the existing ledger.
+const initialSeasonName = "Season 1"
+
+type seasonV5 struct {
+	ID        string    `gorm:"primaryKey"`
+	Name      string    `gorm:"not null;size:255;uniqueIndex"`
+	StartedAt time.Time `gorm:"not null"`
+	ClosedAt  *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (seasonV5) TableName() string {
+	return "seasons"
+}
+
+type seasonTotalV5 struct {
+	SeasonID      string        `gorm:"primaryKey"`
+	Season        seasonV5      `gorm:"foreignKey:SeasonID"`
+	ParticipantID string        `gorm:"primaryKey"`
+	Participant   participantV3 `gorm:"foreignKey:ParticipantID"`
+	Earned        int64         `gorm:"not null"`
+	Spent         int64         `gorm:"not null"`
+	Balance       int64         `gorm:"not null"`
+	CarriedOver   int64         `gorm:"not null"`
+}
+
+func (seasonTotalV5) TableName() string {
+	return "season_totals"
+}
+
+type pointEntryV5 struct {
+	ID            string `gorm:"primaryKey"`
+	SeasonID      string `gorm:"index"`
+	ParticipantID string `gorm:"not null;index"`
+	Points        int64  `gorm:"not null"`
+	Reason        string `gorm:"size:255"`
+	CarryOver     bool
+	CreatedAt     time.Time
+}
+
+func (pointEntryV5) TableName() string {
+	return "point_entries"
+}
+
+type teamBonusV5 struct {
+	ID        string `gorm:"primaryKey"`
+	SeasonID  string `gorm:"index"`
+	TeamID    string `gorm:"not null;index"`
+	Points    int64  `gorm:"not null"`
+	Reason    string `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+func (teamBonusV5) TableName() string {
+	return "team_bonuses"
+}
+
+// migrateCreateSeasonsUp opens the first season and assigns the whole
+// existing ledger and every team bonus to it.
+func migrateCreateSeasonsUp(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&seasonV5{}, &seasonTotalV5{})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	season := seasonV5{ID: uuid.NewString(), Name: initialSeasonName, StartedAt: now, CreatedAt: now, UpdatedAt: now}
+	err = tx.Create(&season).Error
+	if err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&pointEntryV5{}, &teamBonusV5{}} {
+		err = tx.Migrator().AddColumn(model, "SeasonID")
+		if err != nil {
+			return err
+		}
+		err = tx.Model(model).Where("1 = 1").Update("season_id", season.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Migrator().CreateIndex(model, "SeasonID")
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Migrator().AddColumn(&pointEntryV5{}, "CarryOver")
+	if err != nil {
+		return err
+	}
+	return tx.Model(&pointEntryV5{}).Where("1 = 1").Update("carry_over", false).Error
+}
+
+func migrateCreateSeasonsDown(tx *gorm.DB) error {
+	var open seasonV5
+	err := tx.Where("closed_at IS NULL").First(&open).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("no season is open, reverting would delete the whole ledger")
+	}
+	if err != nil {
+		return err
+	}
+	// Only the open season's ledger matches the balances table, older
+	// seasons cannot be represented without seasons.
+	err = tx.Where("season_id <> ?", open.ID).Delete(&pointEntryV5{}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Where("season_id <> ?", open.ID).Delete(&teamBonusV5{}).Error
+	if err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&pointEntryV5{}, &teamBonusV5{}} {
+		err = tx.Migrator().DropIndex(model, "SeasonID")
+		if err != nil {
+			return err
+		}
+		err = tx.Migrator().DropColumn(model, "season_id")
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Migrator().DropColumn(&pointEntryV5{}, "carry_over")
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(&seasonTotalV5{}, &seasonV5{})
+}
+
+type categoryV6 struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string `gorm:"not null;size:255;uniqueIndex"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (categoryV6) TableName() string {
+	return "categories"
+}
+
+type pointEntryV6 struct {
+	ID            string `gorm:"primaryKey"`
+	SeasonID      string `gorm:"index"`
+	ParticipantID string `gorm:"not null;index"`
+	CategoryID    string `gorm:"index"`
+	Points        int64  `gorm:"not null"`
+	Reason        string `gorm:"size:255"`
+	CarryOver     bool
+	CreatedAt     time.Time
+}
+
+func (pointEntryV6) TableName() string {
+	return "point_entries"
+}
+
+type participantV6 struct {
+	ID                string `gorm:"primaryKey"`
+	FirstName         string `gorm:"not null;size:255"`
+	LastName          string `gorm:"size:255"`
+	LeaderboardOptOut bool   `gorm:"not null;default:false"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+func (participantV6) TableName() string {
+	return "participants"
+}
+
+// migrateLeaderboardsUp leaves the existing ledger uncategorized and every
+// participant on the leaderboards.
+func migrateLeaderboardsUp(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&categoryV6{})
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().AddColumn(&pointEntryV6{}, "CategoryID")
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&pointEntryV6{}).Where("1 = 1").Update("category_id", "").Error
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().CreateIndex(&pointEntryV6{}, "CategoryID")
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().AddColumn(&participantV6{}, "LeaderboardOptOut")
+}
+
+func migrateLeaderboardsDown(tx *gorm.DB) error {
+	err := tx.Migrator().DropColumn(&participantV6{}, "leaderboard_opt_out")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropIndex(&pointEntryV6{}, "CategoryID")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&pointEntryV6{}, "category_id")
+	if err != nil {
+		return err
+	}
+	err = restoreIndexes(tx, &participantV3{}, "DeletedAt")
+	if err != nil {
+		return err
+	}
+	err = restoreIndexes(tx, &pointEntryV5{}, "SeasonID", "ParticipantID")
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(&categoryV6{})
+}
+
+// restoreIndexes creates the indexes of fields that are missing. SQLite
+// drops a column by copying the table, which loses its other indexes.
+func restoreIndexes(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasIndex(model, field) {
+			continue
+		}
+		err := tx.Migrator().CreateIndex(model, field)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type catalogItemV7 struct {
+	ID             string `gorm:"primaryKey"`
+	Name           string `gorm:"not null;size:200;uniqueIndex"`
+	Description    string `gorm:"size:1024"`
+	ImageURL       string `gorm:"size:1024"`
+	Price          int64  `gorm:"not null"`
+	Stock          int    `gorm:"not null"`
+	PurchaseLimit  int    `gorm:"not null"`
+	AvailableFrom  *time.Time
+	AvailableUntil *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (catalogItemV7) TableName() string {
+	return "catalog_items"
+}
+
+type redemptionV7 struct {
+	ID            string        `gorm:"primaryKey"`
+	SeasonID      string        `gorm:"not null;index"`
+	ItemID        string        `gorm:"not null;index"`
+	Item          catalogItemV7 `gorm:"foreignKey:ItemID"`
+	ParticipantID string        `gorm:"not null;index"`
+	Participant   participantV6 `gorm:"foreignKey:ParticipantID"`
+	PointEntryID  string        `gorm:"not null"`
+	PointEntry    pointEntryV6  `gorm:"foreignKey:PointEntryID"`
+	Quantity      int           `gorm:"not null"`
+	Points        int64         `gorm:"not null"`
+	CreatedAt     time.Time
+}
+
+func (redemptionV7) TableName() string {
+	return "redemptions"
+}
+
+func migrateCreateCatalogUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&catalogItemV7{}, &redemptionV7{})
+}
+
+func migrateCreateCatalogDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&redemptionV7{}, &catalogItemV7{})
+}
+
+type catalogItemV8 struct {
+	ID             string `gorm:"primaryKey"`
+	Name           string `gorm:"not null;size:200;uniqueIndex"`
+	Description    string `gorm:"size:1024"`
+	ImageURL       string `gorm:"size:1024"`
+	Price          int64  `gorm:"not null"`
+	Stock          int    `gorm:"not null"`
+	PurchaseLimit  int    `gorm:"not null"`
+	AvailableFrom  *time.Time
+	AvailableUntil *time.Time
+	Version        int `gorm:"not null;default:1"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (catalogItemV8) TableName() string {
+	return "catalog_items"
+}
+
+type redemptionV8 struct {
+	ID            string `gorm:"primaryKey"`
+	SeasonID      string `gorm:"not null;index"`
+	ItemID        string `gorm:"not null;index"`
+	ParticipantID string `gorm:"not null;index"`
+	PointEntryID  string `gorm:"not null"`
+	ReceiptNumber string `gorm:"size:36;index"`
+	Quantity      int    `gorm:"not null"`
+	Points        int64  `gorm:"not null"`
+	CreatedAt     time.Time
+}
+
+func (redemptionV8) TableName() string {
+	return "redemptions"
+}
+
+// migrateCatalogVersionUp starts every item at version 1 and leaves earlier
+// redemptions without a receipt.
+func migrateCatalogVersionUp(tx *gorm.DB) error {
+	err := tx.Migrator().AddColumn(&catalogItemV8{}, "Version")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().AddColumn(&redemptionV8{}, "ReceiptNumber")
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&redemptionV8{}).Where("1 = 1").Update("receipt_number", "").Error
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().CreateIndex(&redemptionV8{}, "ReceiptNumber")
+}
+
+func migrateCatalogVersionDown(tx *gorm.DB) error {
+	err := tx.Migrator().DropIndex(&redemptionV8{}, "ReceiptNumber")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&redemptionV8{}, "receipt_number")
+	if err != nil {
+		return err
+	}
+	err = restoreIndexes(tx, &redemptionV7{}, "SeasonID", "ItemID", "ParticipantID")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&catalogItemV8{}, "version")
+	if err != nil {
+		return err
+	}
+	return restoreIndexes(tx, &catalogItemV7{}, "Name", "DeletedAt")
+}
+
+type eventV9 struct {
+	ID        string    `gorm:"primaryKey"`
+	Name      string    `gorm:"not null;size:255"`
+	StartsAt  time.Time `gorm:"not null;index"`
+	EndsAt    time.Time `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (eventV9) TableName() string {
+	return "events"
+}
+
+type awardBatchV9 struct {
+	ID         string `gorm:"primaryKey"`
+	SeasonID   string `gorm:"not null;index"`
+	EventID    string `gorm:"index"`
+	TeamID     string
+	Reason     string `gorm:"size:255"`
+	CreatedBy  string `gorm:"size:255"`
+	ReversedAt *time.Time
+	ReversedBy string `gorm:"size:255"`
+	CreatedAt  time.Time
+}
+
+func (awardBatchV9) TableName() string {
+	return "award_batches"
+}
+
+type pointEntryV9 struct {
+	ID            string `gorm:"primaryKey"`
+	SeasonID      string `gorm:"index"`
+	ParticipantID string `gorm:"not null;index"`
+	CategoryID    string `gorm:"index"`
+	BatchID       string `gorm:"index"`
+	Points        int64  `gorm:"not null"`
+	Reason        string `gorm:"size:255"`
+	CarryOver     bool
+	Reversal      bool
+	CreatedAt     time.Time
+}
+
+func (pointEntryV9) TableName() string {
+	return "point_entries"
+}
+
+// migrateAwardBatchesUp leaves the existing ledger outside of any batch.
+func migrateAwardBatchesUp(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&eventV9{}, &awardBatchV9{})
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().AddColumn(&pointEntryV9{}, "BatchID")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().AddColumn(&pointEntryV9{}, "Reversal")
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&pointEntryV9{}).Where("1 = 1").Updates(map[string]interface{}{"batch_id": "", "reversal": false}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().CreateIndex(&pointEntryV9{}, "BatchID")
+}
+
+func migrateAwardBatchesDown(tx *gorm.DB) error {
+	err := tx.Migrator().DropIndex(&pointEntryV9{}, "BatchID")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&pointEntryV9{}, "reversal")
+	if err != nil {
+		return err
+	}
+	err = tx.Migrator().DropColumn(&pointEntryV9{}, "batch_id")
+	if err != nil {
+		return err
+	}
+	err = restoreIndexes(tx, &pointEntryV6{}, "SeasonID", "ParticipantID", "CategoryID")
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(&awardBatchV9{}, &eventV9{})
+}
+
+type badgeV10 struct {
+	ID            string `gorm:"primaryKey"`
+	ParticipantID string `gorm:"not null;size:36;uniqueIndex:idx_badges_award"`
+	RuleKey       string `gorm:"not null;size:100;uniqueIndex:idx_badges_award"`
+	SeasonID      string `gorm:"not null;size:36;uniqueIndex:idx_badges_award"`
+	Period        string `gorm:"not null;size:7;uniqueIndex:idx_badges_award"`
+	Name          string `gorm:"size:255"`
+	PointEntryID  string
+	AwardedAt     time.Time
+}
+
+func (badgeV10) TableName() string {
+	return "badges"
+}
+
+func migrateCreateBadgesUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&badgeV10{})
+}
+
+func migrateCreateBadgesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&badgeV10{})
+}
+
+type guardianshipV11 struct {
+	ID            string `gorm:"primaryKey"`
+	UserID        string `gorm:"not null;size:36;uniqueIndex:idx_guardianships_link"`
+	ParticipantID string `gorm:"not null;size:36;uniqueIndex:idx_guardianships_link;index"`
+	CreatedAt     time.Time
+}
+
+func (guardianshipV11) TableName() string {
+	return "guardianships"
+}
+
+type guardianInviteV11 struct {
+	ID            string `gorm:"primaryKey"`
+	Email         string `gorm:"not null;size:255"`
+	ParticipantID string `gorm:"not null;index"`
+	TokenHash     string `gorm:"not null;size:64;uniqueIndex"`
+	InvitedBy     string `gorm:"size:255"`
+	ExpiresAt     time.Time
+	AcceptedAt    *time.Time
+	AcceptedBy    string `gorm:"size:255"`
+	CreatedAt     time.Time
+}
+
+func (guardianInviteV11) TableName() string {
+	return "guardian_invites"
+}
+
+func migrateCreateGuardiansUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&guardianshipV11{}, &guardianInviteV11{})
+}
+
+func migrateCreateGuardiansDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&guardianInviteV11{}, &guardianshipV11{})
+}
+
+type kioskDeviceV12 struct {
+	ID         string `gorm:"primaryKey"`
+	Name       string `gorm:"not null;size:100"`
+	TokenHash  string `gorm:"not null;size:64;uniqueIndex"`
+	CreatedBy  string `gorm:"size:255"`
+	LastSeenAt *time.Time
+	RevokedAt  *time.Time
+	RevokedBy  string `gorm:"size:255"`
+	CreatedAt  time.Time
+}
+
+func (kioskDeviceV12) TableName() string {
+	return "kiosk_devices"
+}
+
+type checkInV12 struct {
+	ID            string `gorm:"primaryKey"`
+	EventID       string `gorm:"not null;size:36;uniqueIndex:idx_check_ins_attendee"`
+	ParticipantID string `gorm:"not null;size:36;uniqueIndex:idx_check_ins_attendee;index"`
+	DeviceID      string `gorm:"index"`
+	BatchID       string
+	CreatedAt     time.Time
+}
+
+func (checkInV12) TableName() string {
+	return "check_ins"
+}
+
+func migrateCreateKiosksUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&kioskDeviceV12{}, &checkInV12{})
+}
+
+func migrateCreateKiosksDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&checkInV12{}, &kioskDeviceV12{})
+}
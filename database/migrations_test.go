@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type migrationTestTable struct {
+	ID   uint
+	Name string
+}
+
+func TestMigrateUp_ShouldApplyAllAndRecordThem(t *testing.T) {
+	repo := NewUserRepository(DbTemp(t), 0)
+
+	count, err := repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), count)
+	assert.True(t, repo.Database.Migrator().HasTable("users"))
+	assert.True(t, repo.Database.Migrator().HasTable("roles"))
+
+	statuses, err := repo.MigrationStatus(context.Background())
+	assert.Nil(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+	}
+
+	count, err = repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMigrateDown_ShouldRevertLastMigration(t *testing.T) {
+	repo := NewUserRepository(DbTemp(t), 0)
+	_, err := repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+
+	count, err := repo.MigrateDown(context.Background(), len(migrations))
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), count)
+	assert.False(t, repo.Database.Migrator().HasTable("users"))
+
+	statuses, err := repo.MigrationStatus(context.Background())
+	assert.Nil(t, err)
+	for _, status := range statuses {
+		assert.False(t, status.Applied)
+	}
+}
+
+func TestMigrateUp_ShouldRollBackFailedMigration(t *testing.T) {
+	db := DbTemp(t)
+	list := []Migration{
+		{
+			Version: 2,
+			Name:    "fails after creating a table",
+			Up: func(tx *gorm.DB) error {
+				err := tx.AutoMigrate(&migrationTestTable{})
+				if err != nil {
+					return err
+				}
+				return errors.New("boom")
+			},
+			Down: func(tx *gorm.DB) error { return nil },
+		},
+		{
+			Version: 1,
+			Name:    "creates nothing",
+			Up:      func(tx *gorm.DB) error { return nil },
+			Down:    func(tx *gorm.DB) error { return nil },
+		},
+	}
+
+	count, err := migrateUp(db, list)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, count)
+	assert.False(t, db.Migrator().HasTable(&migrationTestTable{}))
+
+	statuses, err := migrationStatus(db, list)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, statuses[0].Version)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+}
+
+func TestMigrateUp_ShouldRejectDuplicateVersions(t *testing.T) {
+	noop := func(tx *gorm.DB) error { return nil }
+	_, err := migrateUp(DbTemp(t), []Migration{
+		{Version: 1, Name: "a", Up: noop, Down: noop},
+		{Version: 1, Name: "b", Up: noop, Down: noop},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestMigrateUserRoleForeignKey_ShouldMapEmbeddedRolesByName(t *testing.T) {
+	db := DbTemp(t)
+	_, err := migrateUp(db, migrations[:1])
+	assert.Nil(t, err)
+	admin := roleV1{ID: "admin-role", RoleName: "ADMIN", Permissions: ADMIN}
+	none := roleV1{ID: "none-role", RoleName: "NO_PERMISSIONS", Permissions: NO_PERMISSIONS}
+	assert.Nil(t, db.Create(&admin).Error)
+	assert.Nil(t, db.Create(&none).Error)
+	assert.Nil(t, db.Exec("INSERT INTO users (id, username, role_name, permissions) VALUES (?, ?, ?, ?)", "u1", "admin", "ADMIN", ADMIN).Error)
+	assert.Nil(t, db.Exec("INSERT INTO users (id, username, role_name, permissions) VALUES (?, ?, ?, ?)", "u2", "orphan", "DELETED_ROLE", USER_READ).Error)
+
+	_, err = migrateUp(db, migrations[:2])
+	assert.Nil(t, err)
+
+	var users []userV2
+	assert.Nil(t, db.Order("id").Find(&users).Error)
+	assert.Equal(t, "admin-role", users[0].RoleID)
+	assert.Equal(t, "none-role", users[1].RoleID)
+	assert.False(t, db.Migrator().HasColumn(&userV1{}, "role_name"))
+	assert.True(t, db.Migrator().HasIndex(&userV2{}, "Username"))
+
+	_, err = migrateDown(db, migrations[:2], 1)
+	assert.Nil(t, err)
+	var restored []userV1
+	assert.Nil(t, db.Order("id").Find(&restored).Error)
+	assert.Equal(t, "ADMIN", restored[0].Role.RoleName)
+	assert.Equal(t, ADMIN, restored[0].Role.Permissions)
+	assert.False(t, db.Migrator().HasColumn(&userV2{}, "role_id"))
+}
+
+func TestMigrateRolesAndUsers_ShouldReportCollidingUsernames(t *testing.T) {
+	db := DbTemp(t)
+	assert.Nil(t, db.Exec("CREATE TABLE users (id text PRIMARY KEY, username text NOT NULL)").Error)
+	assert.Nil(t, db.Exec("INSERT INTO users (id, username) VALUES ('u1', 'Ada'), ('u2', 'ada'), ('u3', 'Bob')").Error)
+
+	_, err := migrateUp(db, migrations[:1])
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `"ada" is claimed by u1 "Ada", u2 "ada"`)
+	assert.NotContains(t, err.Error(), "u3")
+	var count int64
+	assert.Nil(t, db.Table("users").Where("username = ?", "Ada").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestLoadUser_ShouldReflectRoleChanges(t *testing.T) {
+	repo := NewUserRepository(DbTemp(t), 0)
+	_, err := repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+	role := Role{RoleName: "EDITOR", Permissions: USER_READ}
+	assert.Nil(t, repo.Database.Create(&role).Error)
+	assert.Nil(t, repo.SaveUser(context.Background(), User{Username: "editor", Role: role}))
+
+	role.Permissions = USER_READ | USER_WRITE
+	assert.Nil(t, repo.Database.Save(&role).Error)
+
+	user, err := repo.LoadUser(context.Background(), "editor")
+	assert.Nil(t, err)
+	assert.Equal(t, role.ID, user.RoleID)
+	assert.Equal(t, USER_READ|USER_WRITE, user.Role.Permissions)
+}
+
+func TestMigrateSeasons_ShouldAssignExistingLedgerToFirstSeason(t *testing.T) {
+	db := DbTemp(t)
+	_, err := migrateUp(db, migrations[:4])
+	assert.Nil(t, err)
+	participant := participantV3{ID: "p1", FirstName: "Ada"}
+	assert.Nil(t, db.Create(&participant).Error)
+	assert.Nil(t, db.Create(&pointEntryV3{ID: "e1", ParticipantID: "p1", Points: 5}).Error)
+
+	_, err = migrateUp(db, migrations[:5])
+	assert.Nil(t, err)
+	var season seasonV5
+	assert.Nil(t, db.Where("closed_at IS NULL").First(&season).Error)
+	assert.Equal(t, initialSeasonName, season.Name)
+	var entry pointEntryV5
+	assert.Nil(t, db.First(&entry, "id = ?", "e1").Error)
+	assert.Equal(t, season.ID, entry.SeasonID)
+	assert.False(t, entry.CarryOver)
+
+	// Without an open season there is no ledger left to keep.
+	assert.Nil(t, db.Model(&seasonV5{}).Where("id = ?", season.ID).Update("closed_at", time.Now()).Error)
+	_, err = migrateDown(db, migrations[:5], 1)
+	assert.NotNil(t, err)
+	assert.True(t, db.Migrator().HasTable(&seasonV5{}))
+	assert.Nil(t, db.Model(&seasonV5{}).Where("id = ?", season.ID).Update("closed_at", nil).Error)
+
+	_, err = migrateDown(db, migrations[:5], 1)
+	assert.Nil(t, err)
+	assert.False(t, db.Migrator().HasTable(&seasonV5{}))
+	assert.False(t, db.Migrator().HasColumn(&pointEntryV5{}, "season_id"))
+	var count int64
+	assert.Nil(t, db.Model(&pointEntryV3{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
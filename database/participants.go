@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Participant is a club member who earns and spends points. Participants
+// with LeaderboardOptOut set are left off every leaderboard.
+type Participant struct {
+	ID                string `gorm:"primaryKey"`
+	FirstName         string `gorm:"not null;size:255"`
+	LastName          string `gorm:"size:255"`
+	LeaderboardOptOut bool   `gorm:"not null;default:false"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+func (participant *Participant) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	participant.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", participant.ID)
+	return
+}
+
+// SaveParticipant creates the participant when it has no ID yet, otherwise
+// updates it. The stored participant is returned.
+func (r *UserRepository) SaveParticipant(ctx context.Context, participant Participant) (Participant, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if participant.ID == "" {
+		err = db.Create(&participant).Error
+	} else {
+		err = db.Save(&participant).Error
+	}
+	return participant, err
+}
+
+func (r *UserRepository) LoadParticipant(ctx context.Context, id string) (Participant, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var participant Participant
+	err := db.Where("id = ?", id).First(&participant).Error
+	return participant, err
+}
+
+// SearchParticipants finds participants by a scanned ID or by name. Every
+// word of text must start a first or last name, case insensitively. The
+// search is read from a replica when one is configured.
+func (r *UserRepository) SearchParticipants(ctx context.Context, text string, limit int) ([]Participant, error) {
+	db, cancel := r.reportSession(ctx)
+	defer cancel()
+	var participants []Participant
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return participants, nil
+	}
+	err := db.Where("id = ?", text).Find(&participants).Error
+	if err != nil || len(participants) > 0 {
+		return participants, err
+	}
+	query := db.Order("last_name, first_name").Limit(limit)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		prefix := likeEscaper.Replace(word) + "%"
+		query = query.Where("LOWER(first_name) LIKE ? ESCAPE '!' OR LOWER(last_name) LIKE ? ESCAPE '!'", prefix, prefix)
+	}
+	err = query.Find(&participants).Error
+	return participants, err
+}
+
+// likeEscaper makes the LIKE wildcards of typed text match themselves,
+// "[" included for SQL Server. The escape character is "!" because a
+// backslash needs quoting differently in every dialect.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_", "[", "![")
+
+// Participants lists everyone by name, read from a replica when one is
+// configured.
+func (r *UserRepository) Participants(ctx context.Context) ([]Participant, error) {
+	db, cancel := r.reportSession(ctx)
+	defer cancel()
+	var participants []Participant
+	err := db.Order("last_name, first_name").Find(&participants).Error
+	return participants, err
+}
+
+// Entries returns the latest ledger entries of a participant across all
+// seasons, newest first.
+func (r *UserRepository) Entries(ctx context.Context, participantID string, limit int) ([]PointEntry, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var entries []PointEntry
+	err := db.Where("participant_id = ?", participantID).Order("created_at DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
@@ -1,5 +1,11 @@
 package database
 
+import "errors"
+
+// ErrPermissionDenied is returned when the acting user's role lacks the
+// permission an operation requires.
+var ErrPermissionDenied = errors.New("permission denied")
+
 type permission uint64
 
 const (
@@ -17,6 +23,8 @@ const (
 	CATEGORY_WRITE     permission = (1 << (iota))
 	EVENT_READ         permission = (1 << (iota))
 	EVENT_WRITE        permission = (1 << (iota))
+	GUARDIAN_READ      permission = (1 << (iota))
+	KIOSK_WRITE        permission = (1 << (iota))
 )
 
 func Set(value permission, flag permission) permission {
@@ -26,3 +34,21 @@ func Set(value permission, flag permission) permission {
 func Unset(value permission, flag permission) permission {
 	return value & ^flag
 }
+
+// SystemUser is the actor for maintenance commands run by the operator from
+// the command line.
+var SystemUser = User{Username: "system", Role: Role{RoleName: "ADMIN", Permissions: ADMIN}}
+
+// HasPermission reports whether the role grants every bit of flag. Admins
+// are granted everything.
+func (r Role) HasPermission(flag permission) bool {
+	return r.Permissions&ADMIN != 0 || r.Permissions&flag == flag
+}
+
+// requirePermission returns ErrPermissionDenied unless actor may use flag.
+func requirePermission(actor User, flag permission) error {
+	if actor.DisableAccount || !actor.Role.HasPermission(flag) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
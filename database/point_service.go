@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// PointService records earned and spent points. Every ledger write updates
+// the participant's materialized balance in the same transaction, so pages
+// read balances without summing the ledger.
+type PointService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewPointService(stores Stores, tx TxRunner) *PointService {
+	return &PointService{stores: stores, tx: tx}
+}
+
+// BalanceDrift is a stored balance that does not match the ledger.
+type BalanceDrift struct {
+	Stored   ParticipantBalance
+	Expected ParticipantBalance
+}
+
+func validatePoints(points int64) error {
+	if points <= 0 {
+		return ValidationErrorNew("points", "points must be positive", INVALID_VALUE_CODE)
+	}
+	return nil
+}
+
+// AwardPoints adds uncategorized points to a participant's balance in the
+// open season.
+func (s *PointService) AwardPoints(ctx context.Context, participantID string, points int64, reason string) (PointEntry, error) {
+	return s.AwardCategoryPoints(ctx, participantID, "", points, reason)
+}
+
+// AwardCategoryPoints is AwardPoints for points earned in a category, an
+// empty categoryID leaves them uncategorized.
+func (s *PointService) AwardCategoryPoints(ctx context.Context, participantID string, categoryID string, points int64, reason string) (PointEntry, error) {
+	err := validatePoints(points)
+	if err != nil {
+		return PointEntry{}, err
+	}
+	var entry PointEntry
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		_, err := tx.Points.LoadParticipant(ctx, participantID)
+		if err != nil {
+			return err
+		}
+		if categoryID != "" {
+			_, err = tx.Categories.LoadCategory(ctx, categoryID)
+			if err != nil {
+				return err
+			}
+		}
+		season, err := tx.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return err
+		}
+		entry, err = recordPoints(ctx, tx, PointEntry{SeasonID: season.ID, ParticipantID: participantID, CategoryID: categoryID, Points: points, Reason: reason})
+		return err
+	})
+	return entry, err
+}
+
+// SpendPoints takes points from a participant's balance, which may not go
+// below zero.
+func (s *PointService) SpendPoints(ctx context.Context, participantID string, points int64, reason string) (PointEntry, error) {
+	err := validatePoints(points)
+	if err != nil {
+		return PointEntry{}, err
+	}
+	var entry PointEntry
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		entry, err = spendPoints(ctx, tx, participantID, points, reason)
+		return err
+	})
+	return entry, err
+}
+
+// spendPoints records a spend in the open season with the stores of a
+// running transaction, refusing to take the balance below zero.
+func spendPoints(ctx context.Context, tx Stores, participantID string, points int64, reason string) (PointEntry, error) {
+	_, err := tx.Points.LoadParticipant(ctx, participantID)
+	if err != nil {
+		return PointEntry{}, err
+	}
+	season, err := tx.Seasons.CurrentSeason(ctx)
+	if err != nil {
+		return PointEntry{}, err
+	}
+	entry, err := tx.Points.SpendPoints(ctx, PointEntry{SeasonID: season.ID, ParticipantID: participantID, Points: -points, Reason: reason})
+	if !errors.Is(err, ErrInsufficientPoints) {
+		return entry, err
+	}
+	balance, err := tx.Points.LoadBalance(ctx, participantID)
+	if err != nil {
+		return PointEntry{}, err
+	}
+	return PointEntry{}, ValidationErrorNew("points", "not enough points, the balance is "+strconv.FormatInt(balance.Balance, 10), INSUFFICIENT_POINTS_CODE)
+}
+
+func (s *PointService) Balance(ctx context.Context, participantID string) (ParticipantBalance, error) {
+	return s.stores.Points.LoadBalance(ctx, participantID)
+}
+
+// Reconcile recomputes every balance from the open season's ledger and
+// returns those that drifted from it. With fix set the drifted balances are
+// overwritten with the recomputed ones in the same transaction.
+func (s *PointService) Reconcile(ctx context.Context, fix bool) ([]BalanceDrift, error) {
+	var drifts []BalanceDrift
+	err := s.tx.WithinTx(ctx, func(tx Stores) error {
+		season, err := tx.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return err
+		}
+		// The balances are locked before the ledger is summed: entries
+		// written meanwhile wait for the lock to add to the balances and
+		// are neither counted twice nor lost when drifts are overwritten.
+		balances, err := tx.Points.LockBalances(ctx, season.ID)
+		if err != nil {
+			return err
+		}
+		totals, err := tx.Points.LedgerTotals(ctx, season.ID)
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]ParticipantBalance, len(balances))
+		for _, balance := range balances {
+			stored[balance.ParticipantID] = balance
+		}
+		drifts = nil
+		for _, total := range totals {
+			balance, ok := stored[total.ParticipantID]
+			delete(stored, total.ParticipantID)
+			if !ok {
+				balance.ParticipantID = total.ParticipantID
+			}
+			if !sameTotals(balance, total) {
+				drifts = append(drifts, BalanceDrift{Stored: balance, Expected: total})
+			}
+		}
+		// Whatever is left has no ledger entries at all and must be zero.
+		for _, balance := range balances {
+			if _, ok := stored[balance.ParticipantID]; !ok {
+				continue
+			}
+			expected := ParticipantBalance{ParticipantID: balance.ParticipantID}
+			if !sameTotals(balance, expected) {
+				drifts = append(drifts, BalanceDrift{Stored: balance, Expected: expected})
+			}
+		}
+		if !fix {
+			return nil
+		}
+		for _, drift := range drifts {
+			err = tx.Points.SaveBalance(ctx, drift.Expected)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return drifts, err
+}
+
+func sameTotals(a ParticipantBalance, b ParticipantBalance) bool {
+	return a.Earned == b.Earned && a.Spent == b.Spent && a.Balance == b.Balance
+}
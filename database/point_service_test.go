@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointService_ShouldKeepBalanceWithLedger(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		service := NewPointService(stores, tx)
+		participant, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+
+		_, err = service.AwardPoints(ctx, participant.ID, 10, "attendance")
+		assert.Nil(t, err)
+		_, err = service.AwardPoints(ctx, participant.ID, 5, "memory verse")
+		assert.Nil(t, err)
+		_, err = service.SpendPoints(ctx, participant.ID, 12, "store")
+		assert.Nil(t, err)
+
+		balance, err := service.Balance(ctx, participant.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(15), balance.Earned)
+		assert.Equal(t, int64(12), balance.Spent)
+		assert.Equal(t, int64(3), balance.Balance)
+
+		_, err = service.SpendPoints(ctx, participant.ID, 4, "store")
+		assert.Equal(t, int(INSUFFICIENT_POINTS_CODE), err.(*ValidationError).ErrorCode())
+		_, err = service.AwardPoints(ctx, participant.ID, 0, "nothing")
+		assert.Equal(t, int(INVALID_VALUE_CODE), err.(*ValidationError).ErrorCode())
+		_, err = service.AwardPoints(ctx, "missing", 1, "nobody")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		balance, err = service.Balance(ctx, participant.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), balance.Balance)
+	})
+}
+
+func TestSpendPoints_ShouldRefuseWhatTheBalanceDoesNotCover(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := stores.Points
+		participant, err := points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		season, err := stores.Seasons.CurrentSeason(ctx)
+		assert.Nil(t, err)
+
+		// Without a balance row there is nothing to spend.
+		_, err = points.SpendPoints(ctx, PointEntry{SeasonID: season.ID, ParticipantID: participant.ID, Points: -1})
+		assert.ErrorIs(t, err, ErrInsufficientPoints)
+		_, err = points.RecordPoints(ctx, PointEntry{SeasonID: season.ID, ParticipantID: participant.ID, Points: 5})
+		assert.Nil(t, err)
+		_, err = points.SpendPoints(ctx, PointEntry{SeasonID: season.ID, ParticipantID: participant.ID, Points: -5})
+		assert.Nil(t, err)
+		_, err = points.SpendPoints(ctx, PointEntry{SeasonID: season.ID, ParticipantID: participant.ID, Points: -1})
+		assert.ErrorIs(t, err, ErrInsufficientPoints)
+
+		totals, err := points.LedgerTotals(ctx, season.ID)
+		assert.Nil(t, err)
+		assert.Len(t, totals, 1)
+		assert.Equal(t, int64(5), totals[0].Spent)
+		balance, err := points.LoadBalance(ctx, participant.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), balance.Spent)
+		assert.Equal(t, int64(0), balance.Balance)
+	})
+}
+
+func TestPointService_ReconcileShouldReportAndFixDrift(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		service := NewPointService(stores, tx)
+		points := stores.Points
+		ada, err := points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		bob, err := points.SaveParticipant(ctx, Participant{FirstName: "Bob"})
+		assert.Nil(t, err)
+		_, err = service.AwardPoints(ctx, ada.ID, 10, "attendance")
+		assert.Nil(t, err)
+
+		drifts, err := service.Reconcile(ctx, false)
+		assert.Nil(t, err)
+		assert.Empty(t, drifts)
+
+		// Simulate writes that bypassed the ledger.
+		assert.Nil(t, points.SaveBalance(ctx, ParticipantBalance{ParticipantID: ada.ID, Earned: 10, Balance: 7}))
+		assert.Nil(t, points.SaveBalance(ctx, ParticipantBalance{ParticipantID: bob.ID, Earned: 2, Balance: 2}))
+
+		drifts, err = service.Reconcile(ctx, false)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(drifts))
+		balance, err := service.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), balance.Balance)
+
+		drifts, err = service.Reconcile(ctx, true)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(drifts))
+		balance, err = service.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), balance.Balance)
+		balance, err = service.Balance(ctx, bob.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), balance.Balance)
+
+		drifts, err = service.Reconcile(ctx, false)
+		assert.Nil(t, err)
+		assert.Empty(t, drifts)
+	})
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientPoints is returned for a spend larger than the balance.
+var ErrInsufficientPoints = errors.New("not enough points")
+
+// PointEntry is one line of the append-only points ledger. Earned points
+// are positive, spent points negative. CarryOver marks the opening entry
+// brought over from the previous season, which is not counted as earned in
+// team totals or leaderboards. CategoryID is empty for uncategorized entries.
+//
+// Entries of a bulk award share its BatchID. Reversing the batch appends a
+// Reversal entry with the negated points for each of them; reversals lower
+// the earned total rather than count as spent.
+type PointEntry struct {
+	ID            string `gorm:"primaryKey"`
+	SeasonID      string `gorm:"index"`
+	ParticipantID string `gorm:"not null;index"`
+	CategoryID    string `gorm:"index"`
+	BatchID       string `gorm:"index"`
+	Points        int64  `gorm:"not null"`
+	Reason        string `gorm:"size:255"`
+	CarryOver     bool
+	Reversal      bool
+	CreatedAt     time.Time
+}
+
+func (entry *PointEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	entry.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", entry.ID)
+	return
+}
+
+// ParticipantBalance is the materialized sum of a participant's ledger in
+// the open season, kept up to date by every RecordPoints call. Spent is a
+// positive number and Balance is always Earned - Spent.
+type ParticipantBalance struct {
+	ParticipantID string `gorm:"primaryKey"`
+	Earned        int64  `gorm:"not null"`
+	Spent         int64  `gorm:"not null"`
+	Balance       int64  `gorm:"not null"`
+	UpdatedAt     time.Time
+}
+
+// balanceOf returns the change entry makes to a balance.
+func balanceOf(entry PointEntry) ParticipantBalance {
+	balance := ParticipantBalance{ParticipantID: entry.ParticipantID, Balance: entry.Points}
+	if entry.Points > 0 || entry.Reversal {
+		balance.Earned = entry.Points
+	} else {
+		balance.Spent = -entry.Points
+	}
+	return balance
+}
+
+// RecordPoints appends entry to the ledger and applies it to the
+// participant's balance in the same transaction. The entry's season must be
+// open.
+func (r *UserRepository) RecordPoints(ctx context.Context, entry PointEntry) (PointEntry, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := requireOpenSeason(tx, entry.SeasonID)
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&entry).Error
+		if err != nil {
+			return err
+		}
+		return addToBalance(tx, balanceOf(entry))
+	})
+	return entry, err
+}
+
+// SpendPoints appends a spend to the ledger and takes it from the
+// participant's balance in the same transaction. The balance is only
+// lowered while it covers the spend, so concurrent spends can't take it
+// below zero.
+func (r *UserRepository) SpendPoints(ctx context.Context, entry PointEntry) (PointEntry, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := requireOpenSeason(tx, entry.SeasonID)
+		if err != nil {
+			return err
+		}
+		change := balanceOf(entry)
+		record := tx.Model(&ParticipantBalance{}).
+			Where("participant_id = ? AND balance >= ?", entry.ParticipantID, -entry.Points).
+			Updates(map[string]interface{}{
+				"spent":   gorm.Expr("spent + ?", change.Spent),
+				"balance": gorm.Expr("balance + ?", change.Balance),
+			})
+		if record.Error != nil {
+			return record.Error
+		}
+		if record.RowsAffected == 0 {
+			return ErrInsufficientPoints
+		}
+		return tx.Create(&entry).Error
+	})
+	return entry, err
+}
+
+// addToBalance adds change to the stored balance, creating the row for the
+// participant's first entry. The upsert is a single statement so two first
+// entries can't both try to create the row.
+func addToBalance(tx *gorm.DB, change ParticipantBalance) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "participant_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"earned":     gorm.Expr("participant_balances.earned + ?", change.Earned),
+			"spent":      gorm.Expr("participant_balances.spent + ?", change.Spent),
+			"balance":    gorm.Expr("participant_balances.balance + ?", change.Balance),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&change).Error
+}
+
+// LoadBalance returns the stored balance of a participant, which is zero
+// before their first ledger entry.
+func (r *UserRepository) LoadBalance(ctx context.Context, participantID string) (ParticipantBalance, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var balances []ParticipantBalance
+	err := db.Where("participant_id = ?", participantID).Limit(1).Find(&balances).Error
+	if err != nil || len(balances) == 0 {
+		return ParticipantBalance{ParticipantID: participantID}, err
+	}
+	return balances[0], nil
+}
+
+func (r *UserRepository) Balances(ctx context.Context) ([]ParticipantBalance, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var balances []ParticipantBalance
+	err := db.Order("participant_id").Find(&balances).Error
+	return balances, err
+}
+
+func (r *UserRepository) LockBalances(ctx context.Context, seasonID string) ([]ParticipantBalance, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var balances []ParticipantBalance
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := requireOpenSeason(tx, seasonID)
+		if err != nil {
+			return err
+		}
+		return updateLock(tx, "participant_balances").Order("participant_id").Find(&balances).Error
+	})
+	return balances, err
+}
+
+// updateLock reads table with an update lock held until the transaction
+// ends, so the rows read cannot change before they are written. SQLite has
+// no row locks, a write transaction locks the database.
+func updateLock(tx *gorm.DB, table string) *gorm.DB {
+	if tx.Dialector.Name() == "sqlserver" {
+		return tx.Table(table + " WITH (UPDLOCK, HOLDLOCK, ROWLOCK)")
+	}
+	return tx.Table(table).Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// SaveBalance overwrites a stored balance, it is meant for reconciliation
+// only.
+func (r *UserRepository) SaveBalance(ctx context.Context, balance ParticipantBalance) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	return db.Save(&balance).Error
+}
+
+// DeleteBalances removes every stored balance, it is used when a season is
+// closed.
+func (r *UserRepository) DeleteBalances(ctx context.Context) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	return db.Where("1 = 1").Delete(&ParticipantBalance{}).Error
+}
+
+// LedgerTotals sums the ledger of a season per participant.
+func (r *UserRepository) LedgerTotals(ctx context.Context, seasonID string) ([]ParticipantBalance, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var totals []ParticipantBalance
+	err := db.Model(&PointEntry{}).
+		Select(`participant_id,
+			SUM(CASE WHEN points > 0 OR reversal = ? THEN points ELSE 0 END) AS earned,
+			SUM(CASE WHEN points < 0 AND reversal = ? THEN -points ELSE 0 END) AS spent,
+			SUM(points) AS balance`, true, false).
+		Where("season_id = ?", seasonID).
+		Group("participant_id").
+		Order("participant_id").
+		Scan(&totals).Error
+	return totals, err
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"blue-beetle/config"
+	"blue-beetle/logging"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReplicaSet routes reporting queries to read replicas of the primary
+// database. Replicas that failed their last health check are skipped and
+// the primary serves the reads while none is healthy.
+//
+// Only sessions that ask for the replicas resolver use them, see
+// UserRepository.reportSession; every other query stays on the primary so
+// callers always read their own writes.
+type ReplicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     uint32
+}
+
+// replicasResolver names the dbresolver configuration of the replicas.
+const replicasResolver = "replicas"
+
+type replica struct {
+	sqlDB   *sql.DB
+	monitor *HealthMonitor
+}
+
+func (r *replica) Ping(ctx context.Context) error {
+	return r.sqlDB.PingContext(ctx)
+}
+
+// usable reports whether reads may go to the replica. Until the first check
+// has passed the primary serves the reads.
+func (r *replica) usable() bool {
+	return r.monitor.Status().Healthy
+}
+
+// ConnectReplicas opens the replicas of dbconfig and registers them as the
+// read side of db. Replicas that can't be reached are logged and left out,
+// so the server still starts on the primary. Without replicas an empty set
+// is returned and every query keeps using db.
+func ConnectReplicas(db *gorm.DB, dbconfig config.DBConfig) (*ReplicaSet, error) {
+	replicas := make([]*sql.DB, 0, len(dbconfig.Replicas))
+	for i := range dbconfig.Replicas {
+		replicaDB, err := ConnectDatabase(dbconfig.Replica(i))
+		if err == nil {
+			var sqlDB *sql.DB
+			sqlDB, err = replicaDB.DB()
+			if err == nil {
+				replicas = append(replicas, sqlDB)
+				continue
+			}
+		}
+		logging.Errorf("Database replica %d is unreachable, skipping it: %v", i, err)
+	}
+	if len(replicas) == 0 {
+		return &ReplicaSet{}, nil
+	}
+	set, err := newReplicaSet(db, strings.ToLower(dbconfig.Type), replicas, dbconfig.HealthCheckInterval)
+	if err != nil {
+		for _, sqlDB := range replicas {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+	return set, nil
+}
+
+func newReplicaSet(db *gorm.DB, dbType string, replicas []*sql.DB, interval time.Duration) (*ReplicaSet, error) {
+	primary, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	set := &ReplicaSet{primary: primary}
+	// The primary is listed as a replica too so the policy is consulted
+	// even with a single replica, the resolver skips it otherwise.
+	dialectors := []gorm.Dialector{newDialector(dbType, "", primary)}
+	for i, sqlDB := range replicas {
+		r := &replica{sqlDB: sqlDB}
+		r.monitor = NewHealthMonitor(r, interval)
+		r.monitor.name = "Database replica " + strconv.Itoa(i)
+		set.replicas = append(set.replicas, r)
+		dialectors = append(dialectors, newDialector(dbType, "", sqlDB))
+	}
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   set,
+	}, replicasResolver))
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Resolve picks the connection pool for a read. It implements
+// dbresolver.Policy, rotating over the healthy replicas.
+func (s *ReplicaSet) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(pools))
+	for _, pool := range pools {
+		for _, r := range s.replicas {
+			if pool == gorm.ConnPool(r.sqlDB) && r.usable() {
+				healthy = append(healthy, pool)
+			}
+		}
+	}
+	if len(healthy) == 0 {
+		return s.primary
+	}
+	i := atomic.AddUint32(&s.next, 1)
+	return healthy[int(i%uint32(len(healthy)))]
+}
+
+// Check pings every replica now.
+func (s *ReplicaSet) Check(ctx context.Context) {
+	for _, r := range s.replicas {
+		r.monitor.Check(ctx)
+	}
+}
+
+// Run checks the replicas until ctx is done. Without an interval they are
+// checked once and Run returns.
+func (s *ReplicaSet) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func(monitor *HealthMonitor) {
+			defer wg.Done()
+			monitor.Run(ctx)
+		}(r.monitor)
+	}
+	wg.Wait()
+}
+
+// Close closes the replica connection pools.
+func (s *ReplicaSet) Close() error {
+	var closeErr error
+	for _, r := range s.replicas {
+		err := r.sqlDB.Close()
+		if err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+var _ dbresolver.Policy = (*ReplicaSet)(nil)
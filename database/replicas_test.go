@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaSet_ShouldRouteReportsToHealthyReplicas(t *testing.T) {
+	ctx := context.Background()
+	primary := NewUserRepository(DbTemp(t), 0)
+	replicaRepo := NewUserRepository(DbTemp(t), 0)
+	for _, repo := range []*UserRepository{primary, replicaRepo} {
+		_, err := repo.MigrateUp(ctx)
+		assert.Nil(t, err)
+	}
+	// Different rows on each side show which database answered.
+	assert.Nil(t, primary.SaveUser(ctx, User{Username: "on-primary", Email: "primary@example.com", Password: []byte("x")}))
+	assert.Nil(t, replicaRepo.SaveUser(ctx, User{Username: "on-replica", Email: "replica@example.com", Password: []byte("x")}))
+	replicaDB, err := replicaRepo.Database.DB()
+	assert.Nil(t, err)
+
+	set, err := newReplicaSet(primary.Database, "sqlite", []*sql.DB{replicaDB}, 0)
+	assert.Nil(t, err)
+
+	reportUsername := func() string {
+		db, cancel := primary.reportSession(ctx)
+		defer cancel()
+		var user User
+		assert.Nil(t, db.First(&user).Error)
+		return user.Username
+	}
+	// An unchecked replica isn't trusted yet.
+	assert.Equal(t, "on-primary", reportUsername())
+
+	set.Check(ctx)
+	assert.Equal(t, "on-replica", reportUsername())
+	user, err := primary.LoadUser(ctx, "on-primary")
+	assert.Nil(t, err)
+	assert.Equal(t, "on-primary", user.Username)
+
+	assert.Nil(t, set.Close())
+	set.Check(ctx)
+	assert.Equal(t, "on-primary", reportUsername())
+}
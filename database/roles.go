@@ -25,18 +25,20 @@ func (role *Role) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
-func (r *UserRepository) SaveRole(role Role) error {
+func (r *UserRepository) SaveRole(ctx context.Context, role Role) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
 	var testRole Role
-	record := r.Database.WithContext(context.Background()).Where("role_name = ?", role.RoleName).First(&testRole)
-	var err error = nil
+	record := db.Where("role_name = ?", role.RoleName).First(&testRole)
 	if record.Error != nil && errors.Is(record.Error, gorm.ErrRecordNotFound) {
-		err = nil
-		recordC := r.Database.WithContext(context.Background()).Create(&role)
-		err = recordC.Error
+		record = db.Create(&role)
 	} else if record.Error == nil {
-		recordS := r.Database.WithContext(context.Background()).Save(&role)
-		err = recordS.Error
+		if role.ID == "" {
+			role.ID = testRole.ID
+		}
+		record = db.Save(&role)
 	}
+	err := record.Error
 	if err != nil {
 		return err
 	}
@@ -51,9 +53,11 @@ func (r *Role) UnsetPermission(flag permission) {
 	r.Permissions = r.Permissions & ^flag
 }
 
-func (r *UserRepository) LoadRole(name string) (Role, error) {
+func (r *UserRepository) LoadRole(ctx context.Context, name string) (Role, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
 	var role Role
-	record := r.Database.Where("role_name = ?", name).First(&role)
+	record := db.Where("role_name = ?", name).First(&role)
 	if record.Error != nil {
 		return role, record.Error
 	}
@@ -66,31 +70,3 @@ func (r *Role) BeforeDelete(tx *gorm.DB) (err error) {
 	}
 	return
 }
-
-func (r *UserRepository) MigrateRoleModel() error {
-	return UserRepo.Database.AutoMigrate(&Role{})
-}
-
-func (r *UserRepository) InitRoleModle() error {
-	var no_perm Role
-	record := r.Database.WithContext(context.Background()).Where("role_name = ?", "NO_PERMISSIONS").First(&no_perm)
-	if record.Error != nil && errors.Is(record.Error, gorm.ErrRecordNotFound) {
-		no_perm.RoleName = "NO_PERMISSIONS"
-		no_perm.Permissions = NO_PERMISSIONS
-		err := r.SaveRole(no_perm)
-		if err != nil {
-			return err
-		}
-	}
-	var admin Role
-	record = r.Database.WithContext(context.Background()).Where("role_name = ?", "ADMIN").First(&admin)
-	if record.Error != nil && errors.Is(record.Error, gorm.ErrRecordNotFound) {
-		admin.RoleName = "ADMIN"
-		admin.Permissions = ADMIN
-		err := r.SaveRole(admin)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
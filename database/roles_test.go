@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -9,11 +10,7 @@ import (
 )
 
 func TestFindRole_ShouldFindAdmin(t *testing.T) {
-	db := DbMock(t)
-
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
+	repo, _ := setupTestRepository(t)
 	Expected := Role{
 		ID:          uuid.NewString(),
 		RoleName:    "ADMIN",
@@ -22,17 +19,13 @@ func TestFindRole_ShouldFindAdmin(t *testing.T) {
 		UpdatedAt:   time.Now(),
 	}
 
-	rle, err := UserRepo.LoadRole("ADMIN")
+	rle, err := repo.LoadRole(context.Background(), "ADMIN")
 	assert.Nil(t, err)
 	Compare_Roles(t, Expected, rle)
 }
 
 func TestFindRole_ShouldFindNoPermissions(t *testing.T) {
-	db := DbMock(t)
-
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
+	repo, _ := setupTestRepository(t)
 	Expected := Role{
 		ID:          uuid.NewString(),
 		RoleName:    "NO_PERMISSIONS",
@@ -41,17 +34,13 @@ func TestFindRole_ShouldFindNoPermissions(t *testing.T) {
 		UpdatedAt:   time.Now(),
 	}
 
-	rle, err := UserRepo.LoadRole("NO_PERMISSIONS")
+	rle, err := repo.LoadRole(context.Background(), "NO_PERMISSIONS")
 	assert.Nil(t, err)
 	Compare_Roles(t, Expected, rle)
 }
 
 func TestAddRole_ShouldSucceed(t *testing.T) {
-	db := DbMock(t)
-
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
+	repo, _ := setupTestRepository(t)
 	Expected := Role{
 		ID:          uuid.NewString(),
 		RoleName:    "CATEGORY_WRITE",
@@ -60,10 +49,10 @@ func TestAddRole_ShouldSucceed(t *testing.T) {
 		UpdatedAt:   time.Now(),
 	}
 
-	err := UserRepo.SaveRole(Expected)
+	err := repo.SaveRole(context.Background(), Expected)
 	assert.Nil(t, err)
 
-	rle, err := UserRepo.LoadRole(Expected.RoleName)
+	rle, err := repo.LoadRole(context.Background(), Expected.RoleName)
 
 	assert.Nil(t, err)
 	Compare_Roles(t, Expected, rle)
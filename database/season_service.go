@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// SeasonService opens and closes seasons. Closing a season needs ADMIN.
+type SeasonService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewSeasonService(stores Stores, tx TxRunner) *SeasonService {
+	return &SeasonService{stores: stores, tx: tx}
+}
+
+// CloseSeason ends the open season and starts one called nextName, all in
+// one transaction:
+//
+//   - the final totals of the closed season are recomputed from its ledger
+//     and stored as SeasonTotals,
+//   - the closed season no longer accepts ledger entries or team bonuses,
+//   - every balance is reset, and carryOverPercent of each positive balance
+//     (rounded down) is booked as a CarryOver entry in the new season.
+func (s *SeasonService) CloseSeason(ctx context.Context, actor User, nextName string, carryOverPercent int) (Season, error) {
+	err := requirePermission(actor, ADMIN)
+	if err != nil {
+		return Season{}, err
+	}
+	nextName = strings.TrimSpace(nextName)
+	if nextName == "" {
+		return Season{}, ValidationErrorNew("name", "season name is required", EMPTY_VALUE_CODE)
+	}
+	if carryOverPercent < 0 || carryOverPercent > 100 {
+		return Season{}, ValidationErrorNew("carryOverPercent", "carry over must be between 0 and 100 percent", INVALID_VALUE_CODE)
+	}
+	var next Season
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		current, err := tx.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return err
+		}
+		// The close is claimed before the totals are taken, so a concurrent
+		// close fails and ledger writes still holding the season finish
+		// first.
+		now := time.Now()
+		err = tx.Seasons.CloseSeason(ctx, current.ID, now)
+		if err != nil {
+			return err
+		}
+		ledger, err := tx.Points.LedgerTotals(ctx, current.ID)
+		if err != nil {
+			return err
+		}
+		totals := make([]SeasonTotal, 0, len(ledger))
+		for _, balance := range ledger {
+			total := SeasonTotal{
+				SeasonID:      current.ID,
+				ParticipantID: balance.ParticipantID,
+				Earned:        balance.Earned,
+				Spent:         balance.Spent,
+				Balance:       balance.Balance,
+			}
+			if balance.Balance > 0 {
+				total.CarriedOver = balance.Balance * int64(carryOverPercent) / 100
+			}
+			totals = append(totals, total)
+		}
+		err = tx.Seasons.SaveSeasonTotals(ctx, totals)
+		if err != nil {
+			return err
+		}
+		next, err = tx.Seasons.SaveSeason(ctx, Season{Name: nextName, StartedAt: now})
+		if err != nil {
+			return err
+		}
+		err = tx.Points.DeleteBalances(ctx)
+		if err != nil {
+			return err
+		}
+		for _, total := range totals {
+			if total.CarriedOver <= 0 {
+				continue
+			}
+			_, err = recordPoints(ctx, tx, PointEntry{
+				SeasonID:      next.ID,
+				ParticipantID: total.ParticipantID,
+				Points:        total.CarriedOver,
+				Reason:        "Carried over from " + current.Name,
+				CarryOver:     true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return next, err
+}
+
+func (s *SeasonService) CurrentSeason(ctx context.Context) (Season, error) {
+	return s.stores.Seasons.CurrentSeason(ctx)
+}
+
+func (s *SeasonService) Seasons(ctx context.Context) ([]Season, error) {
+	return s.stores.Seasons.Seasons(ctx)
+}
+
+// SeasonTotals returns the archived totals of a closed season.
+func (s *SeasonService) SeasonTotals(ctx context.Context, seasonID string) ([]SeasonTotal, error) {
+	return s.stores.Seasons.SeasonTotals(ctx, seasonID)
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeasonService_CloseShouldArchiveAndCarryOver(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		teams := NewTeamService(stores, tx)
+		seasons := NewSeasonService(stores, tx)
+		first, err := seasons.CurrentSeason(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, initialSeasonName, first.Name)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+		bob, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Bob"})
+		assert.Nil(t, err)
+		team, err := teams.CreateTeam(ctx, SystemUser, "Red", "")
+		assert.Nil(t, err)
+		_, err = teams.MoveParticipant(ctx, SystemUser, ada.ID, team.ID)
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 25, "attendance")
+		assert.Nil(t, err)
+		_, err = points.SpendPoints(ctx, ada.ID, 4, "store")
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, bob.ID, 3, "attendance")
+		assert.Nil(t, err)
+
+		next, err := seasons.CloseSeason(ctx, SystemUser, "2027", 50)
+		assert.Nil(t, err)
+		assert.Equal(t, "2027", next.Name)
+		current, err := seasons.CurrentSeason(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, next.ID, current.ID)
+
+		totals, err := seasons.SeasonTotals(ctx, first.ID)
+		assert.Nil(t, err)
+		byParticipant := map[string]SeasonTotal{}
+		for _, total := range totals {
+			byParticipant[total.ParticipantID] = total
+		}
+		assert.Equal(t, SeasonTotal{SeasonID: first.ID, ParticipantID: ada.ID, Earned: 25, Spent: 4, Balance: 21, CarriedOver: 10}, byParticipant[ada.ID])
+		assert.Equal(t, int64(1), byParticipant[bob.ID].CarriedOver)
+
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), balance.Balance)
+		drifts, err := points.Reconcile(ctx, false)
+		assert.Nil(t, err)
+		assert.Empty(t, drifts)
+
+		// Carried over points are not earned by the team again.
+		total, err := teams.TeamTotal(ctx, team.ID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), total.Total)
+		total, err = teams.TeamTotal(ctx, team.ID, first.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(25), total.Total)
+
+		_, err = stores.Points.RecordPoints(ctx, PointEntry{SeasonID: first.ID, ParticipantID: ada.ID, Points: 5})
+		assert.ErrorIs(t, err, ErrSeasonClosed)
+		_, err = stores.Teams.RecordTeamBonus(ctx, TeamBonus{SeasonID: first.ID, TeamID: team.ID, Points: 5})
+		assert.ErrorIs(t, err, ErrSeasonClosed)
+		// A second close of the same season loses the claim.
+		assert.ErrorIs(t, stores.Seasons.CloseSeason(ctx, first.ID, time.Now()), ErrSeasonClosed)
+	})
+}
+
+func TestSeasonService_CloseShouldValidate(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		seasons := NewSeasonService(stores, tx)
+		_, err := seasons.CloseSeason(ctx, teamLeader, "2027", 0)
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+		_, err = seasons.CloseSeason(ctx, SystemUser, "2027", 101)
+		assert.Equal(t, int(INVALID_VALUE_CODE), err.(*ValidationError).ErrorCode())
+		_, err = seasons.CloseSeason(ctx, SystemUser, initialSeasonName, 0)
+		assert.Equal(t, int(DUPLICATE_VALUE_CODE), err.(*ValidationError).ErrorCode())
+
+		current, err := seasons.CurrentSeason(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, initialSeasonName, current.Name)
+	})
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSeasonClosed is returned when points are recorded against a season
+// that has been closed; closed seasons are kept for history only.
+var ErrSeasonClosed = errors.New("season is closed")
+
+// Season scopes the points ledger, usually to one school year. Exactly one
+// season is open at a time, the one with no ClosedAt.
+type Season struct {
+	ID        string    `gorm:"primaryKey"`
+	Name      string    `gorm:"not null;size:255;uniqueIndex"`
+	StartedAt time.Time `gorm:"not null"`
+	ClosedAt  *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (season *Season) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	season.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", season.ID)
+	return
+}
+
+// SeasonTotal is a participant's final balance in a closed season and how
+// much of it was carried into the next one.
+type SeasonTotal struct {
+	SeasonID      string `gorm:"primaryKey"`
+	ParticipantID string `gorm:"primaryKey"`
+	Earned        int64  `gorm:"not null"`
+	Spent         int64  `gorm:"not null"`
+	Balance       int64  `gorm:"not null"`
+	CarriedOver   int64  `gorm:"not null"`
+}
+
+func (r *UserRepository) CurrentSeason(ctx context.Context) (Season, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var season Season
+	err := db.Where("closed_at IS NULL").First(&season).Error
+	return season, err
+}
+
+func (r *UserRepository) LoadSeason(ctx context.Context, id string) (Season, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var season Season
+	err := db.Where("id = ?", id).First(&season).Error
+	return season, err
+}
+
+// Seasons returns every season, oldest first.
+func (r *UserRepository) Seasons(ctx context.Context) ([]Season, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var seasons []Season
+	err := db.Order("started_at").Find(&seasons).Error
+	return seasons, err
+}
+
+func (r *UserRepository) SaveSeason(ctx context.Context, season Season) (Season, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if season.ID == "" {
+		err = db.Create(&season).Error
+	} else {
+		err = db.Save(&season).Error
+	}
+	if err != nil && isDuplicateKeyError(err) {
+		return season, ValidationErrorNew("name", "season name already exists", DUPLICATE_VALUE_CODE)
+	}
+	return season, err
+}
+
+// CloseSeason marks the season closed at the given time. Only one caller
+// can close a season: the update is conditional on it still being open and
+// ErrSeasonClosed is returned otherwise.
+func (r *UserRepository) CloseSeason(ctx context.Context, id string, at time.Time) error {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	record := db.Model(&Season{}).
+		Where("id = ? AND closed_at IS NULL", id).
+		Updates(map[string]interface{}{"closed_at": at, "updated_at": at})
+	if record.Error != nil {
+		return record.Error
+	}
+	if record.RowsAffected == 0 {
+		return ErrSeasonClosed
+	}
+	return nil
+}
+
+func (r *UserRepository) SaveSeasonTotals(ctx context.Context, totals []SeasonTotal) error {
+	if len(totals) == 0 {
+		return nil
+	}
+	db, cancel := r.session(ctx)
+	defer cancel()
+	return db.Create(&totals).Error
+}
+
+func (r *UserRepository) SeasonTotals(ctx context.Context, seasonID string) ([]SeasonTotal, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var totals []SeasonTotal
+	err := db.Where("season_id = ?", seasonID).Order("participant_id").Find(&totals).Error
+	return totals, err
+}
+
+// requireOpenSeason returns ErrSeasonClosed unless seasonID is the open
+// season. The season row stays share locked until tx ends, so a concurrent
+// CloseSeason waits for the write instead of taking its totals without it,
+// and a write waiting on a close sees the season closed.
+func requireOpenSeason(tx *gorm.DB, seasonID string) error {
+	var ids []string
+	err := shareLock(tx, "seasons").Where("id = ? AND closed_at IS NULL", seasonID).Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return ErrSeasonClosed
+	}
+	return nil
+}
+
+// shareLock reads table with a shared lock held until the transaction
+// ends. SQLite has no row locks, a write transaction locks the database.
+func shareLock(tx *gorm.DB, table string) *gorm.DB {
+	if tx.Dialector.Name() == "sqlserver" {
+		return tx.Table(table + " WITH (HOLDLOCK, ROWLOCK)")
+	}
+	return tx.Table(table).Clauses(clause.Locking{Strength: "SHARE"})
+}
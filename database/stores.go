@@ -0,0 +1,293 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by every store when a record does not exist. It
+// is the GORM error so callers of the GORM stores need no translation.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// Every store method takes the caller's context first so a cancelled
+// request or an expired deadline stops the query.
+
+// UserStore persists user accounts. Usernames are always compared in their
+// NormalizeUsername form.
+type UserStore interface {
+	LoadUser(ctx context.Context, username string) (User, error)
+	// SaveUser creates the user when it has no ID yet and otherwise updates
+	// the user with that ID. A username used by another user returns a
+	// DUPLICATE_VALUE_CODE ValidationError.
+	SaveUser(ctx context.Context, user User) error
+	UsernameExists(ctx context.Context, username string) (bool, error)
+}
+
+// RoleStore persists roles, which are identified by name.
+type RoleStore interface {
+	LoadRole(ctx context.Context, name string) (Role, error)
+	// SaveRole creates the role or updates the one with the same name.
+	SaveRole(ctx context.Context, role Role) error
+}
+
+// PointStore persists participants and their points ledger.
+type PointStore interface {
+	// SaveParticipant creates the participant when it has no ID yet and
+	// returns it as stored.
+	SaveParticipant(ctx context.Context, participant Participant) (Participant, error)
+	LoadParticipant(ctx context.Context, id string) (Participant, error)
+	// SearchParticipants finds a participant by exact ID, as scanned from a
+	// card, or up to limit participants whose first or last names start
+	// with every word of text.
+	SearchParticipants(ctx context.Context, text string, limit int) ([]Participant, error)
+	// Participants returns every participant ordered by last and first name.
+	Participants(ctx context.Context) ([]Participant, error)
+	// Entries returns up to limit of the participant's ledger entries
+	// across all seasons, newest first.
+	Entries(ctx context.Context, participantID string, limit int) ([]PointEntry, error)
+	// RecordPoints appends entry to the ledger and applies it to the
+	// participant's balance atomically. It returns ErrSeasonClosed unless
+	// the entry's season is open.
+	RecordPoints(ctx context.Context, entry PointEntry) (PointEntry, error)
+	// SpendPoints records a spend like RecordPoints, but returns
+	// ErrInsufficientPoints unless the balance covers it. The check and the
+	// change of the balance are one step.
+	SpendPoints(ctx context.Context, entry PointEntry) (PointEntry, error)
+	// LoadBalance returns a zero balance for participants without entries.
+	LoadBalance(ctx context.Context, participantID string) (ParticipantBalance, error)
+	// Balances returns every stored balance, LedgerTotals recomputes them
+	// from the ledger of a season. Both are ordered by participant ID.
+	Balances(ctx context.Context) ([]ParticipantBalance, error)
+	LedgerTotals(ctx context.Context, seasonID string) ([]ParticipantBalance, error)
+	// LockBalances returns every stored balance like Balances and keeps
+	// ledger writes to them waiting until the transaction ends. It returns
+	// ErrSeasonClosed once seasonID is closed.
+	LockBalances(ctx context.Context, seasonID string) ([]ParticipantBalance, error)
+	SaveBalance(ctx context.Context, balance ParticipantBalance) error
+	DeleteBalances(ctx context.Context) error
+}
+
+// CategoryStore persists the categories ledger entries are grouped by.
+type CategoryStore interface {
+	// SaveCategory creates the category when it has no ID yet and returns
+	// it as stored. Category names are unique.
+	SaveCategory(ctx context.Context, category Category) (Category, error)
+	LoadCategory(ctx context.Context, id string) (Category, error)
+	// Categories returns every category ordered by name.
+	Categories(ctx context.Context) ([]Category, error)
+}
+
+// LeaderboardStore computes the scores leaderboards are ranked by.
+type LeaderboardStore interface {
+	// ParticipantScores returns the limit best scores, highest first, plus
+	// everyone tied with the last one. limit must be positive.
+	ParticipantScores(ctx context.Context, filter LeaderboardFilter, limit int) ([]ParticipantScore, error)
+	// TeamScores returns every team's score, highest first.
+	TeamScores(ctx context.Context, filter LeaderboardFilter) ([]TeamScore, error)
+}
+
+// CatalogStore persists the reward catalog and what was redeemed from it.
+type CatalogStore interface {
+	// SaveCatalogItem creates the item when it has no ID yet and returns it
+	// as stored. Item names are unique. Updates return ErrConflict unless
+	// item.Version is the stored version.
+	SaveCatalogItem(ctx context.Context, item CatalogItem) (CatalogItem, error)
+	LoadCatalogItem(ctx context.Context, id string) (CatalogItem, error)
+	// CatalogItems returns every item ordered by name.
+	CatalogItems(ctx context.Context) ([]CatalogItem, error)
+	// TakeStock atomically lowers the item's stock by quantity and bumps its
+	// version. It returns ErrConflict if version is no longer current, or an
+	// OUT_OF_STOCK_CODE ValidationError if not enough is left.
+	TakeStock(ctx context.Context, itemID string, quantity int, version int) error
+	RecordRedemption(ctx context.Context, redemption Redemption) (Redemption, error)
+	// RedeemedQuantity sums the participant's redemptions of an item in a
+	// season.
+	RedeemedQuantity(ctx context.Context, seasonID string, itemID string, participantID string) (int, error)
+}
+
+// EventStore persists the events points are awarded for.
+type EventStore interface {
+	// SaveEvent creates the event when it has no ID yet and returns it as
+	// stored.
+	SaveEvent(ctx context.Context, event Event) (Event, error)
+	LoadEvent(ctx context.Context, id string) (Event, error)
+	// Events returns the events overlapping [from, to) in start order.
+	Events(ctx context.Context, from time.Time, to time.Time) ([]Event, error)
+}
+
+// AwardStore persists the batches bulk awards write to the ledger. The
+// entries themselves are recorded through the PointStore.
+type AwardStore interface {
+	// SaveAwardBatch creates the batch when it has no ID yet and returns it
+	// as stored.
+	SaveAwardBatch(ctx context.Context, batch AwardBatch) (AwardBatch, error)
+	// MarkBatchReversed sets the reversal of a batch that has none yet and
+	// returns ErrConflict otherwise, so a batch is only reversed once.
+	MarkBatchReversed(ctx context.Context, id string, reversedBy string, at time.Time) error
+	LoadAwardBatch(ctx context.Context, id string) (AwardBatch, error)
+	// AwardBatches returns up to limit batches of a season, newest first.
+	AwardBatches(ctx context.Context, seasonID string, limit int) ([]AwardBatch, error)
+	// BatchEntries returns the entries of a batch, reversals included, in
+	// the order they were recorded.
+	BatchEntries(ctx context.Context, batchID string) ([]PointEntry, error)
+	// AttendedEvents returns the IDs of the events starting in [from, to)
+	// the participant earned points at through a batch that was not
+	// reversed.
+	AttendedEvents(ctx context.Context, participantID string, from time.Time, to time.Time) ([]string, error)
+}
+
+// BadgeStore persists awarded badges and reads the ledger progress badge
+// rules are checked against. Reads must see the writes of the transaction
+// the store is bound to.
+type BadgeStore interface {
+	// SaveBadge records a new badge and reports whether it was inserted. A
+	// participant has each badge at most once per season and period; a
+	// second one is skipped without an error and leaves the transaction
+	// usable.
+	SaveBadge(ctx context.Context, badge Badge) (Badge, bool, error)
+	// SetBadgeEntry links a badge to the bonus entry granted with it.
+	SetBadgeEntry(ctx context.Context, badgeID string, pointEntryID string) error
+	HasBadge(ctx context.Context, participantID string, ruleKey string, seasonID string, period string) (bool, error)
+	// Badges returns every badge of a participant, oldest first.
+	Badges(ctx context.Context, participantID string) ([]Badge, error)
+	// Progress sums the participant's entries counted by filter.
+	Progress(ctx context.Context, filter LeaderboardFilter, participantID string) (LedgerProgress, error)
+}
+
+// GuardianStore persists the links between guardian accounts and the
+// participants they follow, and the invitations that create them.
+type GuardianStore interface {
+	// SaveGuardianship records a new link. Linking the same guardian and
+	// participant twice returns a DUPLICATE_VALUE_CODE ValidationError.
+	SaveGuardianship(ctx context.Context, guardianship Guardianship) (Guardianship, error)
+	IsGuardian(ctx context.Context, userID string, participantID string) (bool, error)
+	// GuardedParticipants returns the participants the user follows ordered
+	// by last and first name.
+	GuardedParticipants(ctx context.Context, userID string) ([]Participant, error)
+	// SaveGuardianInvite creates the invite when it has no ID yet and
+	// returns it as stored.
+	SaveGuardianInvite(ctx context.Context, invite GuardianInvite) (GuardianInvite, error)
+	LoadGuardianInvite(ctx context.Context, tokenHash string) (GuardianInvite, error)
+	// ClaimGuardianInvite marks the invite accepted unless it already is,
+	// returning ErrInviteInvalid then, so an invite is only used once.
+	ClaimGuardianInvite(ctx context.Context, id string, acceptedBy string, at time.Time) error
+}
+
+// KioskStore persists the check-in devices and the check-ins made with
+// them.
+type KioskStore interface {
+	// SaveKioskDevice creates the device when it has no ID yet and returns
+	// it as stored.
+	SaveKioskDevice(ctx context.Context, device KioskDevice) (KioskDevice, error)
+	// TouchKioskDevice records the device being seen at at, returning
+	// ErrKioskDenied instead when it was revoked in the meantime.
+	TouchKioskDevice(ctx context.Context, id string, at time.Time) error
+	// RevokeKioskDevice revokes the device unless it already is, returning
+	// ErrConflict then.
+	RevokeKioskDevice(ctx context.Context, id string, revokedBy string, at time.Time) error
+	LoadKioskDevice(ctx context.Context, id string) (KioskDevice, error)
+	KioskDeviceByToken(ctx context.Context, tokenHash string) (KioskDevice, error)
+	// KioskDevices returns every device ordered by name.
+	KioskDevices(ctx context.Context) ([]KioskDevice, error)
+	// SaveCheckIn records a check-in. Checking the same participant in to
+	// an event twice returns a DUPLICATE_VALUE_CODE ValidationError.
+	SaveCheckIn(ctx context.Context, checkIn CheckIn) (CheckIn, error)
+	// CheckIns returns the check-ins of an event, oldest first.
+	CheckIns(ctx context.Context, eventID string) ([]CheckIn, error)
+}
+
+// SeasonStore persists seasons and the final totals of closed ones.
+type SeasonStore interface {
+	// CurrentSeason returns the open season.
+	CurrentSeason(ctx context.Context) (Season, error)
+	LoadSeason(ctx context.Context, id string) (Season, error)
+	// Seasons returns every season, oldest first.
+	Seasons(ctx context.Context) ([]Season, error)
+	// SaveSeason creates the season when it has no ID yet and returns it as
+	// stored. Season names are unique.
+	SaveSeason(ctx context.Context, season Season) (Season, error)
+	// CloseSeason closes the season if it is still open and returns
+	// ErrSeasonClosed otherwise, so only one close of a season succeeds.
+	CloseSeason(ctx context.Context, id string, at time.Time) error
+	SaveSeasonTotals(ctx context.Context, totals []SeasonTotal) error
+	// SeasonTotals returns the totals of a season ordered by participant ID.
+	SeasonTotals(ctx context.Context, seasonID string) ([]SeasonTotal, error)
+}
+
+// TeamStore persists teams, their membership history and team bonuses.
+type TeamStore interface {
+	// SaveTeam creates the team when it has no ID yet and returns it as
+	// stored. Team names are unique.
+	SaveTeam(ctx context.Context, team Team) (Team, error)
+	LoadTeam(ctx context.Context, id string) (Team, error)
+	// Teams returns every team ordered by name.
+	Teams(ctx context.Context) ([]Team, error)
+	// CurrentMembership returns the open membership of a participant.
+	CurrentMembership(ctx context.Context, participantID string) (TeamMembership, error)
+	// SaveMembership creates the membership when it has no ID yet.
+	SaveMembership(ctx context.Context, membership TeamMembership) (TeamMembership, error)
+	// Memberships returns every membership of a team, oldest first.
+	Memberships(ctx context.Context, teamID string) ([]TeamMembership, error)
+	// RecordTeamBonus returns ErrSeasonClosed unless the bonus's season is
+	// open.
+	RecordTeamBonus(ctx context.Context, bonus TeamBonus) (TeamBonus, error)
+	TeamTotal(ctx context.Context, teamID string, seasonID string) (TeamTotal, error)
+}
+
+// Stores groups every store so a unit of work can hand out transactional
+// versions of all of them at once.
+type Stores struct {
+	Users        UserStore
+	Roles        RoleStore
+	Points       PointStore
+	Teams        TeamStore
+	Seasons      SeasonStore
+	Categories   CategoryStore
+	Leaderboards LeaderboardStore
+	Catalog      CatalogStore
+	Events       EventStore
+	Awards       AwardStore
+	Badges       BadgeStore
+	Guardians    GuardianStore
+	Kiosks       KioskStore
+}
+
+// TxRunner runs multi-step operations atomically. fn receives stores bound
+// to one transaction; returning an error (or panicking) rolls back every
+// write made through them, returning nil commits.
+type TxRunner interface {
+	WithinTx(ctx context.Context, fn func(tx Stores) error) error
+}
+
+var _ UserStore = (*UserRepository)(nil)
+var _ RoleStore = (*UserRepository)(nil)
+var _ PointStore = (*UserRepository)(nil)
+var _ TeamStore = (*UserRepository)(nil)
+var _ SeasonStore = (*UserRepository)(nil)
+var _ CategoryStore = (*UserRepository)(nil)
+var _ LeaderboardStore = (*UserRepository)(nil)
+var _ CatalogStore = (*UserRepository)(nil)
+var _ EventStore = (*UserRepository)(nil)
+var _ AwardStore = (*UserRepository)(nil)
+var _ BadgeStore = (*UserRepository)(nil)
+var _ GuardianStore = (*UserRepository)(nil)
+var _ KioskStore = (*UserRepository)(nil)
+var _ UserStore = (*MemoryStore)(nil)
+var _ RoleStore = (*MemoryStore)(nil)
+var _ PointStore = (*MemoryStore)(nil)
+var _ TeamStore = (*MemoryStore)(nil)
+var _ SeasonStore = (*MemoryStore)(nil)
+var _ CategoryStore = (*MemoryStore)(nil)
+var _ LeaderboardStore = (*MemoryStore)(nil)
+var _ CatalogStore = (*MemoryStore)(nil)
+var _ EventStore = (*MemoryStore)(nil)
+var _ AwardStore = (*MemoryStore)(nil)
+var _ BadgeStore = (*MemoryStore)(nil)
+var _ GuardianStore = (*MemoryStore)(nil)
+var _ KioskStore = (*MemoryStore)(nil)
+var _ TxRunner = (*UserRepository)(nil)
+var _ TxRunner = (*MemoryStore)(nil)
+var _ Pinger = (*UserRepository)(nil)
+var _ Pinger = (*MemoryStore)(nil)
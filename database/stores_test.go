@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachStore runs test against a freshly migrated GORM database and a
+// fresh in-memory store. Tests build the services they need on top of
+// stores and tx.
+func forEachStore(t *testing.T, test func(t *testing.T, stores Stores, tx TxRunner)) {
+	t.Run("gorm", func(t *testing.T) {
+		repo := NewUserRepository(DbTemp(t), 0)
+		_, err := repo.MigrateUp(context.Background())
+		assert.Nil(t, err)
+		test(t, repo.Stores(), repo)
+	})
+	t.Run("memory", func(t *testing.T) {
+		memory := NewMemoryStore()
+		test(t, memory.Stores(), memory)
+	})
+}
+
+func assertWithinTxRollsBack(t *testing.T, runner TxRunner, stores Stores) {
+	ctx := context.Background()
+	failure := errors.New("award failed")
+
+	err := runner.WithinTx(ctx, func(tx Stores) error {
+		err := tx.Roles.SaveRole(ctx, Role{RoleName: "ROLLED_BACK", Permissions: USER_READ})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	_, err = stores.Roles.LoadRole(ctx, "ROLLED_BACK")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = runner.WithinTx(ctx, func(tx Stores) error {
+		return tx.Roles.SaveRole(ctx, Role{RoleName: "COMMITTED", Permissions: USER_READ})
+	})
+	assert.Nil(t, err)
+	role, err := stores.Roles.LoadRole(ctx, "COMMITTED")
+	assert.Nil(t, err)
+	assert.Equal(t, USER_READ, role.Permissions)
+}
+
+func TestWithinTx_GormShouldRollBackOnError(t *testing.T) {
+	repo := NewUserRepository(DbTemp(t), 0)
+	_, err := repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+
+	assertWithinTxRollsBack(t, repo, repo.Stores())
+}
+
+func TestWithinTx_MemoryShouldRollBackOnError(t *testing.T) {
+	store := NewMemoryStore()
+
+	assertWithinTxRollsBack(t, store, store.Stores())
+}
+
+func TestWithinTx_ShouldNestThroughTxStores(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, runner TxRunner) {
+		failure := errors.New("inner failed")
+		err := runner.WithinTx(ctx, func(tx Stores) error {
+			err := tx.Roles.SaveRole(ctx, Role{RoleName: "OUTER", Permissions: USER_READ})
+			if err != nil {
+				return err
+			}
+			err = tx.Roles.(TxRunner).WithinTx(ctx, func(tx Stores) error {
+				err := tx.Roles.SaveRole(ctx, Role{RoleName: "INNER", Permissions: USER_READ})
+				if err != nil {
+					return err
+				}
+				return failure
+			})
+			assert.ErrorIs(t, err, failure)
+			return tx.Roles.(TxRunner).WithinTx(ctx, func(tx Stores) error {
+				return tx.Roles.SaveRole(ctx, Role{RoleName: "NESTED", Permissions: USER_READ})
+			})
+		})
+		assert.Nil(t, err)
+		_, err = stores.Roles.LoadRole(ctx, "OUTER")
+		assert.Nil(t, err)
+		_, err = stores.Roles.LoadRole(ctx, "NESTED")
+		assert.Nil(t, err)
+		_, err = stores.Roles.LoadRole(ctx, "INNER")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// failingUsers makes every user write fail so seeding breaks after the
+// roles were already saved in the same transaction.
+type failingUsers struct {
+	UserStore
+}
+
+func (failingUsers) SaveUser(ctx context.Context, user User) error {
+	return errors.New("disk full")
+}
+
+type failingUsersTx struct {
+	*MemoryStore
+}
+
+func (f failingUsersTx) WithinTx(ctx context.Context, fn func(tx Stores) error) error {
+	return f.MemoryStore.WithinTx(ctx, func(tx Stores) error {
+		tx.Users = failingUsers{tx.Users}
+		return fn(tx)
+	})
+}
+
+func TestInitiateModels_ShouldLeaveNothingOnFailure(t *testing.T) {
+	store := NewMemoryStore()
+	service := NewUserService(store.Stores(), failingUsersTx{store})
+
+	err := service.InitiateModels(context.Background())
+	assert.NotNil(t, err)
+	_, err = store.LoadRole(context.Background(), "NO_PERMISSIONS")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TeamService manages teams and their memberships. Changes to teams and
+// memberships need PARTICIPENT_WRITE, team bonuses ADD_POINTS_WRITE.
+type TeamService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewTeamService(stores Stores, tx TxRunner) *TeamService {
+	return &TeamService{stores: stores, tx: tx}
+}
+
+func (s *TeamService) CreateTeam(ctx context.Context, actor User, name string, color string) (Team, error) {
+	err := requirePermission(actor, PARTICIPENT_WRITE)
+	if err != nil {
+		return Team{}, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Team{}, ValidationErrorNew("name", "team name is required", EMPTY_VALUE_CODE)
+	}
+	return s.stores.Teams.SaveTeam(ctx, Team{Name: name, Color: color})
+}
+
+// MoveParticipant makes teamID the participant's current team, closing the
+// membership of their previous team. Moving to the current team is a no-op.
+func (s *TeamService) MoveParticipant(ctx context.Context, actor User, participantID string, teamID string) (TeamMembership, error) {
+	err := requirePermission(actor, PARTICIPENT_WRITE)
+	if err != nil {
+		return TeamMembership{}, err
+	}
+	var membership TeamMembership
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		_, err := tx.Points.LoadParticipant(ctx, participantID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Teams.LoadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		current, err := tx.Teams.CurrentMembership(ctx, participantID)
+		if err == nil {
+			if current.TeamID == teamID {
+				membership = current
+				return nil
+			}
+			current.LeftAt = &now
+			_, err = tx.Teams.SaveMembership(ctx, current)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		membership, err = tx.Teams.SaveMembership(ctx, TeamMembership{TeamID: teamID, ParticipantID: participantID, JoinedAt: now})
+		return err
+	})
+	return membership, err
+}
+
+// RemoveFromTeam closes the participant's current membership, if any.
+func (s *TeamService) RemoveFromTeam(ctx context.Context, actor User, participantID string) error {
+	err := requirePermission(actor, PARTICIPENT_WRITE)
+	if err != nil {
+		return err
+	}
+	current, err := s.stores.Teams.CurrentMembership(ctx, participantID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	current.LeftAt = &now
+	_, err = s.stores.Teams.SaveMembership(ctx, current)
+	return err
+}
+
+// AwardTeamBonus gives points to the team in the open season only,
+// members' balances are not changed.
+func (s *TeamService) AwardTeamBonus(ctx context.Context, actor User, teamID string, points int64, reason string) (TeamBonus, error) {
+	err := requirePermission(actor, ADD_POINTS_WRITE)
+	if err != nil {
+		return TeamBonus{}, err
+	}
+	err = validatePoints(points)
+	if err != nil {
+		return TeamBonus{}, err
+	}
+	var bonus TeamBonus
+	err = s.tx.WithinTx(ctx, func(tx Stores) error {
+		_, err := tx.Teams.LoadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		season, err := tx.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return err
+		}
+		bonus, err = tx.Teams.RecordTeamBonus(ctx, TeamBonus{SeasonID: season.ID, TeamID: teamID, Points: points, Reason: reason})
+		return err
+	})
+	return bonus, err
+}
+
+// TeamTotal returns the team's score in a season, an empty seasonID means
+// the open season.
+func (s *TeamService) TeamTotal(ctx context.Context, teamID string, seasonID string) (TeamTotal, error) {
+	if seasonID == "" {
+		season, err := s.stores.Seasons.CurrentSeason(ctx)
+		if err != nil {
+			return TeamTotal{}, err
+		}
+		seasonID = season.ID
+	}
+	return s.stores.Teams.TeamTotal(ctx, teamID, seasonID)
+}
+
+func (s *TeamService) Teams(ctx context.Context) ([]Team, error) {
+	return s.stores.Teams.Teams(ctx)
+}
+
+// Memberships returns the team's current and past memberships.
+func (s *TeamService) Memberships(ctx context.Context, teamID string) ([]TeamMembership, error) {
+	return s.stores.Teams.Memberships(ctx, teamID)
+}
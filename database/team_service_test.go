@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var teamLeader = User{Username: "leader", Role: Role{Permissions: PARTICIPENT_WRITE | ADD_POINTS_WRITE}}
+
+func TestTeamService_ShouldCountPointsDuringMembership(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		points := NewPointService(stores, tx)
+		teams := NewTeamService(stores, tx)
+		red, err := teams.CreateTeam(ctx, teamLeader, "Red", "#ff0000")
+		assert.Nil(t, err)
+		blue, err := teams.CreateTeam(ctx, teamLeader, "Blue", "#0000ff")
+		assert.Nil(t, err)
+		ada, err := stores.Points.SaveParticipant(ctx, Participant{FirstName: "Ada"})
+		assert.Nil(t, err)
+
+		_, err = points.AwardPoints(ctx, ada.ID, 3, "before joining")
+		assert.Nil(t, err)
+		_, err = teams.MoveParticipant(ctx, teamLeader, ada.ID, red.ID)
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 10, "attendance")
+		assert.Nil(t, err)
+		_, err = points.SpendPoints(ctx, ada.ID, 4, "store")
+		assert.Nil(t, err)
+		_, err = teams.MoveParticipant(ctx, teamLeader, ada.ID, blue.ID)
+		assert.Nil(t, err)
+		_, err = points.AwardPoints(ctx, ada.ID, 5, "memory verse")
+		assert.Nil(t, err)
+		_, err = teams.AwardTeamBonus(ctx, teamLeader, red.ID, 20, "won the game")
+		assert.Nil(t, err)
+
+		total, err := teams.TeamTotal(ctx, red.ID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), total.MemberPoints)
+		assert.Equal(t, int64(20), total.BonusPoints)
+		assert.Equal(t, int64(30), total.Total)
+		total, err = teams.TeamTotal(ctx, blue.ID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), total.Total)
+
+		balance, err := points.Balance(ctx, ada.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(14), balance.Balance)
+
+		memberships, err := teams.Memberships(ctx, red.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(memberships))
+		assert.NotNil(t, memberships[0].LeftAt)
+	})
+}
+
+func TestTeamService_ShouldRequirePermissions(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, stores Stores, tx TxRunner) {
+		teams := NewTeamService(stores, tx)
+		pointsOnly := User{Role: Role{Permissions: ADD_POINTS_WRITE}}
+		_, err := teams.CreateTeam(ctx, pointsOnly, "Green", "")
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+
+		team, err := teams.CreateTeam(ctx, User{Role: Role{Permissions: ADMIN}}, "Green", "")
+		assert.Nil(t, err)
+		_, err = teams.CreateTeam(ctx, teamLeader, "Green", "")
+		assert.Equal(t, int(DUPLICATE_VALUE_CODE), err.(*ValidationError).ErrorCode())
+
+		participantsOnly := User{Role: Role{Permissions: PARTICIPENT_WRITE}}
+		_, err = teams.AwardTeamBonus(ctx, participantsOnly, team.ID, 5, "bonus")
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+		disabled := teamLeader
+		disabled.DisableAccount = true
+		_, err = teams.AwardTeamBonus(ctx, disabled, team.ID, 5, "bonus")
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+	})
+}
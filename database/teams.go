@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Team is a group of participants competing for a common total, such as a
+// color team.
+type Team struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string `gorm:"not null;size:255;uniqueIndex"`
+	Color     string `gorm:"size:32"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (team *Team) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	team.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", team.ID)
+	return
+}
+
+// TeamMembership is the time a participant spent on a team. LeftAt is nil
+// for the current team; moving teams closes the old membership and opens a
+// new one, so the history is kept.
+type TeamMembership struct {
+	ID            string    `gorm:"primaryKey"`
+	TeamID        string    `gorm:"not null;index"`
+	ParticipantID string    `gorm:"not null;index"`
+	JoinedAt      time.Time `gorm:"not null"`
+	LeftAt        *time.Time
+}
+
+func (membership *TeamMembership) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	membership.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", membership.ID)
+	return
+}
+
+// covers reports whether at falls inside the membership window.
+func (membership TeamMembership) covers(at time.Time) bool {
+	return !at.Before(membership.JoinedAt) && (membership.LeftAt == nil || at.Before(*membership.LeftAt))
+}
+
+// TeamBonus is points given to a team as a whole, they count towards the
+// team total only and never reach a participant's balance.
+type TeamBonus struct {
+	ID        string `gorm:"primaryKey"`
+	SeasonID  string `gorm:"index"`
+	TeamID    string `gorm:"not null;index"`
+	Points    int64  `gorm:"not null"`
+	Reason    string `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+func (TeamBonus) TableName() string {
+	return "team_bonuses"
+}
+
+func (bonus *TeamBonus) BeforeCreate(tx *gorm.DB) (err error) {
+	// UUID version 4
+	bonus.ID = uuid.NewString()
+	tx.Statement.SetColumn("ID", bonus.ID)
+	return
+}
+
+// TeamTotal is a team's score in a season: the points its members earned
+// while on the team plus its bonuses. Spent and carried over points and
+// reversed award batches do not count.
+type TeamTotal struct {
+	TeamID       string
+	SeasonID     string
+	MemberPoints int64
+	BonusPoints  int64
+	Total        int64
+}
+
+func (r *UserRepository) SaveTeam(ctx context.Context, team Team) (Team, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if team.ID == "" {
+		err = db.Create(&team).Error
+	} else {
+		err = db.Save(&team).Error
+	}
+	if err != nil && isDuplicateKeyError(err) {
+		return team, ValidationErrorNew("name", "team name already exists", DUPLICATE_VALUE_CODE)
+	}
+	return team, err
+}
+
+func (r *UserRepository) LoadTeam(ctx context.Context, id string) (Team, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var team Team
+	err := db.Where("id = ?", id).First(&team).Error
+	return team, err
+}
+
+func (r *UserRepository) Teams(ctx context.Context) ([]Team, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var teams []Team
+	err := db.Order("name").Find(&teams).Error
+	return teams, err
+}
+
+func (r *UserRepository) CurrentMembership(ctx context.Context, participantID string) (TeamMembership, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var membership TeamMembership
+	err := db.Where("participant_id = ? AND left_at IS NULL", participantID).First(&membership).Error
+	return membership, err
+}
+
+func (r *UserRepository) SaveMembership(ctx context.Context, membership TeamMembership) (TeamMembership, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var err error
+	if membership.ID == "" {
+		err = db.Create(&membership).Error
+	} else {
+		err = db.Save(&membership).Error
+	}
+	return membership, err
+}
+
+func (r *UserRepository) Memberships(ctx context.Context, teamID string) ([]TeamMembership, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	var memberships []TeamMembership
+	err := db.Where("team_id = ?", teamID).Order("joined_at").Find(&memberships).Error
+	return memberships, err
+}
+
+// RecordTeamBonus stores bonus, whose season must be open.
+func (r *UserRepository) RecordTeamBonus(ctx context.Context, bonus TeamBonus) (TeamBonus, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := requireOpenSeason(tx, bonus.SeasonID)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&bonus).Error
+	})
+	return bonus, err
+}
+
+// TeamTotal sums the earned ledger entries of a season made inside each
+// membership window of the team, and its bonuses.
+func (r *UserRepository) TeamTotal(ctx context.Context, teamID string, seasonID string) (TeamTotal, error) {
+	db, cancel := r.session(ctx)
+	defer cancel()
+	total := TeamTotal{TeamID: teamID, SeasonID: seasonID}
+	err := earnedEntries(db.Model(&PointEntry{}), seasonID).
+		Select("COALESCE(SUM(point_entries.points), 0)").
+		Joins(`JOIN team_memberships ON team_memberships.participant_id = point_entries.participant_id
+			AND point_entries.created_at >= team_memberships.joined_at
+			AND (team_memberships.left_at IS NULL OR point_entries.created_at < team_memberships.left_at)`).
+		Where("team_memberships.team_id = ?", teamID).
+		Scan(&total.MemberPoints).Error
+	if err != nil {
+		return total, err
+	}
+	err = db.Model(&TeamBonus{}).
+		Select("COALESCE(SUM(points), 0)").
+		Where("team_id = ? AND season_id = ?", teamID, seasonID).
+		Scan(&total.BonusPoints).Error
+	total.Total = total.MemberPoints + total.BonusPoints
+	return total, err
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"log"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -17,13 +18,28 @@ import (
 )
 
 func DbMock(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("test.sqlite"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open("test.sqlite"), newGormConfig())
 	if err != nil {
 		t.Fatal(err)
 	}
 	return db
 }
 
+// DbTemp opens an empty sqlite database that is removed after the test.
+func DbTemp(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite")), newGormConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
 type ColumnSchema struct {
 	DBColumnName  string
 	DBColumnIndex int
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"blue-beetle/config"
+)
+
+// UserService holds the account rules (logon, password changes, seeding)
+// on top of the stores, so it works the same against GORM or the
+// in-memory store.
+type UserService struct {
+	stores Stores
+	tx     TxRunner
+}
+
+func NewUserService(stores Stores, tx TxRunner) *UserService {
+	return &UserService{stores: stores, tx: tx}
+}
+
+// InitiateModels seeds the built-in roles and the admin account in one
+// transaction, so a failure part way leaves nothing half created.
+func (s *UserService) InitiateModels(ctx context.Context) error {
+	return s.tx.WithinTx(ctx, func(tx Stores) error {
+		txService := &UserService{stores: tx, tx: s.tx}
+		err := txService.InitRoleModle(ctx)
+		if err != nil {
+			return err
+		}
+		return txService.InitUserModel(ctx)
+	})
+}
+
+func (s *UserService) InitRoleModle(ctx context.Context) error {
+	_, err := s.stores.Roles.LoadRole(ctx, "NO_PERMISSIONS")
+	if err != nil && errors.Is(err, ErrNotFound) {
+		var no_perm Role
+		no_perm.RoleName = "NO_PERMISSIONS"
+		no_perm.Permissions = NO_PERMISSIONS
+		err = s.stores.Roles.SaveRole(ctx, no_perm)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.stores.Roles.LoadRole(ctx, "ADMIN")
+	if err != nil && errors.Is(err, ErrNotFound) {
+		var admin Role
+		admin.RoleName = "ADMIN"
+		admin.Permissions = ADMIN
+		err = s.stores.Roles.SaveRole(ctx, admin)
+	}
+	if err != nil {
+		return err
+	}
+	// Guardians may only follow the participants linked to their account.
+	_, err = s.stores.Roles.LoadRole(ctx, GuardianRoleName)
+	if err != nil && errors.Is(err, ErrNotFound) {
+		var guardian Role
+		guardian.RoleName = GuardianRoleName
+		guardian.Permissions = GUARDIAN_READ
+		err = s.stores.Roles.SaveRole(ctx, guardian)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *UserService) InitUserModel(ctx context.Context) error {
+	err := s.InitRoleModle(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.stores.Users.LoadUser(ctx, "admin")
+	if err != nil && errors.Is(err, ErrNotFound) {
+		admin, err := s.CreateNewUser(ctx, "admin", "admin@no.email", "Password_1")
+		if err != nil {
+			return err
+		}
+		adminRole, err := s.stores.Roles.LoadRole(ctx, "ADMIN")
+		if err != nil {
+			return err
+		}
+		admin.Role = adminRole
+		admin.ForcePasswordReset = true
+		return s.stores.Users.SaveUser(ctx, admin)
+	}
+	return err
+}
+
+func (s *UserService) LogonUser(ctx context.Context, username string, password string) (User, error) {
+	user, err := s.stores.Users.LoadUser(ctx, username)
+	if err != nil && errors.Is(err, ErrNotFound) {
+		return user, LogonErrorNew("Username or password is not valid", BAD_USER_CODE)
+	} else if err != nil {
+		return User{}, err
+	}
+	if !user.VerifyPassword(password) {
+		user.LoginAttempts = user.LoginAttempts + 1
+		err = s.stores.Users.SaveUser(ctx, user)
+		if err != nil {
+			return User{}, LogonErrorNew("Fail to save User account: "+err.Error(), FAILED_TO_SAVE_USER_CODE)
+		}
+		err = LogonErrorNew("Username or password is not valid", BAD_USER_CODE)
+		return User{}, err
+	}
+	if user.DisableAccount {
+		return user, LogonErrorNew("Account Locked", LOCKED_ACCOUNT_CODE)
+	}
+	if user.ForcePasswordReset {
+		return user, LogonErrorNew("New Password need", FORCED_PASS_RESET_CODE)
+	}
+	if user.LoginAttempts > config.Live().RateLimit.MaxLoginAttempts {
+		user.LoginAttempts = user.LoginAttempts + 1
+		user.LastLogin = time.Now()
+		err = s.stores.Users.SaveUser(ctx, user)
+		if err != nil {
+			return User{}, LogonErrorNew("Fail to save User account: "+err.Error(), FAILED_TO_SAVE_USER_CODE)
+		}
+		return user, LogonErrorNew("To many Failed Logins", LOGON_COUNT_FAILED_CODE)
+	}
+	user.LoginAttempts = 0
+	user.LastLogin = time.Now()
+	err = s.stores.Users.SaveUser(ctx, user)
+	if err != nil {
+		return User{}, LogonErrorNew("Fail to save User account: "+err.Error(), FAILED_TO_SAVE_USER_CODE)
+	}
+	return user, nil
+}
+
+// User loads the account of a logged in user with its role.
+func (s *UserService) User(ctx context.Context, username string) (User, error) {
+	return s.stores.Users.LoadUser(ctx, username)
+}
+
+func (s *UserService) CreateNewUser(ctx context.Context, username string, email string, password string) (User, error) {
+	username = NormalizeUsername(username)
+	validate := s.validateUsername(ctx, username)
+	var u User
+	if validate != nil {
+		return u, validate
+	}
+	u.Username = username
+	u.Email = email
+	u.DisableAccount = false
+	u.ForcePasswordReset = false
+	u.LastLogin = time.Now()
+	u.LoginAttempts = 0
+	noPerms, err := s.stores.Roles.LoadRole(ctx, "NO_PERMISSIONS")
+	if err != nil {
+		return u, err
+	}
+	u.Role = noPerms
+	err = u.EncryptPassword(password)
+	if err != nil {
+		return u, err
+	}
+	err = s.stores.Users.SaveUser(ctx, u)
+	if err != nil {
+		return u, err
+	}
+	return s.stores.Users.LoadUser(ctx, username)
+}
+
+func (s *UserService) validateUsername(ctx context.Context, username string) error {
+	username = NormalizeUsername(username)
+	if username == "" {
+		return ValidationErrorNew("username", "username can not be empty", EMPTY_VALUE_CODE)
+	}
+	exists, err := s.stores.Users.UsernameExists(ctx, username)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ValidationErrorNew("username", "username already exits", DUPLICATE_VALUE_CODE)
+	}
+	return nil
+}
+
+func (s *UserService) ChangeUserPassword(ctx context.Context, user User, oldPassword string, newPassword string) error {
+	if user.VerifyPassword(oldPassword) {
+		if oldPassword == newPassword {
+			return errors.New("current and new password can't be the same")
+		}
+		err := user.EncryptPassword(newPassword)
+		if err != nil {
+			return err
+		}
+	} else {
+		return errors.New("current password doesn't match for the user")
+	}
+	user.ForcePasswordReset = false
+	err := s.stores.Users.SaveUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of username after logging on with
+// the current one, so wrong guesses count as failed logins. It is how a
+// forced password reset is completed. The new password must follow the
+// password policy.
+func (s *UserService) ChangePassword(ctx context.Context, username string, current string, next string) (User, error) {
+	user, err := s.LogonUser(ctx, username, current)
+	var logonErr *LogonError
+	if errors.As(err, &logonErr) && logonErr.ErrorCode() == int(FORCED_PASS_RESET_CODE) {
+		err = nil
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if current == next {
+		return User{}, ValidationErrorNew("password", "the new password must differ from the current one", INVALID_VALUE_CODE)
+	}
+	err = validatePassword(next)
+	if err != nil {
+		return User{}, ValidationErrorNew("password", err.Error(), INVALID_VALUE_CODE)
+	}
+	err = s.ChangeUserPassword(ctx, user, current, next)
+	if err != nil {
+		return User{}, err
+	}
+	return s.stores.Users.LoadUser(ctx, user.Username)
+}
+
+func (s *UserService) ResetUserPassword(ctx context.Context, user User) (string, error) {
+	newPass := s.GenerateRandmoPassword()
+	count := 0
+	for count < 1000 {
+		err := validatePassword(newPass)
+		if err == nil {
+			break
+		}
+		count += 1
+		newPass = s.GenerateRandmoPassword()
+	}
+	err := validatePassword(newPass)
+	if err != nil {
+		return "", err
+	}
+	err = user.EncryptPassword(newPass)
+	if err != nil {
+		return "", err
+	}
+	user.ForcePasswordReset = false
+	user.LoginAttempts = 0
+	err = s.stores.Users.SaveUser(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	return newPass, nil
+}
+
+func (s *UserService) GenerateRandmoPassword() string {
+	literalList := "QWERTYUIOPASDFGHJKLZXCVBNMqwertyuiopasdfghjklzxcvbnm1234567890_*#^&@:<>.,?+="
+	var p string
+	for i := 1; i < 16; i++ {
+		random := rand.Intn(len(literalList))
+		p += string(literalList[random])
+	}
+	return p
+}
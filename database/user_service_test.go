@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMemoryService(t *testing.T) (*MemoryStore, *UserService) {
+	store := NewMemoryStore()
+	service := NewUserService(store.Stores(), store)
+	err := service.InitiateModels(context.Background())
+	assert.Nil(t, err)
+	return store, service
+}
+
+func TestUserService_ShouldSeedAdminWithAdminRole(t *testing.T) {
+	store, _ := setupMemoryService(t)
+
+	admin, err := store.LoadUser(context.Background(), "Admin")
+	assert.Nil(t, err)
+	assert.Equal(t, ADMIN, admin.Role.Permissions)
+	assert.True(t, admin.ForcePasswordReset)
+}
+
+func TestUserService_LogonShouldCountFailedAttempts(t *testing.T) {
+	store, service := setupMemoryService(t)
+
+	_, err := service.LogonUser(context.Background(), "admin", "Wrong_Password1")
+	assert.NotNil(t, err)
+	assert.Equal(t, int(BAD_USER_CODE), err.(*LogonError).ErrorCode())
+
+	admin, err := store.LoadUser(context.Background(), "admin")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), admin.LoginAttempts)
+}
+
+func TestUserService_LogonShouldRequirePasswordReset(t *testing.T) {
+	_, service := setupMemoryService(t)
+
+	usr, err := service.LogonUser(context.Background(), "admin", "Password_1")
+	assert.Equal(t, int(FORCED_PASS_RESET_CODE), err.(*LogonError).ErrorCode())
+	err = service.ChangeUserPassword(context.Background(), usr, "Password_1", "Password_2")
+	assert.Nil(t, err)
+
+	usr, err = service.LogonUser(context.Background(), "ADMIN", "Password_2")
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", usr.Username)
+}
+
+func TestUserService_ChangePasswordShouldCompleteForcedReset(t *testing.T) {
+	store, service := setupMemoryService(t)
+	ctx := context.Background()
+
+	_, err := service.ChangePassword(ctx, "admin", "Wrong_Password1", "Password_2")
+	assert.Equal(t, int(BAD_USER_CODE), err.(*LogonError).ErrorCode())
+	_, err = service.ChangePassword(ctx, "admin", "Password_1", "Password_1")
+	assert.Equal(t, int(INVALID_VALUE_CODE), err.(*ValidationError).ErrorCode())
+	_, err = service.ChangePassword(ctx, "admin", "Password_1", "short")
+	assert.Equal(t, int(INVALID_VALUE_CODE), err.(*ValidationError).ErrorCode())
+
+	admin, err := service.ChangePassword(ctx, "Admin", "Password_1", "Password_2")
+	assert.Nil(t, err)
+	assert.False(t, admin.ForcePasswordReset)
+	admin, err = store.LoadUser(ctx, "admin")
+	assert.Nil(t, err)
+	assert.True(t, admin.VerifyPassword("Password_2"))
+	_, err = service.LogonUser(ctx, "admin", "Password_2")
+	assert.Nil(t, err)
+}
+
+func TestUserService_CreateNewUserShouldRejectDuplicates(t *testing.T) {
+	_, service := setupMemoryService(t)
+
+	user, err := service.CreateNewUser(context.Background(), "Leader", "leader@no.email", "Password_1")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, user.ID)
+	assert.Equal(t, NO_PERMISSIONS, user.Role.Permissions)
+
+	_, err = service.CreateNewUser(context.Background(), "LEADER", "leader@no.email", "Password_1")
+	assert.Equal(t, int(DUPLICATE_VALUE_CODE), err.(*ValidationError).ErrorCode())
+}
+
+func TestUserService_ResetUserPasswordShouldReturnUsablePassword(t *testing.T) {
+	store, service := setupMemoryService(t)
+	user, err := service.CreateNewUser(context.Background(), "leader", "leader@no.email", "Password_1")
+	assert.Nil(t, err)
+
+	password, err := service.ResetUserPassword(context.Background(), user)
+	assert.Nil(t, err)
+	assert.Nil(t, validatePassword(password))
+
+	user, err = store.LoadUser(context.Background(), "leader")
+	assert.Nil(t, err)
+	assert.True(t, user.VerifyPassword(password))
+}
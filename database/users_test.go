@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"strconv"
 	"testing"
 	"time"
 
@@ -9,12 +11,8 @@ import (
 )
 
 func TestFindUser_ShouldFindAdmin(t *testing.T) {
-	db := DbMock(t)
-
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
-	r, err := UserRepo.LoadRole("ADMIN")
+	repo, service := setupTestRepository(t)
+	r, err := repo.LoadRole(context.Background(), "ADMIN")
 	assert.Nil(t, err)
 	Expected := User{
 		ID:                 uuid.NewString(),
@@ -29,53 +27,45 @@ func TestFindUser_ShouldFindAdmin(t *testing.T) {
 		UpdatedAt:          time.Now(),
 	}
 
-	usr, err := UserRepo.LogonUser("admin", "Password_1")
+	usr, err := service.LogonUser(context.Background(), "admin", "Password_1")
 	if err != nil {
 		lerr := err.(*LogonError)
 		if lerr.ErrorCode() == 3 {
-			err = UserRepo.ChangeUserPassword(usr, "Password_1", "Password_2")
+			err = service.ChangeUserPassword(context.Background(), usr, "Password_1", "Password_2")
 			assert.Nil(t, err)
 		}
-		usr, err = UserRepo.LogonUser("admin", "Password_2")
+		usr, err = service.LogonUser(context.Background(), "admin", "Password_2")
 	}
 	assert.Nil(t, err)
 	Compare_Users(t, Expected, usr)
 }
 
 func TestAddUser_ShouldSucceed(t *testing.T) {
-	db := DbMock(t)
-
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
+	repo, service := setupTestRepository(t)
 	userTime := time.Now().UTC().String()
-	Expected, err := UserRepo.CreateNewUser("newUser"+userTime, "newUser@no.email", "Password_1")
+	Expected, err := service.CreateNewUser(context.Background(), "newUser"+userTime, "newUser@no.email", "Password_1")
 	assert.Nil(t, err)
 
-	err = UserRepo.SaveUser(Expected)
+	err = repo.SaveUser(context.Background(), Expected)
 	assert.Nil(t, err)
 
-	rle, err := UserRepo.LoadUser(Expected.Username)
+	rle, err := repo.LoadUser(context.Background(), Expected.Username)
 
 	assert.Nil(t, err)
 	Compare_Users(t, Expected, rle)
 }
 
 func TestUserLogon_ShouldSucceed(t *testing.T) {
-	db := DbMock(t)
+	_, service := setupTestRepository(t)
 
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
-
-	usr, err := UserRepo.LogonUser("admin", "Password_1")
+	usr, err := service.LogonUser(context.Background(), "admin", "Password_1")
 	if err != nil {
 		lerr := err.(*LogonError)
 		if lerr.ErrorCode() == 3 {
-			err = UserRepo.ChangeUserPassword(usr, "Password_1", "Password_2")
+			err = service.ChangeUserPassword(context.Background(), usr, "Password_1", "Password_2")
 			assert.Nil(t, err)
 		}
-		usr, err = UserRepo.LogonUser("admin", "Password_2")
+		usr, err = service.LogonUser(context.Background(), "admin", "Password_2")
 	}
 	assert.Nil(t, err)
 	expected := usr.VerifyPassword("Password_2")
@@ -83,13 +73,9 @@ func TestUserLogon_ShouldSucceed(t *testing.T) {
 }
 
 func TestUserLogon_ShouldFail(t *testing.T) {
-	db := DbMock(t)
-
-	UserRepo.Database = db
-	UserRepo.AutoMigrate()
-	UserRepo.InitiateModels()
+	_, service := setupTestRepository(t)
 
-	usr, err := UserRepo.LogonUser("admin", "Password_3")
+	usr, err := service.LogonUser(context.Background(), "admin", "Password_3")
 	assert.NotNil(t, err)
 	lerr := err.(*LogonError)
 	assert.Equal(t, lerr.ErrorCode(), 1)
@@ -104,3 +90,67 @@ func Compare_Users(t *testing.T, Expected User, Actual User) {
 	assert.Equal(t, Expected.ForcePasswordReset, Actual.ForcePasswordReset)
 	assert.Equal(t, Expected.DisableAccount, Actual.DisableAccount)
 }
+
+func TestNormalizeUsername(t *testing.T) {
+	assert.Equal(t, "admin", NormalizeUsername("Admin"))
+	assert.Equal(t, "admin", NormalizeUsername("  ADMIN "))
+	assert.Equal(t, "admin", NormalizeUsername("ａｄｍｉｎ"))
+	assert.Equal(t, "strasse", NormalizeUsername("STRASSE"))
+	assert.Equal(t, "strasse", NormalizeUsername("Straße"))
+}
+
+func TestAddUser_ShouldFailOnCaseInsensitiveDuplicate(t *testing.T) {
+	_, service := setupTestRepository(t)
+	userTime := time.Now().UTC().String()
+	_, err := service.CreateNewUser(context.Background(), "dupUser"+userTime, "dupUser@no.email", "Password_1")
+	assert.Nil(t, err)
+
+	_, err = service.CreateNewUser(context.Background(), "DUPUSER"+userTime, "dupUser@no.email", "Password_1")
+	assert.NotNil(t, err)
+	verr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, int(DUPLICATE_VALUE_CODE), verr.ErrorCode())
+	assert.Equal(t, "username", verr.Field())
+}
+
+func TestSaveUser_ShouldTranslateUniqueIndexViolation(t *testing.T) {
+	repo, _ := setupTestRepository(t)
+	ctx := context.Background()
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 10)
+	assert.Nil(t, repo.SaveUser(ctx, User{Username: "twin" + suffix, Email: "first@no.email"}))
+
+	// Same name once normalized, as a creation that raced past
+	// UsernameExists would save it.
+	err := repo.SaveUser(ctx, User{Username: " ＴＷＩＮ" + suffix, Email: "second@no.email"})
+
+	verr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, int(DUPLICATE_VALUE_CODE), verr.ErrorCode())
+	user, err := repo.LoadUser(ctx, "twin"+suffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "first@no.email", user.Email)
+}
+
+// setupTestRepository migrates and seeds a fresh test database.
+func setupTestRepository(t *testing.T) (*UserRepository, *UserService) {
+	repo := NewUserRepository(DbTemp(t), 0)
+	_, err := repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+	service := NewUserService(repo.Stores(), repo)
+	err = service.InitiateModels(context.Background())
+	assert.Nil(t, err)
+	return repo, service
+}
+
+func TestLoadUser_ShouldStopOnCancelledContext(t *testing.T) {
+	repo := NewUserRepository(DbTemp(t), time.Second)
+	_, err := repo.MigrateUp(context.Background())
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = repo.LoadUser(ctx, "admin")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = NewMemoryStore().LoadUser(ctx, "admin")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+var EMPTY_VALUE_CODE uint8 = 1
+var DUPLICATE_VALUE_CODE uint8 = 2
+var INVALID_VALUE_CODE uint8 = 3
+var INSUFFICIENT_POINTS_CODE uint8 = 4
+var OUT_OF_STOCK_CODE uint8 = 5
+var UNAVAILABLE_CODE uint8 = 6
+var PURCHASE_LIMIT_CODE uint8 = 7
+
+type ValidationError struct {
+	errorCode uint8
+	field     string
+	err       error
+}
+
+func (v *ValidationError) Error() string {
+	return v.err.Error()
+}
+
+func (v *ValidationError) ErrorCode() int {
+	return int(v.errorCode)
+}
+
+func (v *ValidationError) Field() string {
+	return v.field
+}
+
+func ValidationErrorNew(field string, err string, code uint8) error {
+	var error ValidationError
+	error.err = errors.New(err)
+	error.field = field
+	error.errorCode = code
+	return &error
+}
+
+// isDuplicateKeyError reports whether err is a unique constraint violation.
+// GORM translates most dialects to gorm.ErrDuplicatedKey, but SQL Server
+// raises 2601 (rather than 2627) when a unique index is violated.
+func isDuplicateKeyError(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.Number == 2601 || mssqlErr.Number == 2627
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+type Level int32
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+var levelNames = map[Level]string{
+	DEBUG: "DEBUG",
+	INFO:  "INFO",
+	WARN:  "WARN",
+	ERROR: "ERROR",
+}
+
+var currentLevel int32 = int32(INFO)
+
+func (l Level) String() string {
+	name, ok := levelNames[l]
+	if !ok {
+		return "UNKNOWN"
+	}
+	return name
+}
+
+func ParseLevel(level string) (Level, error) {
+	upper := strings.ToUpper(strings.TrimSpace(level))
+	if upper == "WARNING" {
+		upper = "WARN"
+	}
+	for l, name := range levelNames {
+		if name == upper {
+			return l, nil
+		}
+	}
+	return INFO, errors.New("unknown log level: " + level)
+}
+
+// SetLevel changes the minimum level that is written. It is safe to call
+// while other goroutines are logging.
+func SetLevel(level Level) {
+	atomic.StoreInt32(&currentLevel, int32(level))
+}
+
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&currentLevel))
+}
+
+func Enabled(level Level) bool {
+	return level >= GetLevel()
+}
+
+func logf(level Level, format string, v ...any) {
+	if !Enabled(level) {
+		return
+	}
+	log.Printf("["+level.String()+"] "+format, v...)
+}
+
+func Debugf(format string, v ...any) {
+	logf(DEBUG, format, v...)
+}
+
+func Infof(format string, v ...any) {
+	logf(INFO, format, v...)
+}
+
+func Warnf(format string, v ...any) {
+	logf(WARN, format, v...)
+}
+
+func Errorf(format string, v ...any) {
+	logf(ERROR, format, v...)
+}
@@ -0,0 +1,70 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"blue-beetle/config"
+)
+
+// ErrDisabled is returned by SMTPSender while mail is disabled in the
+// configuration. Callers are expected to fall back to showing the content.
+var ErrDisabled = errors.New("mail is disabled")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email.
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// SMTPSender sends through the SMTP server of the live mail settings, so a
+// configuration reload takes effect with the next message.
+type SMTPSender struct{}
+
+func (SMTPSender) Send(ctx context.Context, message Message) error {
+	settings := config.Live().Mail
+	if !settings.Enabled {
+		return ErrDisabled
+	}
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if settings.Username != "" {
+		auth = smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)
+	}
+	address := net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port))
+	return smtp.SendMail(address, auth, settings.From, []string{message.To}, compose(settings.From, message, time.Now()))
+}
+
+// compose renders the message with its headers. Line breaks are removed
+// from header values so they cannot inject headers.
+func compose(from string, message Message, date time.Time) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&buffer, "To: %s\r\n", header.Replace(message.To))
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", header.Replace(message.Subject)))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("\r\n")
+	body := strings.ReplaceAll(message.Body, "\r\n", "\n")
+	buffer.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buffer.Bytes()
+}
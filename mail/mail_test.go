@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompose_ShouldEncodeHeadersAndBody(t *testing.T) {
+	date := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	raw := string(compose("club@example.com", Message{
+		To:      "parent@example.com\r\nBcc: someone@example.com",
+		Subject: "Einladung für Eltern",
+		Body:    "Hello\nWorld",
+	}, date))
+
+	assert.Contains(t, raw, "To: parent@example.comBcc: someone@example.com\r\n")
+	assert.Contains(t, raw, "Subject: =?utf-8?q?Einladung_f=C3=BCr_Eltern?=\r\n")
+	assert.Contains(t, raw, "Date: Sun, 01 Mar 2026 10:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\nHello\r\nWorld"))
+}
+
+func TestSend_ShouldReportDisabledMail(t *testing.T) {
+	err := SMTPSender{}.Send(context.Background(), Message{To: "parent@example.com"})
+
+	assert.True(t, errors.Is(err, ErrDisabled))
+}
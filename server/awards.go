@@ -0,0 +1,332 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blue-beetle/database"
+	"blue-beetle/logging"
+)
+
+// recentEvents is how far back the award page offers events.
+const recentEvents = 7 * 24 * time.Hour
+
+type awardsPage struct {
+	EventID      string
+	TeamID       string
+	Reason       string
+	Events       []database.Event
+	Teams        []database.Team
+	Categories   []database.Category
+	Participants []database.Participant
+	Batches      []database.AwardBatch
+	// Values holds the points typed in each cell, keyed by CellName.
+	Values  map[string]string
+	Checked map[string]bool
+	Success string
+	Error   bool
+	Message string
+}
+
+// CellName is the form field of a participant's points in a category, an
+// empty categoryID is the uncategorized column.
+func (p awardsPage) CellName(participantID string, categoryID string) string {
+	return "points:" + participantID + ":" + categoryID
+}
+
+// awardFromForm reads the checked participants' points. Empty and zero
+// cells are skipped so only the columns that apply need filling in.
+func awardFromForm(r *http.Request) (database.BulkAward, error) {
+	award := database.BulkAward{
+		EventID: r.PostFormValue("event"),
+		TeamID:  r.PostFormValue("team"),
+		Reason:  r.PostFormValue("reason"),
+	}
+	checked := make(map[string]bool)
+	for _, participantID := range r.PostForm["participant"] {
+		checked[participantID] = true
+	}
+	for name, values := range r.PostForm {
+		parts := strings.Split(name, ":")
+		if len(parts) != 3 || parts[0] != "points" || !checked[parts[1]] || strings.TrimSpace(values[0]) == "" {
+			continue
+		}
+		points, err := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64)
+		if err != nil {
+			return award, database.ValidationErrorNew("points", "points must be whole numbers", database.INVALID_VALUE_CODE)
+		}
+		if points == 0 {
+			continue
+		}
+		award.Lines = append(award.Lines, database.AwardLine{ParticipantID: parts[1], CategoryID: parts[2], Points: points})
+	}
+	return award, nil
+}
+
+// awardsPage awards points to many participants at once. GET shows the
+// participants of the picked team (?team=) with a points column per
+// category, POST records the checked rows as one batch.
+func (h *Handlers) awardsPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	page := awardsPage{Values: make(map[string]string), Checked: make(map[string]bool)}
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodGet:
+		values := r.URL.Query()
+		page.EventID = values.Get("event")
+		page.TeamID = values.Get("team")
+		switch {
+		case values.Get("awarded") != "":
+			page.Success = "The points were awarded."
+		case values.Get("reversed") != "":
+			page.Success = "The batch was reversed."
+		}
+	case http.MethodPost:
+		award, err := awardFromForm(r)
+		if err == nil {
+			var batch database.AwardBatch
+			batch, err = h.awards.Award(r.Context(), user, award)
+			if err == nil {
+				http.Redirect(w, r, "/awards?awarded="+batch.ID, http.StatusSeeOther)
+				return
+			}
+		}
+		status, page.Message = h.awardError(err)
+		page.Error = true
+		page.EventID, page.TeamID, page.Reason = award.EventID, award.TeamID, award.Reason
+		for name, values := range r.PostForm {
+			if strings.HasPrefix(name, "points:") {
+				page.Values[name] = values[0]
+			}
+		}
+		for _, participantID := range r.PostForm["participant"] {
+			page.Checked[participantID] = true
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	err := h.loadAwardsPage(r, user, &page)
+	if err != nil {
+		status, page.Message = h.awardError(err)
+		page.Error = true
+	}
+	h.render(w, status, "awards.html", page)
+}
+
+func (h *Handlers) loadAwardsPage(r *http.Request, user database.User, page *awardsPage) error {
+	var err error
+	page.Participants, err = h.awards.Roster(r.Context(), user, page.TeamID)
+	if err != nil {
+		return err
+	}
+	page.Batches, err = h.awards.Batches(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	page.Events, err = h.events.Events(r.Context(), now.Add(-recentEvents), now.Add(24*time.Hour))
+	if err != nil {
+		return err
+	}
+	page.Teams, err = h.teams.Teams(r.Context())
+	if err != nil {
+		return err
+	}
+	page.Categories, err = h.categories.Categories(r.Context())
+	return err
+}
+
+// reverseAward reverses the batch posted by the award page.
+func (h *Handlers) reverseAward(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	batch, err := h.awards.Reverse(r.Context(), user, r.PostFormValue("batch"))
+	if err == nil {
+		http.Redirect(w, r, "/awards?reversed="+batch.ID, http.StatusSeeOther)
+		return
+	}
+	page := awardsPage{Values: make(map[string]string), Checked: make(map[string]bool), Error: true}
+	status, message := h.awardError(err)
+	if status != http.StatusForbidden && status != http.StatusInternalServerError {
+		err = h.loadAwardsPage(r, user, &page)
+		if err != nil {
+			status, message = h.awardError(err)
+		}
+	}
+	page.Message = message
+	h.render(w, status, "awards.html", page)
+}
+
+// awardError returns the status to answer a failed award or reversal with
+// and the message to show.
+func (h *Handlers) awardError(err error) (int, string) {
+	var validationErr *database.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest, validationErr.Error()
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound, "The event, team, participant, category or batch no longer exists."
+	case errors.Is(err, database.ErrSeasonClosed):
+		return http.StatusConflict, "The batch belongs to a closed season and cannot be reversed."
+	case errors.Is(err, database.ErrPermissionDenied):
+		return http.StatusForbidden, "You are not allowed to award points."
+	}
+	logging.Errorf("Awarding points failed: %v", err)
+	return http.StatusInternalServerError, "Awarding points is not available, try again later."
+}
+
+type awardLineJSON struct {
+	ParticipantID string `json:"participant_id"`
+	CategoryID    string `json:"category_id,omitempty"`
+	Points        int64  `json:"points"`
+}
+
+type awardRequestJSON struct {
+	EventID string          `json:"event_id,omitempty"`
+	TeamID  string          `json:"team_id,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+	Lines   []awardLineJSON `json:"lines"`
+}
+
+type awardBatchJSON struct {
+	ID         string     `json:"id"`
+	SeasonID   string     `json:"season_id"`
+	EventID    string     `json:"event_id,omitempty"`
+	TeamID     string     `json:"team_id,omitempty"`
+	Reason     string     `json:"reason"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReversedAt *time.Time `json:"reversed_at,omitempty"`
+	ReversedBy string     `json:"reversed_by,omitempty"`
+}
+
+func batchJSON(batch database.AwardBatch) awardBatchJSON {
+	return awardBatchJSON{
+		ID:         batch.ID,
+		SeasonID:   batch.SeasonID,
+		EventID:    batch.EventID,
+		TeamID:     batch.TeamID,
+		Reason:     batch.Reason,
+		CreatedBy:  batch.CreatedBy,
+		CreatedAt:  batch.CreatedAt,
+		ReversedAt: batch.ReversedAt,
+		ReversedBy: batch.ReversedBy,
+	}
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logging.Errorf("Writing response failed: %v", err)
+	}
+}
+
+// apiUser is currentUser for API endpoints, which answer 401 instead of
+// redirecting to the login page.
+func (h *Handlers) apiUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, ok, err := h.sessionUser(w, r)
+	if err != nil {
+		logging.Errorf("Loading the session user failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: http.StatusText(http.StatusInternalServerError)})
+		return database.User{}, false
+	}
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "login required"})
+	}
+	return user, ok
+}
+
+func (h *Handlers) writeAwardError(w http.ResponseWriter, err error) {
+	status, message := h.awardError(err)
+	writeJSON(w, status, apiError{Error: message})
+}
+
+// awardBatchesAPI lists the latest batches of the open season (GET) or
+// records a bulk award (POST) with the session's user.
+func (h *Handlers) awardBatchesAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.apiUser(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		batches, err := h.awards.Batches(r.Context(), user)
+		if err != nil {
+			h.writeAwardError(w, err)
+			return
+		}
+		list := make([]awardBatchJSON, len(batches))
+		for i, batch := range batches {
+			list[i] = batchJSON(batch)
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		var request awardRequestJSON
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "malformed award: " + err.Error()})
+			return
+		}
+		award := database.BulkAward{EventID: request.EventID, TeamID: request.TeamID, Reason: request.Reason}
+		for _, line := range request.Lines {
+			award.Lines = append(award.Lines, database.AwardLine{ParticipantID: line.ParticipantID, CategoryID: line.CategoryID, Points: line.Points})
+		}
+		batch, err := h.awards.Award(r.Context(), user, award)
+		if err != nil {
+			h.writeAwardError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, batchJSON(batch))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: http.StatusText(http.StatusMethodNotAllowed)})
+	}
+}
+
+// reverseAwardBatchAPI serves POST /api/award-batches/<id>/reverse.
+func (h *Handlers) reverseAwardBatchAPI(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/award-batches/")
+	batchID := strings.TrimSuffix(path, "/reverse")
+	if batchID == path || batchID == "" || strings.Contains(batchID, "/") {
+		writeJSON(w, http.StatusNotFound, apiError{Error: http.StatusText(http.StatusNotFound)})
+		return
+	}
+	user, ok := h.apiUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: http.StatusText(http.StatusMethodNotAllowed)})
+		return
+	}
+	batch, err := h.awards.Reverse(r.Context(), user, batchID)
+	if err != nil {
+		h.writeAwardError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, batchJSON(batch))
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"blue-beetle/database"
+	"blue-beetle/logging"
+	"blue-beetle/mail"
+)
+
+type guardianInvitePage struct {
+	Query        string
+	Participants []database.Participant
+	Participant  string
+	Email        string
+	// Link is shown when the invitation could not be emailed so it can be
+	// passed on by hand.
+	Link    string
+	Success string
+	Error   bool
+	Message string
+}
+
+type guardianAcceptPage struct {
+	Token       string
+	Participant database.Participant
+	Email       string
+	Username    string
+	Error       bool
+	Message     string
+}
+
+type guardianPage struct {
+	User         database.User
+	Participants []database.GuardianOverview
+	Events       []database.Event
+	Error        bool
+	Message      string
+}
+
+// inviteLink is the absolute link to accept the invitation of token.
+func (h *Handlers) inviteLink(token string) string {
+	return h.publicLink("/guardian/accept", url.Values{"token": {token}})
+}
+
+// guardianInvite lets staff invite a participant's guardian. GET searches
+// participants (?q=), POST emails an invitation for the picked participant.
+func (h *Handlers) guardianInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var page guardianInvitePage
+	status := http.StatusOK
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		page.Query = r.URL.Query().Get("q")
+		page.Participant = r.URL.Query().Get("participant")
+		if page.Query != "" {
+			page.Participants, err = h.guardians.SearchParticipants(r.Context(), user, page.Query)
+		}
+	case http.MethodPost:
+		page.Participant = r.PostFormValue("participant")
+		page.Email = r.PostFormValue("email")
+		if h.publicURL == "" {
+			err = errNoPublicURL
+			break
+		}
+		var token string
+		_, token, err = h.guardians.Invite(r.Context(), user, page.Participant, page.Email)
+		if err != nil {
+			break
+		}
+		link := h.inviteLink(token)
+		sendErr := h.mail.Send(r.Context(), mail.Message{
+			To:      page.Email,
+			Subject: "Your guardian account",
+			Body:    "You have been invited to follow your child's points.\n\nCreate your login here within 7 days:\n" + link + "\n",
+		})
+		switch {
+		case sendErr == nil:
+			page.Success = "The invitation was emailed to " + page.Email + "."
+		case errors.Is(sendErr, mail.ErrDisabled):
+			page.Success = "Mail is disabled, pass this link on to " + page.Email + "."
+			page.Link = link
+		default:
+			logging.Errorf("Emailing the guardian invitation failed: %v", sendErr)
+			page.Success = "The invitation could not be emailed, pass this link on to " + page.Email + "."
+			page.Link = link
+		}
+		page.Participant = ""
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		status, page.Message = guardianError(err)
+		page.Error = true
+	}
+	h.render(w, status, "guardian-invite.html", page)
+}
+
+// guardianAccept turns an invitation link into a guardian login. GET shows
+// the form, POST links the participant to a new or existing account and
+// logs it in.
+func (h *Handlers) guardianAccept(w http.ResponseWriter, r *http.Request) {
+	var page guardianAcceptPage
+	switch r.Method {
+	case http.MethodGet:
+		page.Token = r.URL.Query().Get("token")
+	case http.MethodPost:
+		page.Token = r.PostFormValue("token")
+		page.Username = r.PostFormValue("username")
+		user, err := h.guardians.AcceptInvite(r.Context(), page.Token, page.Username, r.PostFormValue("pwd"))
+		if err == nil {
+			session, err := h.sessions.Create(user.Username)
+			if err != nil {
+				logging.Errorf("Creating session failed: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			setSessionCookie(w, r, session)
+			http.Redirect(w, r, "/guardian", http.StatusSeeOther)
+			return
+		}
+		status, message := guardianError(err)
+		page.Error, page.Message = true, message
+		if status == http.StatusGone {
+			h.render(w, status, "guardian-accept.html", page)
+			return
+		}
+		invite, participant, loadErr := h.guardians.Invitation(r.Context(), page.Token)
+		if loadErr == nil {
+			page.Participant, page.Email = participant, invite.Email
+		}
+		h.render(w, status, "guardian-accept.html", page)
+		return
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	invite, participant, err := h.guardians.Invitation(r.Context(), page.Token)
+	if err != nil {
+		status, message := guardianError(err)
+		page.Error, page.Message = true, message
+		h.render(w, status, "guardian-accept.html", page)
+		return
+	}
+	page.Participant, page.Email = participant, invite.Email
+	h.render(w, http.StatusOK, "guardian-accept.html", page)
+}
+
+// guardian is the read-only page of a guardian: the balances, history and
+// badges of their participants and the upcoming events.
+func (h *Handlers) guardian(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	page := guardianPage{User: user}
+	status := http.StatusOK
+	var err error
+	page.Participants, err = h.guardians.Participants(r.Context(), user)
+	if err == nil {
+		page.Events, err = h.guardians.UpcomingEvents(r.Context(), user)
+	}
+	if err != nil {
+		status, page.Message = guardianError(err)
+		page.Error = true
+	}
+	h.render(w, status, "guardian.html", page)
+}
+
+// guardianError returns the status to answer a failed guardian request
+// with and the message to show.
+func guardianError(err error) (int, string) {
+	var validationErr *database.ValidationError
+	var logonErr *database.LogonError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest, validationErr.Error()
+	case errors.As(err, &logonErr):
+		return http.StatusUnauthorized, "Invalid username or password!"
+	case errors.Is(err, database.ErrInviteInvalid):
+		return http.StatusGone, "This invitation is invalid, expired or was already used. Ask for a new one."
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound, "The participant no longer exists."
+	case errors.Is(err, database.ErrPermissionDenied):
+		return http.StatusForbidden, "You are not allowed to see this page."
+	case errors.Is(err, errNoPublicURL):
+		return http.StatusServiceUnavailable, "Invitations need the public address of the server, ask an administrator to set server.public-url."
+	}
+	logging.Errorf("Guardian request failed: %v", err)
+	return http.StatusInternalServerError, "This page is not available, try again later."
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"blue-beetle/database"
+	"blue-beetle/logging"
+	"blue-beetle/mail"
+)
+
+// Services are the business services the handlers call.
+type Services struct {
+	Users        *database.UserService
+	Leaderboards *database.LeaderboardService
+	Catalog      *database.CatalogService
+	Checkout     *database.CheckoutService
+	Categories   *database.CategoryService
+	Teams        *database.TeamService
+	Events       *database.EventService
+	Awards       *database.AwardService
+	Guardians    *database.GuardianService
+	Kiosk        *database.KioskService
+	// Mail sends the guardian invitations.
+	Mail mail.Sender
+	// PublicURL is server.public-url, the base of the invitation and
+	// kiosk pairing links.
+	PublicURL string
+}
+
+// Handlers serves the web pages. Everything it needs is injected so it can
+// be tested with the in-memory stores.
+type Handlers struct {
+	users        *database.UserService
+	leaderboards *database.LeaderboardService
+	catalog      *database.CatalogService
+	checkout     *database.CheckoutService
+	categories   *database.CategoryService
+	teams        *database.TeamService
+	events       *database.EventService
+	awards       *database.AwardService
+	guardians    *database.GuardianService
+	kiosk        *database.KioskService
+	mail         mail.Sender
+	publicURL    string
+	sessions     *SessionStore
+	health       *database.HealthMonitor
+	pages        *template.Template
+}
+
+// NewHandlers parses the templates in pages, which must contain the
+// pages/*.html files. health backs the readiness endpoint.
+func NewHandlers(services Services, sessions *SessionStore, health *database.HealthMonitor, pages fs.FS) (*Handlers, error) {
+	templates, err := template.ParseFS(pages, "pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+	return &Handlers{
+		users:        services.Users,
+		leaderboards: services.Leaderboards,
+		catalog:      services.Catalog,
+		checkout:     services.Checkout,
+		categories:   services.Categories,
+		teams:        services.Teams,
+		events:       services.Events,
+		awards:       services.Awards,
+		guardians:    services.Guardians,
+		kiosk:        services.Kiosk,
+		mail:         services.Mail,
+		publicURL:    services.PublicURL,
+		sessions:     sessions,
+		health:       health,
+		pages:        templates,
+	}, nil
+}
+
+// errNoPublicURL is returned when a link for another device is needed but
+// server.public-url is not configured.
+var errNoPublicURL = errors.New("server.public-url is not configured")
+
+// publicLink is the absolute link to path with query on the configured
+// public URL. The Host header of a request is never used, the client
+// controls it. Callers check publicURL is set first.
+func (h *Handlers) publicLink(path string, query url.Values) string {
+	return strings.TrimSuffix(h.publicURL, "/") + path + "?" + query.Encode()
+}
+
+func (h *Handlers) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", h.home)
+	mux.HandleFunc("/login", h.login)
+	mux.HandleFunc("/logout", h.logout)
+	mux.HandleFunc("/password", h.changePassword)
+	mux.HandleFunc("/healthz", h.healthz)
+	mux.HandleFunc("/readyz", h.readyz)
+	mux.HandleFunc("/leaderboard", h.leaderboard)
+	mux.HandleFunc("/store", h.store)
+	mux.HandleFunc("/awards", h.awardsPage)
+	mux.HandleFunc("/awards/reverse", h.reverseAward)
+	mux.HandleFunc("/api/award-batches", h.awardBatchesAPI)
+	mux.HandleFunc("/api/award-batches/", h.reverseAwardBatchAPI)
+	mux.HandleFunc("/guardians/invite", h.guardianInvite)
+	mux.HandleFunc("/guardian/accept", h.guardianAccept)
+	mux.HandleFunc("/guardian", h.guardian)
+	mux.HandleFunc("/kiosks", h.kiosks)
+	mux.HandleFunc("/kiosks/revoke", h.revokeKiosk)
+	mux.HandleFunc("/kiosk/pair", h.pairKiosk)
+	mux.HandleFunc("/kiosk", h.kioskCheckIn)
+	return mux
+}
+
+// healthz reports the process is alive. It never touches the database so
+// an outage does not get the process restarted.
+func (h *Handlers) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok\n"))
+}
+
+type readiness struct {
+	Status    string     `json:"status"`
+	Database  string     `json:"database"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// readyz reports whether requests can be served, which requires the last
+// database check to have succeeded. Error details are only logged.
+func (h *Handlers) readyz(w http.ResponseWriter, r *http.Request) {
+	body := readiness{Status: "ready", Database: "ok"}
+	code := http.StatusOK
+	status := h.health.Ready(r.Context())
+	if !status.CheckedAt.IsZero() {
+		body.CheckedAt = &status.CheckedAt
+	}
+	if !status.Healthy {
+		body.Status = "unavailable"
+		body.Database = "unreachable"
+		if body.CheckedAt == nil {
+			body.Database = "unchecked"
+		}
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logging.Errorf("Writing readiness failed: %v", err)
+	}
+}
+
+type loginPage struct {
+	Error   bool
+	Message string
+}
+
+func (h *Handlers) render(w http.ResponseWriter, status int, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := h.pages.ExecuteTemplate(w, name, data)
+	if err != nil {
+		logging.Errorf("Rendering %s failed: %v", name, err)
+	}
+}
+
+func (h *Handlers) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		h.render(w, http.StatusOK, "login.html", loginPage{})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	user, err := h.users.LogonUser(r.Context(), r.PostFormValue("username"), r.PostFormValue("pwd"))
+	if err != nil {
+		var logonErr *database.LogonError
+		if !errors.As(err, &logonErr) {
+			logging.Errorf("Logon failed: %v", err)
+			h.render(w, http.StatusInternalServerError, "login.html", loginPage{Error: true, Message: "Login is not available, try again later."})
+			return
+		}
+		if uint8(logonErr.ErrorCode()) == database.FORCED_PASS_RESET_CODE {
+			// The password was right, it only has to be replaced.
+			h.render(w, http.StatusOK, "password.html", passwordPage{
+				Username: r.PostFormValue("username"),
+				Message:  "A new password is required for this account.",
+			})
+			return
+		}
+		h.render(w, http.StatusUnauthorized, "login.html", loginPage{Error: true, Message: logonMessage(logonErr)})
+		return
+	}
+	h.startSession(w, r, user)
+}
+
+// logonMessage is the message shown for a failed login.
+func logonMessage(logonErr *database.LogonError) string {
+	switch uint8(logonErr.ErrorCode()) {
+	case database.LOCKED_ACCOUNT_CODE:
+		return "This account is locked."
+	case database.FORCED_PASS_RESET_CODE:
+		return "A password reset is required for this account."
+	case database.LOGON_COUNT_FAILED_CODE:
+		return "Too many failed logins, ask an administrator to reset the password."
+	}
+	return "Invalid username or password!"
+}
+
+// startSession logs user in, ending the session the request came with, and
+// redirects to the home page.
+func (h *Handlers) startSession(w http.ResponseWriter, r *http.Request, user database.User) {
+	if previous, ok := h.sessions.FromRequest(r); ok {
+		h.sessions.Delete(previous.Token)
+	}
+	session, err := h.sessions.Create(user.Username)
+	if err != nil {
+		logging.Errorf("Creating session failed: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	setSessionCookie(w, r, session)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+type homePage struct {
+	Username string
+}
+
+// home is the landing page after login.
+func (h *Handlers) home(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	h.render(w, http.StatusOK, "home.html", homePage{Username: user.Username})
+}
+
+type passwordPage struct {
+	Username string
+	Error    bool
+	Message  string
+}
+
+// changePassword replaces a password, proven with the current one. Users
+// whose password must be reset are sent here by the login and are logged
+// in once it is changed.
+func (h *Handlers) changePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		user, _, err := h.sessionUser(w, r)
+		if err != nil {
+			logging.Errorf("Loading the session user failed: %v", err)
+		}
+		h.render(w, http.StatusOK, "password.html", passwordPage{Username: user.Username})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	page := passwordPage{Username: r.PostFormValue("username"), Error: true}
+	if r.PostFormValue("new-pwd") != r.PostFormValue("confirm-pwd") {
+		page.Message = "The new passwords do not match."
+		h.render(w, http.StatusBadRequest, "password.html", page)
+		return
+	}
+	user, err := h.users.ChangePassword(r.Context(), page.Username, r.PostFormValue("pwd"), r.PostFormValue("new-pwd"))
+	var logonErr *database.LogonError
+	var validationErr *database.ValidationError
+	switch {
+	case err == nil:
+		h.startSession(w, r, user)
+	case errors.As(err, &logonErr):
+		page.Message = logonMessage(logonErr)
+		h.render(w, http.StatusUnauthorized, "password.html", page)
+	case errors.As(err, &validationErr):
+		page.Message = validationErr.Error()
+		h.render(w, http.StatusBadRequest, "password.html", page)
+	default:
+		logging.Errorf("Changing the password failed: %v", err)
+		page.Message = "The password can not be changed now, try again later."
+		h.render(w, http.StatusInternalServerError, "password.html", page)
+	}
+}
+
+func (h *Handlers) logout(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.sessions.FromRequest(r)
+	if ok {
+		h.sessions.Delete(session.Token)
+	}
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// currentUser returns the logged in user. Without a valid session it
+// redirects to the login page and returns false.
+func (h *Handlers) currentUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, ok, err := h.sessionUser(w, r)
+	if err != nil {
+		logging.Errorf("Loading the session user failed: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return database.User{}, false
+	}
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}
+	return user, ok
+}
+
+// sessionUser loads the user of the request's session. A session whose user
+// no longer exists or was disabled is ended; ok is false for it as for a
+// missing session.
+func (h *Handlers) sessionUser(w http.ResponseWriter, r *http.Request) (user database.User, ok bool, err error) {
+	session, ok := h.sessions.FromRequest(r)
+	if !ok {
+		return database.User{}, false, nil
+	}
+	user, err = h.users.User(r.Context(), session.Username)
+	if errors.Is(err, database.ErrNotFound) || err == nil && user.DisableAccount {
+		h.sessions.Delete(session.Token)
+		clearSessionCookie(w)
+		return database.User{}, false, nil
+	}
+	if err != nil {
+		return database.User{}, false, err
+	}
+	return user, true, nil
+}
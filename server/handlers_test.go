@@ -0,0 +1,494 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"blue-beetle/database"
+	"blue-beetle/mail"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHandlers(t *testing.T) (*Handlers, *database.UserService) {
+	return setupHandlersWith(t, database.NewMemoryStore())
+}
+
+func setupHandlersWith(t *testing.T, store *database.MemoryStore) (*Handlers, *database.UserService) {
+	users := database.NewUserService(store.Stores(), store)
+	assert.Nil(t, users.InitiateModels(context.Background()))
+	services := Services{
+		Users:        users,
+		Leaderboards: database.NewLeaderboardService(store.Stores(), store),
+		Catalog:      database.NewCatalogService(store.Stores(), store),
+		Checkout:     database.NewCheckoutService(store.Stores(), store),
+		Categories:   database.NewCategoryService(store.Stores(), store),
+		Teams:        database.NewTeamService(store.Stores(), store),
+		Events:       database.NewEventService(store.Stores(), store),
+		Awards:       database.NewAwardService(store.Stores(), store),
+		Guardians:    database.NewGuardianService(store.Stores(), store),
+		Kiosk:        database.NewKioskService(store.Stores(), store),
+		Mail:         &fakeSender{},
+		PublicURL:    "https://points.example.org/",
+	}
+	handlers, err := NewHandlers(services, NewSessionStore(SessionLifetime), database.NewHealthMonitor(store, 0), os.DirFS(".."))
+	assert.Nil(t, err)
+	return handlers, users
+}
+
+// fakeSender keeps the sent messages.
+type fakeSender struct {
+	sent []mail.Message
+}
+
+func (f *fakeSender) Send(ctx context.Context, message mail.Message) error {
+	f.sent = append(f.sent, message)
+	return nil
+}
+
+func postLogin(handlers *Handlers, username string, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "pwd": {password}}
+	request := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, request)
+	return response
+}
+
+func TestLogin_ShouldRenderForm(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+	response := httptest.NewRecorder()
+
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `method="post"`)
+}
+
+func TestLogin_ShouldRejectBadPassword(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+
+	response := postLogin(handlers, "admin", "Wrong_Password1")
+
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Contains(t, response.Body.String(), "Invalid username or password!")
+	assert.Empty(t, response.Result().Cookies())
+}
+
+func TestLogin_ShouldCreateSession(t *testing.T) {
+	handlers, users := setupHandlers(t)
+	_, err := users.CreateNewUser(context.Background(), "leader", "leader@no.email", "Password_1")
+	assert.Nil(t, err)
+
+	response := postLogin(handlers, "Leader", "Password_1")
+
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	cookies := response.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	session, ok := handlers.sessions.Get(cookies[0].Value)
+	assert.True(t, ok)
+	assert.Equal(t, "leader", session.Username)
+}
+
+func postPasswordChange(handlers *Handlers, username string, current string, next string, confirm string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "pwd": {current}, "new-pwd": {next}, "confirm-pwd": {confirm}}
+	request := httptest.NewRequest(http.MethodPost, "/password", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, request)
+	return response
+}
+
+func TestLogin_ShouldRequireForcedPasswordChange(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+
+	response := postLogin(handlers, "admin", "Password_1")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `action="/password"`)
+	assert.Contains(t, response.Body.String(), "A new password is required")
+	assert.Empty(t, response.Result().Cookies())
+
+	response = postPasswordChange(handlers, "admin", "Password_1", "Password_2", "Password_3")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	response = postPasswordChange(handlers, "admin", "Wrong_Password1", "Password_2", "Password_2")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = postPasswordChange(handlers, "admin", "Password_1", "short", "short")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Empty(t, response.Result().Cookies())
+
+	response = postPasswordChange(handlers, "admin", "Password_1", "Password_2", "Password_2")
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/", response.Header().Get("Location"))
+	cookies := response.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	session, ok := handlers.sessions.Get(cookies[0].Value)
+	assert.True(t, ok)
+	assert.Equal(t, "admin", session.Username)
+
+	response = postLogin(handlers, "admin", "Password_2")
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+}
+
+func TestHome_ShouldGreetLoggedInUser(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/login", response.Header().Get("Location"))
+
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, httptest.NewRequest(http.MethodGet, "/", nil)))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "<strong>admin</strong>")
+
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, httptest.NewRequest(http.MethodGet, "/missing", nil)))
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestSession_ShouldEndForDisabledUser(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	handlers, _ := setupHandlersWith(t, store)
+	admin, err := store.LoadUser(ctx, "admin")
+	assert.Nil(t, err)
+	admin.DisableAccount = true
+	assert.Nil(t, store.SaveUser(ctx, admin))
+	request := loggedInRequest(t, handlers, httptest.NewRequest(http.MethodGet, "/", nil))
+	token, err := request.Cookie(sessionCookieName)
+	assert.Nil(t, err)
+
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/login", response.Header().Get("Location"))
+	_, ok := handlers.sessions.Get(token.Value)
+	assert.False(t, ok)
+	cookies := response.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	assert.True(t, cookies[0].MaxAge < 0)
+}
+
+type downPinger struct{}
+
+func (downPinger) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestReadyz_ShouldReportDatabaseHealth(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"status":"ready"`)
+
+	handlers.health = database.NewHealthMonitor(downPinger{}, 0)
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.NotContains(t, response.Body.String(), "connection refused")
+
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestLeaderboard_ShouldRenderStandings(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+	response := httptest.NewRecorder()
+
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/leaderboard?by=teams", nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "No points yet.")
+}
+
+func TestLeaderboard_ShouldRejectBadWeek(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+	response := httptest.NewRecorder()
+
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/leaderboard?week=last", nil))
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "week must look like 2026-W07")
+}
+
+func TestStore_ShouldRequireLogin(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+	response := httptest.NewRecorder()
+
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/store", nil))
+
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/login", response.Header().Get("Location"))
+}
+
+func loggedInRequest(t *testing.T, handlers *Handlers, request *http.Request) *http.Request {
+	session, err := handlers.sessions.Create("admin")
+	assert.Nil(t, err)
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session.Token})
+	return request
+}
+
+func TestStore_ShouldCheckOutCart(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	handlers, _ := setupHandlersWith(t, store)
+	ada, err := store.SaveParticipant(ctx, database.Participant{FirstName: "Ada", LastName: "Lovelace"})
+	assert.Nil(t, err)
+	_, err = database.NewPointService(store.Stores(), store).AwardPoints(ctx, ada.ID, 30, "attendance")
+	assert.Nil(t, err)
+	kite, err := handlers.catalog.SaveItem(ctx, database.SystemUser, database.CatalogItem{Name: "Kite", Price: 20, Stock: 1})
+	assert.Nil(t, err)
+
+	response := httptest.NewRecorder()
+	request := loggedInRequest(t, handlers, httptest.NewRequest(http.MethodGet, "/store?q=ada", nil))
+	handlers.Routes().ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Ada Lovelace")
+	assert.Contains(t, response.Body.String(), "Kite")
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/store", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+		return response
+	}
+	response = post(url.Values{"participant": {ada.ID}, "action": {"add"}, "item": {kite.ID}, "quantity": {"1"}})
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), kite.ID+":1:1")
+
+	// A stale cart, priced before another station sold the kite.
+	_, err = handlers.catalog.Redeem(ctx, database.SystemUser, ada.ID, kite.ID, 1)
+	assert.Nil(t, err)
+	response = post(url.Values{"participant": {ada.ID}, "line": {kite.ID + ":1:1"}, "action": {"checkout"}})
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Contains(t, response.Body.String(), "Accept changes")
+}
+
+func TestAwards_ShouldAwardCheckedParticipantsAndReverse(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	handlers, _ := setupHandlersWith(t, store)
+	ada, err := store.SaveParticipant(ctx, database.Participant{FirstName: "Ada", LastName: "Lovelace"})
+	assert.Nil(t, err)
+	bob, err := store.SaveParticipant(ctx, database.Participant{FirstName: "Bob", LastName: "Builder"})
+	assert.Nil(t, err)
+	verses, err := handlers.categories.CreateCategory(ctx, database.SystemUser, "Memory verses")
+	assert.Nil(t, err)
+
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, httptest.NewRequest(http.MethodGet, "/awards", nil)))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "points:"+ada.ID+":"+verses.ID)
+
+	form := url.Values{
+		"reason":                             {"Camp day"},
+		"participant":                        {ada.ID},
+		"points:" + ada.ID + ":":             {"5"},
+		"points:" + ada.ID + ":" + verses.ID: {"2"},
+		"points:" + bob.ID + ":":             {"5"},
+	}
+	request := httptest.NewRequest(http.MethodPost, "/awards", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	points := database.NewPointService(store.Stores(), store)
+	balance, err := points.Balance(ctx, ada.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), balance.Balance)
+	balance, err = points.Balance(ctx, bob.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), balance.Balance)
+
+	batches, err := handlers.awards.Batches(ctx, database.SystemUser)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(batches))
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodPost, "/api/award-batches/"+batches[0].ID+"/reverse", nil)
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"reversed_by":"admin"`)
+	balance, err = points.Balance(ctx, ada.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), balance.Balance)
+}
+
+func TestAwardBatchesAPI_ShouldRequireSession(t *testing.T) {
+	handlers, _ := setupHandlers(t)
+	response := httptest.NewRecorder()
+
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/api/award-batches", strings.NewReader(`{"lines":[]}`)))
+
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Contains(t, response.Body.String(), "login required")
+}
+
+func TestGuardian_InviteShouldNeedPublicURL(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	handlers, _ := setupHandlersWith(t, store)
+	handlers.publicURL = ""
+	ada, err := store.SaveParticipant(ctx, database.Participant{FirstName: "Ada", LastName: "Lovelace"})
+	assert.Nil(t, err)
+
+	form := url.Values{"participant": {ada.ID}, "email": {"parent@example.com"}}
+	request := httptest.NewRequest(http.MethodPost, "/guardians/invite", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.Empty(t, handlers.mail.(*fakeSender).sent)
+}
+
+func TestGuardian_ShouldInviteAcceptAndShowParticipant(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	handlers, _ := setupHandlersWith(t, store)
+	ada, err := store.SaveParticipant(ctx, database.Participant{FirstName: "Ada", LastName: "Lovelace"})
+	assert.Nil(t, err)
+	_, err = database.NewPointService(store.Stores(), store).AwardPoints(ctx, ada.ID, 12, "attendance")
+	assert.Nil(t, err)
+
+	form := url.Values{"participant": {ada.ID}, "email": {"parent@example.com"}}
+	request := httptest.NewRequest(http.MethodPost, "/guardians/invite", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Links ignore the Host header a client sends.
+	request.Host = "attacker.example"
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+	assert.Equal(t, http.StatusOK, response.Code)
+	sent := handlers.mail.(*fakeSender).sent
+	assert.Equal(t, 1, len(sent))
+	assert.Equal(t, "parent@example.com", sent[0].To)
+	start := strings.Index(sent[0].Body, "https://points.example.org/guardian/accept?token=")
+	assert.True(t, start >= 0)
+	link := strings.Fields(sent[0].Body[start:])[0]
+	token, err := url.QueryUnescape(strings.TrimPrefix(link, "https://points.example.org/guardian/accept?token="))
+	assert.Nil(t, err)
+
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/guardian/accept?token="+url.QueryEscape(token), nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Ada Lovelace")
+
+	form = url.Values{"token": {token}, "username": {"parent"}, "pwd": {"Password_1"}}
+	request = httptest.NewRequest(http.MethodPost, "/guardian/accept", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, request)
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/guardian", response.Header().Get("Location"))
+	cookies := response.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+
+	request = httptest.NewRequest(http.MethodGet, "/guardian", nil)
+	request.AddCookie(cookies[0])
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Ada Lovelace")
+	assert.Contains(t, response.Body.String(), "<strong>12</strong>")
+
+	// The guardian role cannot use the staff pages.
+	request = httptest.NewRequest(http.MethodGet, "/awards", nil)
+	request.AddCookie(cookies[0])
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, request)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestKiosk_ShouldPairCheckInAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	handlers, _ := setupHandlersWith(t, store)
+	ada, err := store.SaveParticipant(ctx, database.Participant{FirstName: "Ada", LastName: "Lovelace"})
+	assert.Nil(t, err)
+	start := time.Now().Add(-time.Hour)
+	_, err = handlers.events.CreateEvent(ctx, database.SystemUser, "Club night", start, start.Add(2*time.Hour))
+	assert.Nil(t, err)
+
+	form := url.Values{"name": {"Front door"}}
+	request := httptest.NewRequest(http.MethodPost, "/kiosks", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Host = "attacker.example"
+	response := httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+	assert.Equal(t, http.StatusOK, response.Code)
+	body := response.Body.String()
+	assert.Contains(t, body, "https://points.example.org/kiosk/pair?token=")
+	assert.NotContains(t, body, "attacker.example")
+	at := strings.Index(body, "/kiosk/pair?token=")
+	assert.True(t, at >= 0)
+	link := body[at : at+strings.Index(body[at:], "</pre>")]
+
+	// Pairing logs out the leader who opened the link on the tablet.
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, httptest.NewRequest(http.MethodGet, link, nil)))
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/kiosk", response.Header().Get("Location"))
+	var kioskCookie *http.Cookie
+	for _, cookie := range response.Result().Cookies() {
+		switch cookie.Name {
+		case kioskCookieName:
+			kioskCookie = cookie
+		case sessionCookieName:
+			assert.Equal(t, -1, cookie.MaxAge)
+		}
+	}
+	assert.NotNil(t, kioskCookie)
+	assert.Equal(t, "/kiosk", kioskCookie.Path)
+
+	kioskRequest := func(method string, target string, form url.Values) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(kioskCookie)
+		response := httptest.NewRecorder()
+		handlers.Routes().ServeHTTP(response, request)
+		return response
+	}
+	response = kioskRequest(http.MethodPost, "/kiosk", url.Values{"q": {"ada"}})
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Ada Lovelace")
+	response = kioskRequest(http.MethodPost, "/kiosk", url.Values{"q": {ada.ID}})
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/kiosk?points=1&welcome=Ada", response.Header().Get("Location"))
+	response = kioskRequest(http.MethodGet, "/kiosk?points=1&welcome=Ada", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Welcome, Ada!")
+	assert.Contains(t, response.Body.String(), "1 checked in")
+	balance, err := database.NewPointService(store.Stores(), store).Balance(ctx, ada.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), balance.Balance)
+	response = kioskRequest(http.MethodPost, "/kiosk", url.Values{"participant": {ada.ID}})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "already checked in")
+
+	// The device token does not open any other page.
+	response = kioskRequest(http.MethodGet, "/awards", nil)
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	assert.Equal(t, "/login", response.Header().Get("Location"))
+
+	devices, err := handlers.kiosk.Devices(ctx, database.SystemUser)
+	assert.Nil(t, err)
+	form = url.Values{"device": {devices[0].ID}}
+	request = httptest.NewRequest(http.MethodPost, "/kiosks/revoke", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response = httptest.NewRecorder()
+	handlers.Routes().ServeHTTP(response, loggedInRequest(t, handlers, request))
+	assert.Equal(t, http.StatusSeeOther, response.Code)
+	response = kioskRequest(http.MethodGet, "/kiosk", nil)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Contains(t, response.Body.String(), "not paired")
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"blue-beetle/database"
+	"blue-beetle/logging"
+)
+
+const kioskCookieName = "bb_kiosk"
+
+// kioskCookieLifetime is how long a paired device keeps its token; revoking
+// the device ends it earlier.
+const kioskCookieLifetime = 365 * 24 * time.Hour
+
+type kiosksPage struct {
+	Devices []database.KioskDevice
+	// PairLink is shown once after registering a device.
+	PairLink string
+	Success  string
+	Error    bool
+	Message  string
+}
+
+type kioskPage struct {
+	Paired       bool
+	Event        *database.Event
+	Query        string
+	Participants []database.Participant
+	CheckedIn    map[string]bool
+	Count        int
+	Welcome      string
+	Points       string
+	Error        bool
+	Message      string
+}
+
+// kiosks lets staff pair check-in devices (POST) and see or revoke the
+// paired ones.
+func (h *Handlers) kiosks(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var page kiosksPage
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("revoked") != "" {
+			page.Success = "The device was revoked."
+		}
+	case http.MethodPost:
+		if h.publicURL == "" {
+			err = errNoPublicURL
+			break
+		}
+		var device database.KioskDevice
+		var token string
+		device, token, err = h.kiosk.RegisterDevice(r.Context(), user, r.PostFormValue("name"))
+		if err == nil {
+			page.Success = "Open this link once on " + device.Name + " to pair it. It is only shown now."
+			page.PairLink = h.pairLink(token)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	status := http.StatusOK
+	if err != nil {
+		status, page.Message = kioskError(err)
+		page.Error = true
+	}
+	if status != http.StatusForbidden && status != http.StatusInternalServerError {
+		page.Devices, err = h.kiosk.Devices(r.Context(), user)
+		if err != nil {
+			status, page.Message = kioskError(err)
+			page.Error = true
+		}
+	}
+	h.render(w, status, "kiosks.html", page)
+}
+
+// pairLink is the absolute link that pairs the device of token.
+func (h *Handlers) pairLink(token string) string {
+	return h.publicLink("/kiosk/pair", url.Values{"token": {token}})
+}
+
+// revokeKiosk revokes the device posted by the kiosks page.
+func (h *Handlers) revokeKiosk(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	_, err := h.kiosk.Revoke(r.Context(), user, r.PostFormValue("device"))
+	if err == nil {
+		http.Redirect(w, r, "/kiosks?revoked=1", http.StatusSeeOther)
+		return
+	}
+	page := kiosksPage{Error: true}
+	status, message := kioskError(err)
+	if status != http.StatusForbidden && status != http.StatusInternalServerError {
+		page.Devices, err = h.kiosk.Devices(r.Context(), user)
+		if err != nil {
+			status, message = kioskError(err)
+		}
+	}
+	page.Message = message
+	h.render(w, status, "kiosks.html", page)
+}
+
+// pairKiosk stores the device token of the link in a cookie that is only
+// sent to the kiosk pages. A user logged in on the device is logged out so
+// the device cannot reach anything else.
+func (h *Handlers) pairKiosk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	_, err := h.kiosk.Authenticate(r.Context(), token)
+	if err != nil {
+		status, message := kioskError(err)
+		h.render(w, status, "kiosk.html", kioskPage{Error: true, Message: message})
+		return
+	}
+	session, ok := h.sessions.FromRequest(r)
+	if ok {
+		h.sessions.Delete(session.Token)
+	}
+	clearSessionCookie(w)
+	http.SetCookie(w, &http.Cookie{
+		Name:     kioskCookieName,
+		Value:    token,
+		Path:     "/kiosk",
+		Expires:  time.Now().Add(kioskCookieLifetime),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/kiosk", http.StatusSeeOther)
+}
+
+// kioskDevice returns the paired device of the request. Without one it
+// renders the not paired page and returns false.
+func (h *Handlers) kioskDevice(w http.ResponseWriter, r *http.Request) (database.KioskDevice, bool) {
+	var token string
+	cookie, err := r.Cookie(kioskCookieName)
+	if err == nil {
+		token = cookie.Value
+	}
+	device, err := h.kiosk.Authenticate(r.Context(), token)
+	if err != nil {
+		status, message := kioskError(err)
+		h.render(w, status, "kiosk.html", kioskPage{Error: true, Message: message})
+		return database.KioskDevice{}, false
+	}
+	return device, true
+}
+
+// kioskCheckIn is the page of a paired device. GET shows the current
+// event, POST searches participants (q) or checks one in (participant). A
+// scanned card matching one participant checks them in right away.
+func (h *Handlers) kioskCheckIn(w http.ResponseWriter, r *http.Request) {
+	device, ok := h.kioskDevice(w, r)
+	if !ok {
+		return
+	}
+	page := kioskPage{Paired: true}
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		page.Welcome = r.URL.Query().Get("welcome")
+		page.Points = r.URL.Query().Get("points")
+	case http.MethodPost:
+		participantID := r.PostFormValue("participant")
+		page.Query = strings.TrimSpace(r.PostFormValue("q"))
+		if participantID == "" && page.Query != "" {
+			page.Participants, err = h.kiosk.Search(r.Context(), device, page.Query)
+			if err == nil && len(page.Participants) == 1 && page.Participants[0].ID == page.Query {
+				participantID = page.Query
+			}
+		}
+		if participantID != "" {
+			var result database.KioskCheckIn
+			result, err = h.kiosk.CheckIn(r.Context(), device, participantID)
+			if err == nil {
+				values := url.Values{"welcome": {result.Participant.FirstName}, "points": {strconv.FormatInt(result.Points, 10)}}
+				http.Redirect(w, r, "/kiosk?"+values.Encode(), http.StatusSeeOther)
+				return
+			}
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	status := http.StatusOK
+	if err != nil {
+		status, page.Message = kioskError(err)
+		page.Error = true
+	}
+	event, eventErr := h.kiosk.CurrentEvent(r.Context(), device)
+	switch {
+	case eventErr == nil:
+		page.Event = &event
+		var checkIns []database.CheckIn
+		checkIns, eventErr = h.kiosk.CheckIns(r.Context(), device, event.ID)
+		page.CheckedIn = make(map[string]bool, len(checkIns))
+		for _, checkIn := range checkIns {
+			page.CheckedIn[checkIn.ParticipantID] = true
+		}
+		page.Count = len(checkIns)
+	case errors.Is(eventErr, database.ErrNoCurrentEvent):
+		eventErr = nil
+	}
+	if eventErr != nil && !page.Error {
+		status, page.Message = kioskError(eventErr)
+		page.Error = true
+	}
+	h.render(w, status, "kiosk.html", page)
+}
+
+// kioskError returns the status to answer a failed kiosk request with and
+// the message to show.
+func kioskError(err error) (int, string) {
+	var validationErr *database.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest, validationErr.Error()
+	case errors.Is(err, database.ErrKioskDenied):
+		return http.StatusForbidden, "This device is not paired for check-in. Ask a leader to pair it."
+	case errors.Is(err, database.ErrNoCurrentEvent):
+		return http.StatusConflict, "Check-in is closed, there is no event right now."
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound, "The participant or device no longer exists."
+	case errors.Is(err, database.ErrSeasonClosed):
+		return http.StatusConflict, "The season is closed."
+	case errors.Is(err, database.ErrPermissionDenied):
+		return http.StatusForbidden, "You are not allowed to manage kiosks."
+	case errors.Is(err, errNoPublicURL):
+		return http.StatusServiceUnavailable, "Pairing needs the public address of the server, ask an administrator to set server.public-url."
+	}
+	logging.Errorf("Kiosk request failed: %v", err)
+	return http.StatusInternalServerError, "Check-in is not available, ask a leader."
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blue-beetle/database"
+	"blue-beetle/logging"
+)
+
+type leaderboardPage struct {
+	By         string
+	CategoryID string
+	Week       string
+	Categories []database.Category
+	Rows       []database.LeaderboardRow
+	Error      bool
+	Message    string
+}
+
+// leaderboard shows the top participants or, with by=teams, the top teams.
+// It can be filtered by category and ISO week and needs no login, so it
+// can run on a screen in the room; participants are shown by their
+// initials unless leaderboard.initials-only is turned off.
+func (h *Handlers) leaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	values := r.URL.Query()
+	page := leaderboardPage{By: values.Get("by"), CategoryID: values.Get("category"), Week: values.Get("week")}
+	if page.By != "teams" {
+		page.By = "participants"
+	}
+	query := database.LeaderboardQuery{CategoryID: page.CategoryID, Week: page.Week}
+	if values.Get("limit") != "" {
+		limit, err := strconv.Atoi(values.Get("limit"))
+		if err != nil {
+			h.render(w, http.StatusBadRequest, "leaderboard.html", leaderboardPage{By: page.By, Error: true, Message: "limit must be a number"})
+			return
+		}
+		query.Limit = limit
+	}
+	categories, err := h.leaderboards.Categories(r.Context())
+	if err == nil {
+		page.Categories = categories
+		if page.By == "teams" {
+			page.Rows, err = h.leaderboards.Teams(r.Context(), query)
+		} else {
+			page.Rows, err = h.leaderboards.Participants(r.Context(), query)
+		}
+	}
+	if err != nil {
+		var validationErr *database.ValidationError
+		if errors.As(err, &validationErr) {
+			page.Error = true
+			page.Message = validationErr.Error()
+			h.render(w, http.StatusBadRequest, "leaderboard.html", page)
+			return
+		}
+		logging.Errorf("Loading the leaderboard failed: %v", err)
+		page.Error = true
+		page.Message = "The leaderboard is not available, try again later."
+		h.render(w, http.StatusInternalServerError, "leaderboard.html", page)
+		return
+	}
+	h.render(w, http.StatusOK, "leaderboard.html", page)
+}
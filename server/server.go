@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blue-beetle/config"
+	"blue-beetle/logging"
+)
+
+// Server is the main web listener plus the optional HTTP to HTTPS redirect
+// listener, built from ServerConfig.
+type Server struct {
+	settings config.ServerConfig
+	main     *http.Server
+	redirect *http.Server
+}
+
+// New builds the servers for serverConfig without starting them. When TLS
+// is in self-signed mode the certificate is generated here.
+func New(serverConfig config.ServerConfig, handler http.Handler) (*Server, error) {
+	s := &Server{settings: serverConfig}
+	tlsSettings := serverConfig.TLS
+	s.main = s.newHTTPServer(serverConfig.Port, handler)
+	if !tlsSettings.Enabled {
+		return s, nil
+	}
+	if tlsSettings.SelfSigned {
+		err := EnsureSelfSignedCertificate(tlsSettings.CertFile, tlsSettings.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tlsConfig, err := NewTLSConfig(tlsSettings)
+	if err != nil {
+		return nil, err
+	}
+	s.main.TLSConfig = tlsConfig
+	if tlsSettings.HSTSMaxAge > 0 {
+		s.main.Handler = HSTS(tlsSettings.HSTSMaxAge, handler)
+	}
+	if tlsSettings.RedirectPort != 0 {
+		s.redirect = s.newHTTPServer(tlsSettings.RedirectPort, RedirectHandler(serverConfig.Port))
+	}
+	return s, nil
+}
+
+func (s *Server) newHTTPServer(port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + strconv.Itoa(port),
+		Handler:           handler,
+		ReadTimeout:       s.settings.ReadTimeout,
+		ReadHeaderTimeout: s.settings.ReadHeaderTimeout,
+		WriteTimeout:      s.settings.WriteTimeout,
+		IdleTimeout:       s.settings.IdleTimeout,
+		MaxHeaderBytes:    s.settings.MaxHeaderBytes,
+	}
+}
+
+// ListenAndServe blocks serving requests until Shutdown is called, in which
+// case it returns nil, or a listener fails.
+func (s *Server) ListenAndServe() error {
+	if s.redirect != nil {
+		go func() {
+			logging.Infof("Redirecting HTTP on %s to HTTPS", s.redirect.Addr)
+			err := s.redirect.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				logging.Errorf("HTTP redirect listener stopped: %v", err)
+			}
+		}()
+	}
+	var err error
+	if s.settings.TLS.Enabled {
+		logging.Infof("Serving HTTPS on %s", s.main.Addr)
+		err = s.main.ListenAndServeTLS(s.settings.TLS.CertFile, s.settings.TLS.KeyFile)
+	} else {
+		logging.Warnf("TLS is disabled, credentials are sent in cleartext")
+		logging.Infof("Serving HTTP on %s", s.main.Addr)
+		err = s.main.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish until ctx expires, after which remaining connections are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		err := s.redirect.Shutdown(ctx)
+		if err != nil {
+			logging.Warnf("HTTP redirect listener did not shut down cleanly: %v", err)
+		}
+	}
+	err := s.main.Shutdown(ctx)
+	if err != nil {
+		closeErr := s.main.Close()
+		if closeErr != nil {
+			logging.Warnf("Closing remaining connections failed: %v", closeErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"blue-beetle/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestNew_ShouldApplyTimeouts(t *testing.T) {
+	serverConfig := config.DefaultConfig().Server
+	serverConfig.ReadTimeout = 7 * time.Second
+
+	srv, err := New(serverConfig, http.NotFoundHandler())
+	assert.Nil(t, err)
+	assert.Equal(t, 7*time.Second, srv.main.ReadTimeout)
+	assert.Equal(t, serverConfig.IdleTimeout, srv.main.IdleTimeout)
+	assert.Equal(t, serverConfig.MaxHeaderBytes, srv.main.MaxHeaderBytes)
+	assert.Nil(t, srv.redirect)
+}
+
+func TestShutdown_ShouldDrainInFlightRequests(t *testing.T) {
+	serverConfig := config.DefaultConfig().Server
+	serverConfig.Port = freePort(t)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	srv, err := New(serverConfig, handler)
+	assert.Nil(t, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		var response *http.Response
+		var err error
+		for i := 0; i < 50; i++ {
+			response, err = http.Get("http://127.0.0.1:" + strconv.Itoa(serverConfig.Port) + "/")
+			if err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer response.Body.Close()
+		data, _ := io.ReadAll(response.Body)
+		body <- string(data)
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, srv.Shutdown(ctx))
+	assert.Equal(t, "done", <-body)
+	assert.Nil(t, <-serveErr)
+}
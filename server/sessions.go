@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const sessionCookieName = "bb_session"
+
+// SessionLifetime is how long a login stays valid.
+const SessionLifetime = 12 * time.Hour
+
+// SessionSweepInterval is how often expired sessions are dropped.
+const SessionSweepInterval = 10 * time.Minute
+
+type Session struct {
+	Token    string
+	Username string
+	Expires  time.Time
+}
+
+// SessionStore keeps logged in sessions in memory, keyed by a random token
+// stored in an HttpOnly cookie.
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	lifetime time.Duration
+}
+
+func NewSessionStore(lifetime time.Duration) *SessionStore {
+	return &SessionStore{sessions: make(map[string]Session), lifetime: lifetime}
+}
+
+func (s *SessionStore) Create(username string) (Session, error) {
+	buffer := make([]byte, 32)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return Session{}, err
+	}
+	session := Session{
+		Token:    base64.RawURLEncoding.EncodeToString(buffer),
+		Username: username,
+		Expires:  time.Now().Add(s.lifetime),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.Token] = session
+	return session, nil
+}
+
+func (s *SessionStore) Get(token string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	if time.Now().After(session.Expires) {
+		delete(s.sessions, token)
+		return Session{}, false
+	}
+	return session, true
+}
+
+func (s *SessionStore) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Sweep drops the sessions expired at now and returns how many it dropped.
+// Get only removes an expired session when its cookie comes back, so
+// without sweeping abandoned sessions would pile up until restart.
+func (s *SessionStore) Sweep(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for token, session := range s.sessions {
+		if now.After(session.Expires) {
+			delete(s.sessions, token)
+			count++
+		}
+	}
+	return count
+}
+
+// Run sweeps expired sessions every interval until ctx is cancelled.
+func (s *SessionStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Sweep(now)
+		}
+	}
+}
+
+// FromRequest returns the session named by the request's cookie.
+func (s *SessionStore) FromRequest(r *http.Request) (Session, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return Session{}, false
+	}
+	return s.Get(cookie.Value)
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, session Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
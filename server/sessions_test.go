@@ -0,0 +1,21 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStoreSweep_ShouldDropExpiredSessions(t *testing.T) {
+	sessions := NewSessionStore(time.Hour)
+	session, err := sessions.Create("leader")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, sessions.Sweep(time.Now()))
+	_, ok := sessions.Get(session.Token)
+	assert.True(t, ok)
+
+	assert.Equal(t, 1, sessions.Sweep(session.Expires.Add(time.Second)))
+	assert.Equal(t, 0, len(sessions.sessions))
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"blue-beetle/database"
+	"blue-beetle/logging"
+)
+
+type storePage struct {
+	Query        string
+	Participants []database.Participant
+	Cart         database.Cart
+	Summary      *database.CartSummary
+	Items        []database.CatalogItem
+	Changed      bool
+	Error        bool
+	Message      string
+}
+
+// CartValue is a cart line as sent in the page's hidden "line" fields.
+func (p storePage) CartValue(line database.CartLine) string {
+	return line.ItemID + ":" + strconv.Itoa(line.Version) + ":" + strconv.Itoa(line.Quantity)
+}
+
+// cartFromForm reads the cart the page sent back. The cart lives in the
+// page, not the session, so several stations can share a login.
+func cartFromForm(r *http.Request) (database.Cart, error) {
+	cart := database.Cart{ParticipantID: r.PostFormValue("participant")}
+	for _, value := range r.PostForm["line"] {
+		parts := strings.Split(value, ":")
+		if len(parts) != 3 {
+			return cart, errors.New("malformed cart line " + value)
+		}
+		version, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return cart, err
+		}
+		quantity, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return cart, err
+		}
+		cart.Lines = append(cart.Lines, database.CartLine{ItemID: parts[0], Version: version, Quantity: quantity})
+	}
+	return cart, nil
+}
+
+// store is the store night checkout. GET searches participants (?q=) or
+// opens an empty cart (?participant=). POST changes the cart sent with it
+// according to action: add, remove, reprice or checkout.
+func (h *Handlers) store(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var page storePage
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		page.Query = r.URL.Query().Get("q")
+		page.Cart.ParticipantID = r.URL.Query().Get("participant")
+		if page.Cart.ParticipantID == "" && page.Query != "" {
+			page.Participants, err = h.checkout.SearchParticipants(r.Context(), user, page.Query)
+			if err == nil && len(page.Participants) == 1 {
+				// A scanned card or a unique name goes straight to the cart.
+				page.Cart.ParticipantID = page.Participants[0].ID
+			}
+		}
+	case http.MethodPost:
+		page.Cart, err = cartFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.PostFormValue("action") {
+		case "add":
+			quantity, convErr := strconv.Atoi(r.PostFormValue("quantity"))
+			if convErr != nil {
+				quantity = 1
+			}
+			page.Cart, err = h.checkout.AddToCart(r.Context(), user, page.Cart, r.PostFormValue("item"), quantity)
+		case "remove":
+			page.Cart = database.RemoveFromCart(page.Cart, r.PostFormValue("item"))
+		case "reprice":
+			page.Cart, err = h.checkout.Reprice(r.Context(), user, page.Cart)
+		case "checkout":
+			var receipt database.Receipt
+			receipt, err = h.checkout.Checkout(r.Context(), user, page.Cart)
+			if err == nil {
+				h.render(w, http.StatusOK, "receipt.html", receipt)
+				return
+			}
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	status := h.storeError(&page, err)
+	if status == http.StatusForbidden || status == http.StatusInternalServerError {
+		h.render(w, status, "store.html", page)
+		return
+	}
+	if page.Cart.ParticipantID != "" {
+		summary, err := h.checkout.Summarize(r.Context(), user, page.Cart)
+		if err == nil {
+			page.Summary = &summary
+			for _, line := range summary.Lines {
+				page.Changed = page.Changed || line.Changed
+			}
+			page.Items, err = h.catalog.AvailableItems(r.Context())
+		}
+		if err != nil {
+			status = h.storeError(&page, err)
+		}
+	}
+	h.render(w, status, "store.html", page)
+}
+
+// storeError puts the message for err on the page and returns the status
+// to answer with.
+func (h *Handlers) storeError(page *storePage, err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	page.Error = true
+	var validationErr *database.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		page.Message = validationErr.Error()
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrConflict):
+		page.Message = "Another station changed an item in this cart. Review the cart and confirm again."
+		return http.StatusConflict
+	case errors.Is(err, database.ErrNotFound):
+		page.Message = "The participant or item no longer exists."
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrPermissionDenied):
+		page.Message = "You are not allowed to check out at the store."
+		return http.StatusForbidden
+	}
+	logging.Errorf("Store checkout failed: %v", err)
+	page.Message = "The store is not available, try again later."
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"blue-beetle/config"
+	"blue-beetle/logging"
+)
+
+// modernCipherSuites only allows forward secret AEAD suites. TLS 1.3 suites
+// are not configurable in Go and are always enabled.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// intermediateCipherSuites adds CBC suites for older clients.
+var intermediateCipherSuites = append(append([]uint16{}, modernCipherSuites...),
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+)
+
+// NewTLSConfig builds the tls.Config for the min-version and cipher-policy
+// settings. Certificates are loaded separately by the listener.
+func NewTLSConfig(tlsConfig config.TLSConfig) (*tls.Config, error) {
+	var result tls.Config
+	switch tlsConfig.MinVersion {
+	case "1.2":
+		result.MinVersion = tls.VersionTLS12
+	case "1.3":
+		result.MinVersion = tls.VersionTLS13
+	default:
+		return nil, errors.New("unsupported TLS version: " + tlsConfig.MinVersion)
+	}
+	switch tlsConfig.CipherPolicy {
+	case "modern":
+		result.CipherSuites = modernCipherSuites
+	case "intermediate":
+		result.CipherSuites = intermediateCipherSuites
+	default:
+		return nil, errors.New("unsupported cipher policy: " + tlsConfig.CipherPolicy)
+	}
+	result.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+	return &result, nil
+}
+
+// EnsureSelfSignedCertificate writes a self-signed certificate and key to
+// certFile and keyFile when neither exists. If only one of them exists it
+// returns an error rather than overwrite it, since it may be a real key.
+func EnsureSelfSignedCertificate(certFile string, keyFile string) error {
+	certExists, err := fileExists(certFile)
+	if err != nil {
+		return err
+	}
+	keyExists, err := fileExists(keyFile)
+	if err != nil {
+		return err
+	}
+	switch {
+	case certExists && keyExists:
+		return nil
+	case certExists:
+		return errors.New("certificate " + certFile + " exists but its key " + keyFile + " is missing")
+	case keyExists:
+		return errors.New("key " + keyFile + " exists but its certificate " + certFile + " is missing")
+	}
+	logging.Warnf("Generating a self-signed certificate at %s, do not use it in production", certFile)
+	certPEM, keyPEM, err := generateSelfSignedCertificate(time.Now())
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(certFile), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(keyFile), 0700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(certFile, certPEM, 0644)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, keyPEM, 0600)
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+func generateSelfSignedCertificate(now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Blue-Beetle"}, CommonName: hostname},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost", hostname},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// RedirectHandler sends every plain HTTP request to the same host and path
+// on the HTTPS port.
+func RedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// HSTS adds a Strict-Transport-Security header with the given max-age in
+// seconds to every response.
+func HSTS(maxAge int, next http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(maxAge) + "; includeSubDomains"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"blue-beetle/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureSelfSignedCertificate_ShouldCreateLoadablePair(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+
+	err := EnsureSelfSignedCertificate(certFile, keyFile)
+	assert.Nil(t, err)
+	_, err = tls.LoadX509KeyPair(certFile, keyFile)
+	assert.Nil(t, err)
+}
+
+func TestEnsureSelfSignedCertificate_ShouldKeepLoneKey(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(keyFile, []byte("real key"), 0600))
+
+	err := EnsureSelfSignedCertificate(certFile, keyFile)
+
+	assert.NotNil(t, err)
+	key, err := os.ReadFile(keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "real key", string(key))
+	_, err = os.Stat(certFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewTLSConfig_ShouldApplyPolicy(t *testing.T) {
+	tlsConfig, err := NewTLSConfig(config.TLSConfig{MinVersion: "1.3", CipherPolicy: "modern"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, modernCipherSuites, tlsConfig.CipherSuites)
+
+	_, err = NewTLSConfig(config.TLSConfig{MinVersion: "1.0", CipherPolicy: "modern"})
+	assert.NotNil(t, err)
+}
+
+func TestRedirectHandler_ShouldRedirectToHTTPS(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "http://example.com:8080/login?next=%2F", nil)
+	response := httptest.NewRecorder()
+
+	RedirectHandler(8443).ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusMovedPermanently, response.Code)
+	assert.Equal(t, "https://example.com:8443/login?next=%2F", response.Header().Get("Location"))
+}
+
+func TestHSTS_ShouldSetHeader(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	response := httptest.NewRecorder()
+
+	HSTS(600, http.NotFoundHandler()).ServeHTTP(response, request)
+
+	assert.Equal(t, "max-age=600; includeSubDomains", response.Header().Get("Strict-Transport-Security"))
+}